@@ -0,0 +1,30 @@
+package extsort
+
+// Aggregator computes a reduction, such as a count, sum, or min/max, over the records
+// emitted by a sorter, fusing it into the output stage so no second pass is needed.
+type Aggregator[E any] interface {
+	// Observe is called once for every record sent on the output channel, in sorted
+	// order and after any SetMapOutput function has been applied.
+	Observe(E)
+	// Result returns the aggregate of the records observed so far.
+	Result() any
+}
+
+// SetAggregator registers an Aggregator that observes every record sent on the
+// output channel. Observe is always called from a single goroutine, so the
+// aggregator needs no locking of its own, but it blocks the output while it runs.
+// Records that could not be delivered, for example because the sort was cancelled,
+// are not observed. It must be called before Sort.
+func (s *GenericSorter[E]) SetAggregator(a Aggregator[E]) {
+	s.aggregator = a
+}
+
+// Aggregate returns the result of the Aggregator registered with SetAggregator, or
+// nil if there is none. It must only be called once the output channel has been
+// closed; the result then covers every record that was emitted.
+func (s *GenericSorter[E]) Aggregate() any {
+	if s.aggregator == nil {
+		return nil
+	}
+	return s.aggregator.Result()
+}
@@ -0,0 +1,79 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// statsAggregator tracks the count, sum, and range of the records it observes.
+type statsAggregator struct {
+	count, sum, min, max int
+}
+
+func (a *statsAggregator) Observe(v int) {
+	if a.count == 0 || v < a.min {
+		a.min = v
+	}
+	if a.count == 0 || v > a.max {
+		a.max = v
+	}
+	a.count++
+	a.sum += v
+}
+
+func (a *statsAggregator) Result() any {
+	return *a
+}
+
+// TestAggregator verifies that the aggregator observes every emitted record
+// for both the single-chunk and the multi-chunk merge paths.
+func TestAggregator(t *testing.T) {
+	for _, chunkSize := range []int{100, 10000} {
+		data := generateRandomInts(1000)
+		inputChan := make(chan int, len(data))
+		expected := statsAggregator{}
+		for _, v := range data {
+			inputChan <- v
+			expected.Observe(v * 2)
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		// the aggregator sees records after the output map is applied
+		sorter.SetMapOutput(func(i int) int { return i * 2 })
+		sorter.SetAggregator(&statsAggregator{})
+		sorter.Sort(context.Background())
+		if _, err := extsort.Collect(outChan, errChan, 0); err != nil {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+
+		got, ok := sorter.Aggregate().(statsAggregator)
+		if !ok {
+			t.Fatalf("chunk size %d: unexpected aggregate type %T", chunkSize, sorter.Aggregate())
+		}
+		if got != expected {
+			t.Errorf("chunk size %d: expected aggregate %+v, got %+v", chunkSize, expected, got)
+		}
+	}
+}
+
+// TestAggregatorUnset verifies that Aggregate returns nil without an aggregator.
+func TestAggregatorUnset(t *testing.T) {
+	inputChan := make(chan int)
+	close(inputChan)
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], nil)
+	sorter.Sort(context.Background())
+	if _, err := extsort.Collect(outChan, errChan, 0); err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if got := sorter.Aggregate(); got != nil {
+		t.Fatalf("expected nil aggregate, got %v", got)
+	}
+}
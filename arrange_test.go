@@ -0,0 +1,62 @@
+package extsort_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestArrange(t *testing.T) {
+	for _, chunkSize := range []int{100, 100000} {
+		// records are placed by rank, which here is the reverse of their value
+		data := rand.Perm(5000)
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+		rank := func(i int) int64 { return int64(10000 - 2*i) }
+		sorter, outChan, errChan := extsort.Arrange(inputChan, intFromBytes, intToBytes, rank, config)
+		sorter.Sort(context.Background())
+
+		result, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+		slices.Sort(data)
+		slices.Reverse(data)
+		if !slices.Equal(result, data) {
+			t.Fatalf("chunk size %d: output does not match ranked input", chunkSize)
+		}
+	}
+}
+
+func TestArrangeInvalidRank(t *testing.T) {
+	for name, rank := range map[string]func(int) int64{
+		"negative":  func(i int) int64 { return int64(i - 10) },
+		"duplicate": func(i int) int64 { return int64(i / 2) },
+	} {
+		inputChan := make(chan int, 1000)
+		for i := range 1000 {
+			inputChan <- i
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 100
+		sorter, outChan, errChan := extsort.Arrange(inputChan, intFromBytes, intToBytes, rank, config)
+		sorter.Sort(context.Background())
+
+		_, err := extsort.Collect(outChan, errChan, 0)
+		if !errors.Is(err, extsort.ErrInvalidRank) {
+			t.Errorf("%s: expected ErrInvalidRank, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package extsort
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+)
+
+// BloomFileSuffix is appended to the path of a sorted file to name the bloom filter
+// written next to it when Config.EmitBloom is set.
+const BloomFileSuffix = ".bloom"
+
+// bloomFileMagic identifies bloom filter files written by SortToFile.
+const bloomFileMagic = "extbloom"
+
+// bloomFileVersion is the current version of the bloom filter file format.
+// Files are laid out as:
+//
+//	header: the magic string, a big-endian uint32 version, a big-endian uint32
+//	        number of hash functions k, and a big-endian uint64 number of bits m
+//	bits:   the m bits of the filter in ceil(m/8) bytes, bit i being bit i%8
+//	        (least significant first) of byte i/8
+//
+// A key sets the bits at (h1 + j*h2) mod m for j in [0, k), computed with uint64
+// arithmetic, where h1 is the 64-bit FNV-1a hash of the key and h2 is the
+// splitmix64 finalizer of h1 with its lowest bit set.
+const bloomFileVersion uint32 = 1
+
+// bloomFileHeaderSize is the size in bytes of the bloom filter file header.
+const bloomFileHeaderSize = len(bloomFileMagic) + 4 + 4 + 8
+
+// defaultBloomFalsePositiveRate is used when Config.BloomFalsePositiveRate is 0.
+const defaultBloomFalsePositiveRate = 0.01
+
+// BloomFilter tests whether a key may be among the keys of the records of a sorted
+// file, without reading the file. It never reports a key that was added as absent,
+// but reports a key that was not added as present with about the false-positive
+// rate it was built for. A BloomFilter is safe for concurrent use.
+type BloomFilter struct {
+	k    uint32
+	m    uint64
+	bits []byte
+}
+
+// newBloomFilter returns a bloom filter sized for n keys at false-positive rate p.
+func newBloomFilter(n int, p float64) *BloomFilter {
+	n = max(n, 1)
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = max(m, 64)
+	k := uint32(max(math.Round(float64(m)/float64(n)*math.Ln2), 1))
+	return &BloomFilter{k: k, m: m, bits: make([]byte, (m+7)/8)}
+}
+
+// bloomHashes returns the two hashes that locate the bits of key.
+func bloomHashes(key []byte) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write(key)
+	h1 := h.Sum64()
+	// splitmix64 finalizer
+	h2 := h1
+	h2 = (h2 ^ (h2 >> 30)) * 0xbf58476d1ce4e5b9
+	h2 = (h2 ^ (h2 >> 27)) * 0x94d049bb133111eb
+	h2 ^= h2 >> 31
+	return h1, h2 | 1
+}
+
+// add sets the bits of key.
+func (b *BloomFilter) add(key []byte) {
+	h1, h2 := bloomHashes(key)
+	for j := uint64(0); j < uint64(b.k); j++ {
+		i := (h1 + j*h2) % b.m
+		b.bits[i/8] |= 1 << (i % 8)
+	}
+}
+
+// MayContain reports whether key may have been added to the filter. A false result
+// means the key was definitely not added.
+func (b *BloomFilter) MayContain(key []byte) bool {
+	h1, h2 := bloomHashes(key)
+	for j := uint64(0); j < uint64(b.k); j++ {
+		i := (h1 + j*h2) % b.m
+		if b.bits[i/8]&(1<<(i%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeTo writes the filter to w in the bloom filter file format.
+func (b *BloomFilter) writeTo(w io.Writer) error {
+	var header [bloomFileHeaderSize]byte
+	copy(header[:], bloomFileMagic)
+	binary.BigEndian.PutUint32(header[len(bloomFileMagic):], bloomFileVersion)
+	binary.BigEndian.PutUint32(header[len(bloomFileMagic)+4:], b.k)
+	binary.BigEndian.PutUint64(header[len(bloomFileMagic)+8:], b.m)
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(b.bits)
+	return err
+}
+
+// OpenBloomFilter reads a bloom filter written by SortToFile with Config.EmitBloom,
+// normally found at the path of the sorted file with BloomFileSuffix appended.
+// Files that are not bloom filters written by SortToFile, or that use an unsupported
+// version of the format, produce ErrInvalidSortedFile.
+func OpenBloomFilter(path string) (*BloomFilter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, NewDiskError(err, "open bloom filter", path)
+	}
+	defer func() { _ = f.Close() }()
+	r := bufio.NewReader(f)
+
+	var header [bloomFileHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("%w: bloom filter too short", ErrInvalidSortedFile)
+	}
+	if string(header[:len(bloomFileMagic)]) != bloomFileMagic {
+		return nil, fmt.Errorf("%w: bad bloom filter magic", ErrInvalidSortedFile)
+	}
+	if v := binary.BigEndian.Uint32(header[len(bloomFileMagic):]); v != bloomFileVersion {
+		return nil, fmt.Errorf("%w: unsupported bloom filter version %d", ErrInvalidSortedFile, v)
+	}
+	b := &BloomFilter{
+		k: binary.BigEndian.Uint32(header[len(bloomFileMagic)+4:]),
+		m: binary.BigEndian.Uint64(header[len(bloomFileMagic)+8:]),
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, NewDiskError(err, "stat bloom filter", path)
+	}
+	if b.k == 0 || b.m == 0 || uint64(info.Size()-int64(bloomFileHeaderSize)) != (b.m+7)/8 {
+		return nil, fmt.Errorf("%w: bloom filter size does not match its header", ErrInvalidSortedFile)
+	}
+	b.bits = make([]byte, (b.m+7)/8)
+	if _, err := io.ReadFull(r, b.bits); err != nil {
+		return nil, NewDiskError(err, "read bloom filter", path)
+	}
+	return b, nil
+}
+
+// writeBloomFile writes b next to the sorted file at path. With atomic, the filter
+// is written to a temporary file and renamed into place once complete.
+func writeBloomFile(path string, b *BloomFilter, atomic bool) error {
+	path += BloomFileSuffix
+	f, err := createOutputFile(path, atomic)
+	if err != nil {
+		return NewDiskError(err, "create bloom filter", path)
+	}
+	defer func() { _ = f.Close() }()
+	if atomic {
+		// removing fails harmlessly once the file has been renamed into place
+		defer func() { _ = os.Remove(f.Name()) }()
+	}
+
+	bw := bufio.NewWriter(f)
+	if err := b.writeTo(bw); err != nil {
+		return NewDiskError(err, "write bloom filter", path)
+	}
+	if err := bw.Flush(); err != nil {
+		return NewDiskError(err, "flush bloom filter", path)
+	}
+	if err := f.Sync(); err != nil {
+		return NewDiskError(err, "sync bloom filter", path)
+	}
+	if err := f.Close(); err != nil {
+		return NewDiskError(err, "close bloom filter", path)
+	}
+	if atomic {
+		return commitOutputFile(f.Name(), path)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestSortToFileBloom(t *testing.T) {
+	const n = 5000
+	// only even numbers are sorted, so odd numbers measure false positives
+	data := make([]int, n)
+	for i, v := range rand.Perm(n) {
+		data[i] = 2 * v
+	}
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 500
+	config.EmitBloom = true
+	path := sortIntsToFile(t, data, config)
+
+	bloom, err := extsort.OpenBloomFilter(path + extsort.BloomFileSuffix)
+	if err != nil {
+		t.Fatalf("OpenBloomFilter error: %v", err)
+	}
+	for _, v := range data {
+		key, _ := intToBytes(v)
+		if !bloom.MayContain(key) {
+			t.Fatalf("key %d reported absent", v)
+		}
+	}
+	falsePositives := 0
+	for v := 1; v < 2*n; v += 2 {
+		key, _ := intToBytes(v)
+		if bloom.MayContain(key) {
+			falsePositives++
+		}
+	}
+	// the default rate is 0.01
+	if rate := float64(falsePositives) / n; rate > 0.03 {
+		t.Errorf("false-positive rate %.3f, expected about 0.01", rate)
+	}
+}
+
+func TestSortToFileBloomKey(t *testing.T) {
+	data := []int{3, 1, 2}
+	config := extsort.DefaultConfig()
+	config.EmitBloom = true
+	config.BloomFalsePositiveRate = 0.001
+	config.AtomicOutput = true
+	// intToBytes is little-endian, so the first byte is the low byte
+	config.BloomKey = func(raw []byte) []byte { return raw[:1] }
+	path := sortIntsToFile(t, data, config)
+
+	bloom, err := extsort.OpenBloomFilter(path + extsort.BloomFileSuffix)
+	if err != nil {
+		t.Fatalf("OpenBloomFilter error: %v", err)
+	}
+	for _, v := range data {
+		if !bloom.MayContain([]byte{byte(v)}) {
+			t.Errorf("key %d reported absent", v)
+		}
+	}
+	// no temporary files are left next to the outputs
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected the sorted file and its filter, found %d files", len(entries))
+	}
+}
+
+func TestSortToFileBloomEmpty(t *testing.T) {
+	config := extsort.DefaultConfig()
+	config.EmitBloom = true
+	path := sortIntsToFile(t, nil, config)
+	bloom, err := extsort.OpenBloomFilter(path + extsort.BloomFileSuffix)
+	if err != nil {
+		t.Fatalf("OpenBloomFilter error: %v", err)
+	}
+	key, _ := intToBytes(1)
+	if bloom.MayContain(key) {
+		t.Error("empty filter reported a key present")
+	}
+}
+
+func TestSortToFileBloomInvalidRate(t *testing.T) {
+	inputChan := make(chan int)
+	close(inputChan)
+	config := extsort.DefaultConfig()
+	config.EmitBloom = true
+	config.BloomFalsePositiveRate = 1
+	err := extsort.SortToFile(context.Background(), inputChan, intFromBytes, intToBytes, cmp.Compare[int], filepath.Join(t.TempDir(), "sorted.dat"), config)
+	var configErr *extsort.ConfigError
+	if !errors.As(err, &configErr) || configErr.Field != "BloomFalsePositiveRate" {
+		t.Errorf("expected a BloomFalsePositiveRate ConfigError, got %v", err)
+	}
+}
+
+func TestOpenBloomFilterInvalid(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string][]byte{
+		"empty":     {},
+		"magic":     []byte("not a bloom filter at all"),
+		"version":   append([]byte("extbloom"), 0, 0, 0, 99, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 64),
+		"truncated": append([]byte("extbloom"), 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 64, 0),
+	}
+	for name, contents := range cases {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, contents, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := extsort.OpenBloomFilter(path); !errors.Is(err, extsort.ErrInvalidSortedFile) {
+			t.Errorf("%s: expected ErrInvalidSortedFile, got %v", name, err)
+		}
+	}
+}
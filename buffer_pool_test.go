@@ -0,0 +1,78 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+	"github.com/lanrat/extsort/tempfile"
+)
+
+// sortWithPool sorts data spilling to temporary files whose buffers come from pool.
+func sortWithPool(data []int, pool *tempfile.BufferPool) ([]int, error) {
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.BufferPool = pool
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	return extsort.Collect(outChan, errChan, 0)
+}
+
+// TestBufferPool verifies that sorts sharing a buffer pool, one after another and
+// concurrently, each produce their own sorted output.
+func TestBufferPool(t *testing.T) {
+	pool := tempfile.NewBufferPool()
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for j := 0; j < 5; j++ {
+				data := generateRandomInts(1000)
+				result, err := sortWithPool(data, pool)
+				if err == nil && !slices.Equal(result, slices.Sorted(slices.Values(data))) {
+					t.Errorf("sort %d: output does not match the sorted input", j)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("sort error: %v", err)
+		}
+	}
+}
+
+// BenchmarkBufferPool compares the allocations of sequential sorts that share a
+// buffer pool with sorts that each start from an empty pool.
+func BenchmarkBufferPool(b *testing.B) {
+	data := generateRandomInts(2000)
+	shared := tempfile.NewBufferPool()
+	for _, bc := range []struct {
+		name string
+		pool func() *tempfile.BufferPool
+	}{
+		{"shared", func() *tempfile.BufferPool { return shared }},
+		{"per-sort", tempfile.NewBufferPool},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := sortWithPool(data, bc.pool()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package extsort_test
+
+import (
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestRecommendChunkSize(t *testing.T) {
+	for _, tc := range []struct {
+		memBytes       int64
+		avgRecordBytes int
+		numWorkers     int
+		want           int
+	}{
+		// 7/8 of 1GiB of 100 byte records with the default 2 workers, over 24 chunks
+		{1 << 30, 100, 2, 391468},
+		{1 << 30, 100, 0, 391468},
+		// 8GiB of 1KiB records with 8 workers, over 42 chunks
+		{8 << 30, 1024, 8, 174762},
+		// 64MiB of 16 byte records with 1 worker, over 21 chunks
+		{64 << 20, 16, 1, 174762},
+		// too little memory still gives a valid chunk size
+		{1000, 100, 2, 2},
+		{0, 0, 2, 2},
+	} {
+		if got := extsort.RecommendChunkSize(tc.memBytes, tc.avgRecordBytes, tc.numWorkers); got != tc.want {
+			t.Errorf("RecommendChunkSize(%d, %d, %d) = %d, want %d", tc.memBytes, tc.avgRecordBytes, tc.numWorkers, got, tc.want)
+		}
+	}
+}
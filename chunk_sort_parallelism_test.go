@@ -0,0 +1,72 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestChunkSortParallelism verifies that sorting is correct when the number of
+// chunk sorting goroutines differs from NumWorkers.
+func TestChunkSortParallelism(t *testing.T) {
+	for _, parallelism := range []int{1, 4} {
+		data := generateRandomInts(5000)
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 100
+		config.NumWorkers = 1
+		config.ChunkSortParallelism = parallelism
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+		got, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("parallelism %d: sort error: %v", parallelism, err)
+		}
+		slices.Sort(data)
+		if !slices.Equal(got, data) {
+			t.Fatalf("parallelism %d: output does not match sorted input", parallelism)
+		}
+	}
+}
+
+// BenchmarkChunkSortParallelism compares sorting with a single merge worker while
+// varying the number of goroutines sorting chunks.
+func BenchmarkChunkSortParallelism(b *testing.B) {
+	data := generateRandomInts(500000)
+	levels := slices.Compact(slices.Sorted(slices.Values([]int{1, 2, runtime.GOMAXPROCS(0)})))
+	for _, parallelism := range levels {
+		b.Run(fmt.Sprintf("parallelism=%d", parallelism), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				inputChan := make(chan int, len(data))
+				for _, v := range data {
+					inputChan <- v
+				}
+				close(inputChan)
+
+				config := extsort.DefaultConfig()
+				config.ChunkSize = 50000
+				config.NumWorkers = 1
+				config.ChunkSortParallelism = parallelism
+
+				sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+				sorter.Sort(context.Background())
+				for range outChan {
+				}
+				if err := <-errChan; err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
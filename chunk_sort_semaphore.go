@@ -0,0 +1,41 @@
+package extsort
+
+import "context"
+
+// ChunkSortSemaphore bounds the number of chunks being sorted at once across every
+// sort that shares it. It does not bound goroutines: each sort still starts its own
+// workers, merges and save worker, but a worker only sorts a chunk while it holds
+// one of the semaphore's slots, so the CPU bound part of many concurrent sorts is
+// limited to the size of the semaphore rather than to the sum of their worker counts.
+// The other stages mostly wait on I/O and on each other, and are left unbounded so
+// that a sort blocked on its consumer cannot hold a slot another sort needs. A
+// ChunkSortSemaphore is safe for concurrent use and needs no cleanup.
+type ChunkSortSemaphore struct {
+	slots chan struct{}
+}
+
+// NewChunkSortSemaphore returns a ChunkSortSemaphore that lets at most size chunks
+// be sorted at the same time. A size below 1 is treated as 1.
+func NewChunkSortSemaphore(size int) *ChunkSortSemaphore {
+	return &ChunkSortSemaphore{slots: make(chan struct{}, max(size, 1))}
+}
+
+// Size returns the number of chunks the semaphore lets be sorted at the same time.
+func (s *ChunkSortSemaphore) Size() int {
+	return cap(s.slots)
+}
+
+// acquire waits for a free slot, or until ctx is done.
+func (s *ChunkSortSemaphore) acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot taken by acquire.
+func (s *ChunkSortSemaphore) release() {
+	<-s.slots
+}
@@ -0,0 +1,78 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestChunkSortSemaphore(t *testing.T) {
+	const sorts = 8
+	sem := extsort.NewChunkSortSemaphore(1)
+	if sem.Size() != 1 {
+		t.Fatalf("expected semaphore size 1, got %d", sem.Size())
+	}
+
+	// chunk sorts are the only place comparisons happen for inputs that fit in a
+	// single chunk, so overlapping comparisons mean overlapping chunk sorts
+	var active, maxActive atomic.Int64
+	compare := func(a, b int) int {
+		n := active.Add(1)
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		defer active.Add(-1)
+		runtime.Gosched() // give other sorts a chance to overlap
+		return cmp.Compare(a, b)
+	}
+
+	for _, chunkSize := range []int{10000, 500} {
+		var wg sync.WaitGroup
+		errs := make(chan error, sorts)
+		for range sorts {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				data := generateRandomInts(5000)
+				inputChan := make(chan int, len(data))
+				for _, v := range data {
+					inputChan <- v
+				}
+				close(inputChan)
+
+				config := extsort.DefaultConfig()
+				config.ChunkSize = chunkSize
+				config.NumWorkers = 4
+				config.ChunkSortSemaphore = sem
+				sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, compare, config)
+				sorter.Sort(context.Background())
+				result, err := extsort.Collect(outChan, errChan, 0)
+				if err != nil {
+					errs <- err
+					return
+				}
+				slices.Sort(data)
+				if !slices.Equal(result, data) {
+					t.Errorf("chunk size %d: output does not match sorted input", chunkSize)
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+		if chunkSize == 10000 && maxActive.Load() != 1 {
+			t.Errorf("expected one chunk sorted at a time, saw %d", maxActive.Load())
+		}
+	}
+}
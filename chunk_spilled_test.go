@@ -0,0 +1,81 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// chunkStats records the arguments of a single OnChunkSpilled callback.
+type chunkStats struct {
+	id, min, max, count int
+}
+
+// TestOnChunkSpilled verifies that the callback reports the bounds of every spilled chunk.
+func TestOnChunkSpilled(t *testing.T) {
+	data := make([]int, 1000)
+	for i := range data {
+		data[i] = i
+	}
+	// reverse each chunk so it needs sorting while its bounds stay known
+	for i := 0; i < len(data); i += 100 {
+		slices.Reverse(data[i : i+100])
+	}
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.NumWorkers = 1 // keep chunks in input order
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	var stats []chunkStats
+	sorter.SetOnChunkSpilled(func(id, min, max, count int) {
+		stats = append(stats, chunkStats{id, min, max, count})
+	})
+	sorter.Sort(context.Background())
+	for range outChan {
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+
+	if len(stats) != 10 {
+		t.Fatalf("expected 10 callbacks, got %d", len(stats))
+	}
+	for i, s := range stats {
+		want := chunkStats{id: i, min: i * 100, max: i*100 + 99, count: 100}
+		if s != want {
+			t.Errorf("chunk %d: expected %+v, got %+v", i, want, s)
+		}
+	}
+}
+
+// TestOnChunkSpilledSingleChunk verifies that the callback is not invoked when
+// the input is sorted entirely in memory.
+func TestOnChunkSpilledSingleChunk(t *testing.T) {
+	inputChan := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		inputChan <- i
+	}
+	close(inputChan)
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], nil)
+	called := false
+	sorter.SetOnChunkSpilled(func(int, int, int, int) { called = true })
+	sorter.Sort(context.Background())
+	for range outChan {
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if called {
+		t.Error("expected no callback for a single in-memory chunk")
+	}
+}
@@ -0,0 +1,83 @@
+package extsort
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// transformBlockSize is the size of the serialized data passed to each call of
+// Config.ChunkTransformWrite. A block ends at the first record boundary past it,
+// so a single record larger than a block is transformed whole.
+const transformBlockSize = 64 << 10
+
+// writeTransformBlock applies Config.ChunkTransformWrite to the serialized records
+// in buf and writes the result to the temporary file, framed by its length, so the
+// merge can decode a chunk one block at a time. buf is reset afterwards.
+func (s *GenericSorter[E]) writeTransformBlock(buf *bytes.Buffer, scratch []byte) error {
+	if buf.Len() == 0 {
+		return nil
+	}
+	out, err := s.config.ChunkTransformWrite(buf.Bytes())
+	if err != nil {
+		return NewSerializationError(err, "ChunkTransformWrite")
+	}
+	buf.Reset()
+	n := binary.PutUvarint(scratch, uint64(len(out)))
+	if _, err := s.tempWriter.Write(scratch[:n]); err != nil {
+		return NewDiskError(err, "write block header", "")
+	}
+	if _, err := s.tempWriter.Write(out); err != nil {
+		return NewDiskError(err, "write data", "")
+	}
+	return nil
+}
+
+// transformReader reads a chunk written by writeTransformBlock, applying
+// Config.ChunkTransformRead to one block at a time, so that only a single
+// encoded and decoded block of each chunk is held in memory during the merge.
+type transformReader struct {
+	r         *bufio.Reader
+	transform func([]byte) ([]byte, error)
+	encoded   []byte // reused for every block read
+	block     []byte // decoded bytes not yet returned
+}
+
+func (t *transformReader) Read(p []byte) (int, error) {
+	for len(t.block) == 0 {
+		if err := t.nextBlock(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, t.block)
+	t.block = t.block[n:]
+	return n, nil
+}
+
+// nextBlock reads and decodes the next block of the chunk. It returns io.EOF
+// once the chunk has been read entirely.
+func (t *transformReader) nextBlock() error {
+	size, err := binary.ReadUvarint(t.r)
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err != nil {
+		return NewDiskError(err, "read block header", "")
+	}
+	if uint64(cap(t.encoded)) < size {
+		t.encoded = make([]byte, size)
+	}
+	t.encoded = t.encoded[:size]
+	if _, err := io.ReadFull(t.r, t.encoded); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return NewDiskError(err, "read block", "")
+	}
+	t.block, err = t.transform(t.encoded)
+	if err != nil {
+		return NewDeserializationError(err, len(t.encoded), "ChunkTransformRead")
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package extsort_test
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzipBytes(b []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+// TestChunkTransformRoundTrip verifies that ChunkTransformRead reverses
+// ChunkTransformWrite for every chunk, on both the single-threaded and parallel merge.
+func TestChunkTransformRoundTrip(t *testing.T) {
+	for _, chunkSize := range []int{100, 400} {
+		data := generateRandomInts(1000)
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		// transforms may run concurrently in the merge workers
+		var written, read atomic.Int32
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+		config.ChunkTransformWrite = func(b []byte) ([]byte, error) {
+			written.Add(1)
+			return gzipBytes(b)
+		}
+		config.ChunkTransformRead = func(b []byte) ([]byte, error) {
+			read.Add(1)
+			return gunzipBytes(b)
+		}
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+		got, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+		slices.Sort(data)
+		if !slices.Equal(got, data) {
+			t.Fatalf("chunk size %d: output does not match sorted input", chunkSize)
+		}
+		chunks := (len(data) + chunkSize - 1) / chunkSize
+		if int(written.Load()) != chunks || int(read.Load()) != chunks {
+			t.Errorf("chunk size %d: expected %d chunks transformed, got %d written and %d read", chunkSize, chunks, written.Load(), read.Load())
+		}
+	}
+}
+
+// TestChunkTransformError verifies that transform errors abort the sort.
+func TestChunkTransformError(t *testing.T) {
+	errTransform := errors.New("transform failed")
+	identity := func(b []byte) ([]byte, error) { return b, nil }
+	failing := func([]byte) ([]byte, error) { return nil, errTransform }
+
+	for name, transforms := range map[string][2]func([]byte) ([]byte, error){
+		"write": {failing, identity},
+		"read":  {identity, failing},
+	} {
+		inputChan := make(chan int, 1000)
+		for _, v := range generateRandomInts(1000) {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 100
+		config.ChunkTransformWrite = transforms[0]
+		config.ChunkTransformRead = transforms[1]
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+		if _, err := extsort.Collect(outChan, errChan, 0); !errors.Is(err, errTransform) {
+			t.Errorf("%s: expected transform error, got %v", name, err)
+		}
+	}
+}
+
+// TestChunkTransformUnpaired verifies that setting only one transform is rejected.
+func TestChunkTransformUnpaired(t *testing.T) {
+	inputChan := make(chan int)
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkTransformWrite = gzipBytes
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	var configErr *extsort.ConfigError
+	if _, err := extsort.Collect(outChan, errChan, 0); !errors.As(err, &configErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+}
+
+// TestChunkTransformBlocks verifies that large chunks are transformed in bounded
+// blocks, both when written and when read back by the merge.
+func TestChunkTransformBlocks(t *testing.T) {
+	const n = 100000
+	data := generateRandomInts(n)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	var blocks atomic.Int32
+	var largest atomic.Int64
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 40000
+	config.MergeBufferBytes = 4096
+	config.ChunkTransformWrite = func(b []byte) ([]byte, error) {
+		if size := int64(len(b)); size > largest.Load() {
+			largest.Store(size)
+		}
+		return gzipBytes(b)
+	}
+	config.ChunkTransformRead = func(b []byte) ([]byte, error) {
+		blocks.Add(1)
+		return gunzipBytes(b)
+	}
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	got, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	slices.Sort(data)
+	if !slices.Equal(got, data) {
+		t.Fatal("output does not match sorted input")
+	}
+	// a block ends at the first record past 64KB
+	if size := largest.Load(); size > 64<<10+64 {
+		t.Errorf("expected blocks of about 64KB, got one of %d bytes", size)
+	}
+	if chunks := (n + config.ChunkSize - 1) / config.ChunkSize; int(blocks.Load()) <= chunks {
+		t.Errorf("expected chunks to be read in several blocks, got %d blocks for %d chunks", blocks.Load(), chunks)
+	}
+}
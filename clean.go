@@ -0,0 +1,68 @@
+package extsort
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultTempPrefixes are the name prefixes of the temporary files created by the
+// sorters. Run files written by ProduceRuns are owned by the caller and not included.
+var defaultTempPrefixes = []string{"extsort_", "extsort-payload-"}
+
+// CleanTempDir removes temporary files left in dir by sorts that did not shut down
+// cleanly, such as after a crash. It removes the regular files whose name starts with
+// prefix and that were last modified more than olderThan ago, and returns how many
+// were removed. An empty prefix selects the files created by the sorters of this
+// package. Files still in use by a running sort are usually recent, so olderThan
+// should comfortably exceed the duration of the longest sort; on Unix-like systems
+// spill files are unlinked as soon as they are created and are never left behind.
+// Subdirectories are not searched. Files that cannot be removed do not stop the
+// sweep; their errors are joined and returned.
+func CleanTempDir(dir, prefix string, olderThan time.Duration) (removed int, err error) {
+	prefixes := defaultTempPrefixes
+	if prefix != "" {
+		prefixes = []string{prefix}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, NewDiskError(err, "read temp dir", dir)
+	}
+	cutoff := time.Now().Add(-olderThan)
+	var errs []error
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !hasAnyPrefix(entry.Name(), prefixes) {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue // removed since the directory was read
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !info.ModTime().Before(cutoff) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, NewDiskError(err, "remove temp file", path))
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+// hasAnyPrefix reports whether name starts with any of prefixes.
+func hasAnyPrefix(name string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package extsort_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestCleanTempDir(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour)
+	for _, tc := range []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"custom_old", "extsort-run-old", "extsort_new", "notes.txt"}},
+		{"custom_", []string{"extsort-payload-old", "extsort-run-old", "extsort_1_old", "extsort_new", "notes.txt"}},
+	} {
+		dir := t.TempDir()
+		files := map[string]time.Time{
+			"extsort_1_old":       old,
+			"extsort-payload-old": old,
+			"extsort-run-old":     old, // run files belong to the caller
+			"custom_old":          old,
+			"notes.txt":           old,
+			"extsort_new":         time.Now(),
+		}
+		for name, mtime := range files {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// directories are never removed
+		if err := os.Mkdir(filepath.Join(dir, "extsort_dir"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		tc.want = append(tc.want, "extsort_dir")
+		slices.Sort(tc.want)
+
+		removed, err := extsort.CleanTempDir(dir, tc.prefix, time.Hour)
+		if err != nil {
+			t.Fatalf("prefix %q: CleanTempDir error: %v", tc.prefix, err)
+		}
+		if want := len(files) + 1 - len(tc.want); removed != want {
+			t.Errorf("prefix %q: expected %d files removed, got %d", tc.prefix, want, removed)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var left []string
+		for _, e := range entries {
+			left = append(left, e.Name())
+		}
+		if !slices.Equal(left, tc.want) {
+			t.Errorf("prefix %q: expected %v to remain, got %v", tc.prefix, tc.want, left)
+		}
+	}
+}
+
+func TestCleanTempDirMissing(t *testing.T) {
+	if _, err := extsort.CleanTempDir(filepath.Join(t.TempDir(), "missing"), "", time.Hour); err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,35 @@
+package extsort
+
+// Collect drains the output and error channels returned by a sorter into a slice.
+// It collects up to max records, or all records if max <= 0, and returns the first
+// error received on errCh. Both channels are watched at the same time, so an error
+// is returned as soon as it is delivered even if the output channel is still open.
+//
+// When max records have been collected, Collect returns without reading further and
+// the remaining records stay in ch; cancel the context passed to Sort to stop the
+// sorter and release its resources.
+func Collect[E any](ch <-chan E, errCh <-chan error, max int) ([]E, error) {
+	var result []E
+	for ch != nil || errCh != nil {
+		if max > 0 && len(result) >= max {
+			return result, nil
+		}
+		select {
+		case rec, ok := <-ch:
+			if !ok {
+				ch = nil
+				continue
+			}
+			result = append(result, rec)
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
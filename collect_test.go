@@ -0,0 +1,69 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestCollect(t *testing.T) {
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("Collect error: %v", err)
+	}
+	slices.Sort(data)
+	if !slices.Equal(result, data) {
+		t.Fatal("collected records do not match sorted input")
+	}
+}
+
+func TestCollectMax(t *testing.T) {
+	inputChan := make(chan int, 100)
+	for i := 99; i >= 0; i-- {
+		inputChan <- i
+	}
+	close(inputChan)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], nil)
+	sorter.Sort(ctx)
+
+	result, err := extsort.Collect(outChan, errChan, 10)
+	if err != nil {
+		t.Fatalf("Collect error: %v", err)
+	}
+	if want := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}; !slices.Equal(result, want) {
+		t.Fatalf("expected %v, got %v", want, result)
+	}
+}
+
+func TestCollectError(t *testing.T) {
+	errTest := errors.New("test error")
+	ch := make(chan int, 2)
+	errCh := make(chan error, 1)
+	ch <- 1
+	errCh <- errTest
+	// the output channel is left open, so the error must be observed directly
+
+	_, err := extsort.Collect(ch, errCh, 0)
+	if !errors.Is(err, errTest) {
+		t.Fatalf("expected test error, got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package extsort_test
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestCompact(t *testing.T) {
+	// three runs with overlapping keys; a run's version is its index plus one, and
+	// it deletes every key divisible by its delete stride
+	const keys = 400
+	runs := []struct{ keys, deleteStride int }{{keys, 0}, {keys / 2, 7}, {keys / 4, 5}}
+	var inputs []<-chan versioned
+	latest := make(map[uint32]versioned)
+	for i, run := range runs {
+		in := make(chan versioned, run.keys)
+		for key := uint32(0); key < uint32(run.keys); key++ {
+			v := versioned{key: key, version: uint32(i + 1), value: fmt.Sprintf("%d@%d", key, i+1)}
+			if run.deleteStride > 0 && key%uint32(run.deleteStride) == 0 {
+				v.value = "" // tombstone
+			}
+			in <- v
+			latest[key] = v
+		}
+		close(in)
+		inputs = append(inputs, in)
+	}
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 64
+	sorter, outChan, errChan := extsort.Compact(inputs, versionedFromBytes, versionedToBytes,
+		func(v versioned) []byte { return binary.BigEndian.AppendUint32(nil, v.key) },
+		func(v versioned) uint64 { return uint64(v.version) },
+		func(v versioned) bool { return v.value == "" },
+		config)
+	sorter.Sort(context.Background())
+
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	var expected []versioned
+	for key := uint32(0); key < keys; key++ {
+		if v := latest[key]; v.value != "" {
+			expected = append(expected, v)
+		}
+	}
+	if len(result) != len(expected) {
+		t.Fatalf("expected %d live keys, got %d", len(expected), len(result))
+	}
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Fatalf("record %d: expected %+v, got %+v", i, expected[i], result[i])
+		}
+	}
+}
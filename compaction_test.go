@@ -0,0 +1,71 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// versioned is a record with a key and the version of the value written for it.
+type versioned struct {
+	key     uint32
+	version uint32
+	value   string
+}
+
+func versionedToBytes(v versioned) ([]byte, error) {
+	b := binary.BigEndian.AppendUint32(nil, v.key)
+	b = binary.BigEndian.AppendUint32(b, v.version)
+	return append(b, v.value...), nil
+}
+
+func versionedFromBytes(d []byte) (versioned, error) {
+	if len(d) < 8 {
+		return versioned{}, errors.New("short versioned record")
+	}
+	return versioned{key: binary.BigEndian.Uint32(d), version: binary.BigEndian.Uint32(d[4:]), value: string(d[8:])}, nil
+}
+
+// TestCompaction compacts writes like an LSM tree: records are ordered by key and
+// then by descending version, read from the deserialized records during both the
+// chunk sorts and the merge, so keeping the first record of each key keeps its
+// latest write.
+func TestCompaction(t *testing.T) {
+	const keys, versions = 300, 5
+	inputChan := make(chan versioned, keys*versions)
+	for version := uint32(1); version <= versions; version++ {
+		for key := uint32(0); key < keys; key++ {
+			inputChan <- versioned{key: key, version: version, value: fmt.Sprintf("%d@%d", key, version)}
+		}
+	}
+	close(inputChan)
+
+	compareVersioned := func(a, b versioned) int {
+		return cmp.Or(cmp.Compare(a.key, b.key), cmp.Compare(b.version, a.version))
+	}
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	sorter, outChan, errChan := extsort.Generic(inputChan, versionedFromBytes, versionedToBytes, compareVersioned, config)
+	sorter.Sort(context.Background())
+	latest := extsort.UniqKeyChan(outChan, func(v versioned) []byte {
+		return binary.BigEndian.AppendUint32(nil, v.key)
+	}, nil)
+
+	result, err := extsort.Collect(latest, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if len(result) != keys {
+		t.Fatalf("expected %d compacted records, got %d", keys, len(result))
+	}
+	for i, v := range result {
+		if want := fmt.Sprintf("%d@%d", i, versions); v.key != uint32(i) || v.version != versions || v.value != want {
+			t.Fatalf("record %d: expected %q at version %d, got %+v", i, want, versions, v)
+		}
+	}
+}
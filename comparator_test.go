@@ -0,0 +1,64 @@
+package extsort_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// rankTable orders vals by a rank looked up for each key, standing in for a
+// comparator that depends on external state.
+type rankTable map[int]int
+
+func (r rankTable) Less(a, b extsort.SortType) bool {
+	return r[a.(val).Key] < r[b.(val).Key]
+}
+
+func TestNewWithComparator(t *testing.T) {
+	// reverse the keys through the table
+	const n = 500
+	table := make(rankTable, n)
+	for k := 0; k < n; k++ {
+		table[k] = n - k
+	}
+	inputChan := make(chan extsort.SortType, n)
+	for k := 0; k < n; k++ {
+		inputChan <- val{Key: (k * 7) % n, Order: k}
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 50
+	sorter, outChan, errChan := extsort.NewWithComparator(inputChan, fromBytesForTest, table, config)
+	sorter.Sort(context.Background())
+	want := n - 1
+	for rec := range outChan {
+		if got := rec.(val).Key; got != want {
+			t.Fatalf("expected key %d, got %d", want, got)
+		}
+		want--
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if want != -1 {
+		t.Fatalf("expected %d records, got %d", n, n-1-want)
+	}
+}
+
+func TestNewWithComparatorNil(t *testing.T) {
+	inputChan := make(chan extsort.SortType)
+	close(inputChan)
+	sorter, outChan, errChan := extsort.NewWithComparator(inputChan, fromBytesForTest, nil, nil)
+	if sorter != nil {
+		t.Fatal("expected a nil sorter for a nil comparator")
+	}
+	for range outChan {
+	}
+	var configErr *extsort.ConfigError
+	if err := <-errChan; !errors.As(err, &configErr) || configErr.Field != "comparator" {
+		t.Fatalf("expected ConfigError for comparator, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package extsort
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// CountingCompare wraps compareFunc so that every invocation increments a counter.
+// It returns the wrapped function along with the counter, which may be read at any
+// time, including while a sort is running. This is useful when profiling to attribute
+// CPU time to comparisons. The counter is shared by all sort workers, so the count
+// includes comparisons made while sorting chunks and while merging them.
+func CountingCompare[E any](compareFunc CompareGeneric[E]) (CompareGeneric[E], *atomic.Int64) {
+	var count atomic.Int64
+	return func(a, b E) int {
+		count.Add(1)
+		return compareFunc(a, b)
+	}, &count
+}
+
+// compareFailure carries an error reported by a comparator wrapped with FallibleCompare.
+// It is raised as a panic from inside the sort and recovered into a returned error.
+type compareFailure struct {
+	err error
+}
+
+// FallibleCompare adapts a comparator that can fail into a CompareGeneric for use
+// with any sorter. When compareFunc returns an error, the sort is aborted and the
+// error is delivered on the error channel, wrapped with both operands. This is useful
+// when the ordering depends on data that might be malformed, such as unparseable keys.
+// Comparators that cannot fail should be passed directly, which avoids the overhead.
+func FallibleCompare[E any](compareFunc func(a, b E) (int, error)) CompareGeneric[E] {
+	return func(a, b E) int {
+		c, err := compareFunc(a, b)
+		if err != nil {
+			panic(compareFailure{fmt.Errorf("comparing %v and %v: %w", a, b, err)})
+		}
+		return c
+	}
+}
+
+// recoverCompareFailure recovers a panic raised by a FallibleCompare comparator and
+// stores its error in err. Any other panic is propagated.
+func recoverCompareFailure(err *error) {
+	if r := recover(); r != nil {
+		failure, ok := r.(compareFailure)
+		if !ok {
+			panic(r)
+		}
+		*err = failure.err
+	}
+}
+
+// VersionCompare compares version strings such as "1.2.10" and "v2.0.0-rc1", for use
+// with StringsFunc. The strings are compared from the start, treating every run of
+// decimal digits as a number and comparing every other byte as is, so that "1.2.9"
+// sorts before "1.2.10" and "a9b" before "a10b". A version that extends another
+// sorts after it, so "1.2" comes before "1.2.0", and unlike in semantic versioning
+// "1.2.0" comes before "1.2.0-rc1". Numbers that differ only in leading
+// zeros are equal at first, and such strings are finally ordered by strings.Compare,
+// making the order total. As separators are compared by byte value, a "-" suffix
+// sorts before a "." component: "1.2-rc1" comes before "1.2.0".
+func VersionCompare(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return cmp.Compare(a[i], b[j])
+			}
+			i++
+			j++
+			continue
+		}
+		startA, startB := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		numA := strings.TrimLeft(a[startA:i], "0")
+		numB := strings.TrimLeft(b[startB:j], "0")
+		// without leading zeros, a longer number is larger
+		if c := cmp.Compare(len(numA), len(numB)); c != 0 {
+			return c
+		}
+		if c := strings.Compare(numA, numB); c != 0 {
+			return c
+		}
+	}
+	if c := cmp.Compare(len(a)-i, len(b)-j); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// isDigit reports whether c is an ASCII decimal digit.
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
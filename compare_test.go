@@ -0,0 +1,238 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestCountingCompare(t *testing.T) {
+	compare, count := extsort.CountingCompare(cmp.Compare[int])
+	if got := count.Load(); got != 0 {
+		t.Fatalf("expected 0 comparisons before use, got %d", got)
+	}
+	if compare(1, 2) >= 0 || compare(2, 1) <= 0 || compare(3, 3) != 0 {
+		t.Fatal("wrapped comparator returned wrong results")
+	}
+	if got := count.Load(); got != 3 {
+		t.Fatalf("expected 3 comparisons, got %d", got)
+	}
+}
+
+func TestCountingCompareSort(t *testing.T) {
+	data := generateRandomInts(5000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	compare, count := extsort.CountingCompare(cmp.Compare[int])
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 1000
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, compare, config)
+	sorter.Sort(context.Background())
+	for range outChan {
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	// at least one comparison per record is needed to sort and merge
+	if got := count.Load(); got < int64(len(data)) {
+		t.Errorf("expected at least %d comparisons, got %d", len(data), got)
+	}
+}
+
+// errUnparseable is reported by parseCompare for records it cannot order.
+var errUnparseable = errors.New("unparseable key")
+
+// parseCompare orders ints but fails on negative values.
+func parseCompare(a, b int) (int, error) {
+	if a < 0 || b < 0 {
+		return 0, errUnparseable
+	}
+	return cmp.Compare(a, b), nil
+}
+
+func TestFallibleCompare(t *testing.T) {
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, extsort.FallibleCompare(parseCompare), config)
+	sorter.Sort(context.Background())
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if !slices.IsSorted(result) || len(result) != len(data) {
+		t.Fatal("expected all records in sorted order")
+	}
+}
+
+func TestFallibleCompareError(t *testing.T) {
+	for _, chunkSize := range []int{10, 1000} {
+		inputChan := make(chan int, 101)
+		for i := 0; i < 100; i++ {
+			inputChan <- i
+		}
+		inputChan <- -7
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, extsort.FallibleCompare(parseCompare), config)
+		sorter.Sort(context.Background())
+		_, err := extsort.Collect(outChan, errChan, 0)
+		if !errors.Is(err, errUnparseable) {
+			t.Fatalf("chunk size %d: expected errUnparseable, got %v", chunkSize, err)
+		}
+		if !strings.Contains(err.Error(), "-7") {
+			t.Errorf("chunk size %d: expected error to identify the operands: %v", chunkSize, err)
+		}
+	}
+}
+
+// TestFallibleCompareMergeError verifies that an error raised while merging chunks,
+// rather than while sorting them, is reported instead of crashing.
+func TestFallibleCompareMergeError(t *testing.T) {
+	for _, numWorkers := range []int{2, 20} {
+		inputChan := make(chan int, 100)
+		for i := 0; i < 100; i++ {
+			inputChan <- i
+		}
+		close(inputChan)
+
+		// fail only when comparing records from different chunks of ten
+		compare := func(a, b int) (int, error) {
+			if a/10 != b/10 && (a == 55 || b == 55) {
+				return 0, errUnparseable
+			}
+			return cmp.Compare(a, b), nil
+		}
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 10
+		config.NumWorkers = numWorkers
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, extsort.FallibleCompare(compare), config)
+		sorter.Sort(context.Background())
+		_, err := extsort.Collect(outChan, errChan, 0)
+		if !errors.Is(err, errUnparseable) {
+			t.Fatalf("workers %d: expected errUnparseable, got %v", numWorkers, err)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.9", "1.2.10", -1},
+		{"1.2.10", "1.2.10", 0},
+		{"1.10", "1.9.9", 1},
+		{"2", "10", -1},
+		{"1.2", "1.2.0", -1},
+		{"1.2.0", "1.2.0.0", -1},
+		{"a9b", "a10b", -1},
+		{"v1.2.3", "v1.2.12", -1},
+		{"1.2-rc1", "1.2.0", -1},
+		{"1.2.0-rc2", "1.2.0-rc10", -1},
+		{"1.2.0-alpha", "1.2.0-beta", -1},
+		{"1.2a", "1.2b", -1},
+		{"1.2a", "1.10", -1},
+		{"1.02", "1.2", -1}, // equal numbers, ordered by bytes
+		{"1.02", "1.3", -1},
+		{"007", "7", -1},
+		{"18446744073709551616", "18446744073709551615", 1}, // beyond uint64
+		{"", "0", -1},
+		{"", "", 0},
+		{"lib-2.0", "lib-10.0", -1},
+		{"release", "release1", -1},
+	} {
+		if got := extsort.VersionCompare(tc.a, tc.b); got != tc.want {
+			t.Errorf("VersionCompare(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+		if got := extsort.VersionCompare(tc.b, tc.a); got != -tc.want {
+			t.Errorf("VersionCompare(%q, %q) = %d, want %d", tc.b, tc.a, got, -tc.want)
+		}
+	}
+}
+
+func TestStringsFuncVersions(t *testing.T) {
+	versions := []string{"1.10.0", "1.2.10", "0.9", "1.2.9", "1.2", "1.2.9-rc1", "1.2.0", "10.0", "2.0"}
+	inputChan := make(chan string, len(versions))
+	for _, v := range versions {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 3
+	sorter, outChan, errChan := extsort.StringsFunc(inputChan, extsort.VersionCompare, config)
+	sorter.Sort(context.Background())
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	expected := []string{"0.9", "1.2", "1.2.0", "1.2.9", "1.2.9-rc1", "1.2.10", "1.10.0", "2.0", "10.0"}
+	if !slices.Equal(result, expected) {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+}
+
+// TestLegacyCompareCalls verifies that a sort through the legacy New API calls the
+// less function once per comparison, as often as a native compare function is
+// called to sort the same records.
+func TestLegacyCompareCalls(t *testing.T) {
+	const n = 2000
+	keys := rand.Perm(n)
+	input := func() chan extsort.SortType {
+		inputChan := make(chan extsort.SortType, n)
+		for i, k := range keys {
+			inputChan <- val{Key: k, Order: i}
+		}
+		close(inputChan)
+		return inputChan
+	}
+	config := extsort.DefaultConfig()
+	config.ChunkSize = n / 4
+
+	compare, compares := extsort.CountingCompare(func(a, b extsort.SortType) int {
+		return cmp.Compare(a.(val).Key, b.(val).Key)
+	})
+	fromBytes := func(b []byte) (extsort.SortType, error) { return fromBytesForTest(b), nil }
+	toBytes := func(r extsort.SortType) ([]byte, error) { return r.ToBytes(), nil }
+	sorter, outChan, errChan := extsort.Generic(input(), fromBytes, toBytes, compare, config)
+	sorter.Sort(context.Background())
+	if _, err := extsort.Collect(outChan, errChan, 0); err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+
+	var lessCalls atomic.Int64
+	less := func(a, b extsort.SortType) bool {
+		lessCalls.Add(1)
+		return KeyLessThan(a, b)
+	}
+	legacy, legacyOut, legacyErr := extsort.New(input(), fromBytesForTest, less, config)
+	legacy.Sort(context.Background())
+	if _, err := extsort.Collect(legacyOut, legacyErr, 0); err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+
+	if lessCalls.Load() != compares.Load() {
+		t.Errorf("expected %d calls to the less function, got %d", compares.Load(), lessCalls.Load())
+	}
+}
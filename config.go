@@ -1,5 +1,14 @@
 package extsort
 
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/lanrat/extsort/tempfile"
+)
+
 // Config holds configuration settings for external sorting operations.
 // All fields have sensible defaults and can be left as zero values to use defaults.
 type Config struct {
@@ -9,10 +18,28 @@ type Config struct {
 	ChunkSize int
 
 	// NumWorkers controls the maximum number of goroutines used for parallel
-	// chunk sorting and merging. More workers can improve CPU utilization on multi-core systems.
+	// merging, and for chunk sorting unless ChunkSortParallelism is set.
+	// More workers can improve CPU utilization on multi-core systems.
 	// Default: 2 workers. Must be > 1.
 	NumWorkers int
 
+	// ChunkSortParallelism sets the number of goroutines sorting chunks in memory,
+	// separately from NumWorkers. Chunk sorting is CPU bound, while the merge that
+	// NumWorkers also controls mostly waits on I/O, so the two may be tuned
+	// independently, for example matching this to the available cores. Every sorting
+	// goroutine holds a chunk, so memory use grows with ChunkSortParallelism*ChunkSize.
+	// Chunks are written to temporary storage by a single goroutine regardless.
+	// Default: 0 (NumWorkers).
+	ChunkSortParallelism int
+
+	// MaxPendingChunks is the number of sorted chunks that may wait to be written to
+	// temporary storage. Chunks are always written by a background goroutine while
+	// input keeps being read and sorted; this bounds how far reading and sorting may
+	// run ahead of a slow disk before they wait for it. Every pending chunk holds up
+	// to ChunkSize records in memory. Must be >= 0.
+	// Default: 0 (NumWorkers*2).
+	MaxPendingChunks int
+
 	// ChanBuffSize sets the buffer size for internal channels used during chunk merging.
 	// Larger buffers can improve throughput but use more memory.
 	// Default: 1. Must be >= 0.
@@ -23,6 +50,16 @@ type Config struct {
 	// Default: 1000. Must be >= 0.
 	SortedChanBuffSize int
 
+	// OutputBufferBytes bounds the serialized size of the sorted records waiting to be
+	// received from the output channel, rather than only their number. Each record is
+	// charged the size returned by its ToBytes function, computed once more as it is
+	// emitted, and a record larger than the bound waits until nothing else is waiting.
+	// When set, the output channel itself is unbuffered and records wait in a queue in
+	// front of it, which still holds at most SortedChanBuffSize records.
+	// ConsumerTimeout then applies to waiting for room in the bound.
+	// Default: 0 (the output is bounded by SortedChanBuffSize only). Must be >= 0.
+	OutputBufferBytes int
+
 	// TempFilesDir specifies the directory for temporary files during sorting.
 	// When empty (default), the library uses intelligent directory selection that
 	// prefers disk-backed locations over potentially memory-backed filesystems
@@ -36,8 +73,355 @@ type Config struct {
 	//
 	// Default: "" (intelligent selection).
 	TempFilesDir string
+
+	// TempFilesDirs spreads temporary data across several directories, such as mount
+	// points on different disks. One temporary file is created in each directory and
+	// sorted chunks are written to them one at a time in round-robin order, so the
+	// spill load is shared between the disks and the merge reads from all of them.
+	// When set, it takes precedence over TempFilesDir.
+	// Default: nil (use TempFilesDir).
+	TempFilesDirs []string
+
+	// Logger receives structured log events at key points of the sort, such as
+	// chunks being spilled to disk (debug), the merge starting and finishing (info),
+	// and errors (error). When nil, no logging is performed.
+	// Default: nil.
+	Logger *slog.Logger
+
+	// JobID tags a sort for tracing. When set, every log event written to Logger
+	// includes it as the "job" attribute, allowing the events of concurrent sorts
+	// to be correlated.
+	// Default: "" (no tag).
+	JobID string
+
+	// MaxComparisons limits the number of comparisons allowed while sorting a single
+	// chunk. When exceeded, the sort aborts with ErrComparatorBudgetExceeded. This guards
+	// against buggy comparators (for example, non-transitive ones) that would otherwise
+	// make sorting take unreasonably long. A correct comparator needs roughly
+	// ChunkSize*log2(ChunkSize) comparisons per chunk, so set this well above that.
+	// Counting comparisons adds overhead, so it is disabled by default.
+	// Default: 0 (disabled).
+	MaxComparisons int
+
+	// MaxCompareTime limits the total time spent in the compare function while sorting
+	// chunks and merging them, summed over all workers. When exceeded, the sort aborts
+	// with ErrCompareTimeExceeded. Unlike MaxDuration, this isolates the cost of the
+	// comparator, helping to identify a pathologically slow one. To keep the overhead
+	// low, only a sample of comparisons is timed, so the total is an estimate.
+	// Default: 0 (no limit).
+	MaxCompareTime time.Duration
+
+	// NoDrainOnAbort stops a sort that stops early, because of an error or because
+	// its context is done, from reading and discarding its input in the background
+	// until the input channel is closed. By default producers blocked sending to the
+	// input finish instead of blocking forever, at the cost of the CPU spent producing
+	// records that are thrown away. With NoDrainOnAbort, producers must watch the
+	// context or the error channel themselves to know when to stop. Errors during the
+	// merge happen once the input has been fully read, so they leave nothing to drain.
+	// Default: false (the input is drained).
+	NoDrainOnAbort bool
+
+	// OnNilItem controls what happens when a nil interface value is received on the
+	// input channel, which would otherwise fail during serialization. It only applies
+	// when the sorted type is an interface type, such as SortType.
+	// Default: NilItemError.
+	OnNilItem NilItemPolicy
+
+	// OutputRateLimit caps the rate, in records per second, at which sorted records
+	// are sent on the output channel. This is useful when the output feeds a
+	// rate-limited sink and prevents unbounded buffering downstream. Sends are
+	// throttled with a token bucket that allows bursts of up to 100ms worth of records.
+	// Context cancellation is honored while waiting.
+	// Default: 0 (unlimited).
+	OutputRateLimit float64
+
+	// ConsumerTimeout aborts the sort with ErrConsumerStalled when sending a single
+	// record on the output channel blocks for longer than this duration. Without it,
+	// a consumer that stops reading without cancelling the context leaves the sorter
+	// blocked forever. Normal backpressure is unaffected as long as the consumer reads
+	// at least one record within the timeout; the output channel buffer
+	// (SortedChanBuffSize) absorbs short pauses. Time spent waiting on
+	// OutputRateLimit is not counted.
+	// Default: 0 (wait indefinitely).
+	ConsumerTimeout time.Duration
+
+	// Stable makes the sort stable: records that compare equal are emitted in the order
+	// they were read from the input. Chunks are sorted with slices.SortStableFunc, and
+	// the merge always takes equal records from the chunk read earliest. Stable sorting
+	// of chunks is somewhat slower than the default unstable sort.
+	// Default: false.
+	Stable bool
+
+	// Descending hints that the input mostly arrives in descending order. Each chunk
+	// is then checked with a single linear pass and, when strictly descending, reversed
+	// in place instead of sorted. The output is ascending either way.
+	// Default: false.
+	Descending bool
+
+	// MaxDuration limits the total wall-clock time of a sort, measured from the call
+	// to Sort until the last record is emitted. When exceeded, the sort stops, its
+	// temporary file is released, and ErrTimeout is delivered on the error channel.
+	// Records already emitted are not retracted; the output simply ends early.
+	// This is a convenience over a context deadline that reports a distinct error.
+	// Default: 0 (no limit).
+	MaxDuration time.Duration
+
+	// ChunkTransformWrite and ChunkTransformRead rewrite the serialized bytes of each
+	// chunk spilled to temporary storage, allowing a custom on-disk encoding such as
+	// compression or encryption. Each chunk is serialized in blocks of about 64KB:
+	// ChunkTransformWrite receives one block at a time before it is written, and
+	// ChunkTransformRead must return exactly that block when given its output. Blocks
+	// end on record boundaries, so a record larger than a block is transformed whole.
+	// Both must be set together. The merge decodes one block of each chunk at a time,
+	// so its memory use grows with the number of chunks but not with ChunkSize.
+	// Default: nil (chunks are written as-is).
+	ChunkTransformWrite func([]byte) ([]byte, error)
+	ChunkTransformRead  func([]byte) ([]byte, error)
+
+	// MergeBufferBytes caps the total size of the read buffers held by the merge,
+	// which otherwise uses a fixed buffer per chunk (tempfile.BufferSize). The cap is
+	// divided evenly among the chunks being merged, so a large number of chunks reads
+	// ahead less. Records larger than a buffer are still read, into an allocation of
+	// exactly their size, so the bound excludes the records currently being merged.
+	// With ChunkTransformRead, each chunk also holds one encoded and one decoded block
+	// of about 64KB, which the bound excludes too.
+	// Default: 0 (tempfile.BufferSize per chunk).
+	MergeBufferBytes int
+
+	// MergeStrategy selects how the next record is picked while merging sorted
+	// runs: MergeHeap keeps the runs in a binary heap, MergeLoserTree in a
+	// tournament tree that needs fewer comparisons per record at high fan-in.
+	// Both produce the same output, including the order of equal records.
+	// Default: MergeHeap.
+	MergeStrategy MergeStrategy
+
+	// MaxLineSize is the length of the longest line accepted by SortLines, in bytes.
+	// Longer lines abort the sort with bufio.ErrTooLong.
+	// Default: 0 (bufio.MaxScanTokenSize, 64KB).
+	MaxLineSize int
+
+	// TempFileID generates the names of temporary files in place of the default
+	// process id and random suffix, for example to qualify them with the host name
+	// when TempFilesDir is on storage shared by several machines. It is called once
+	// for every temporary file and must return a name that is unique in the
+	// directory; creating a file that already exists fails the sort.
+	// Default: nil (process id and random suffix).
+	TempFileID func() string
+
+	// AllowMemoryFallback lets a sort proceed when its temporary file cannot be
+	// created, for example because TempFilesDir is full or read-only, as long as the
+	// input fits in a single chunk of ChunkSize records and so is sorted entirely in
+	// memory. If the input turns out to be larger, the sort fails with the original
+	// temporary file error once the first chunk is full.
+	// Default: false (fail immediately).
+	AllowMemoryFallback bool
+
+	// Quantiles lists quantiles, each in [0, 1], whose exact values are captured
+	// while the sorted output is emitted and returned by GenericSorter.Quantiles.
+	// The value at quantile q is the record at nearest rank ceil(q*n) of the n records
+	// sorted, so 0 selects the smallest record and 1 the largest. The total number of
+	// records is known once the input channel is closed, before any output is sent,
+	// so no second pass is needed; the input must therefore be finite.
+	// Default: nil (no quantiles).
+	Quantiles []float64
+
+	// AtomicOutput makes SortToFile write to a temporary file next to the output
+	// path and rename it into place only once it is complete, so the output path
+	// either holds the previous file or the complete new one, never a partial write.
+	// On failure the temporary file is removed, making a failed run safe to retry.
+	// The output gets the same permissions as when written in place, and its directory
+	// is synced after the rename so that the new file survives a crash.
+	// Default: false (the output path is truncated and written in place).
+	AtomicOutput bool
+
+	// EmitBloom makes SortToFile write a bloom filter of the keys of all records next
+	// to the sorted file, at its path with BloomFileSuffix appended, so consumers can
+	// test whether a key may be present with OpenBloomFilter without scanning the
+	// file. Keys are added as records are written, and the filter is sized for the
+	// number of records read, which is known before the first record is written.
+	// With AtomicOutput, the filter is renamed into place before the sorted file.
+	// Default: false.
+	EmitBloom bool
+
+	// BloomFalsePositiveRate is the target probability that the filter written with
+	// EmitBloom reports a key that is not present. Lower rates need more space: about
+	// 10 bits per record at 0.01, and 4.8 more for every tenfold reduction.
+	// Default: 0 (0.01). Must be in [0, 1).
+	BloomFalsePositiveRate float64
+
+	// BloomKey returns the key added to the filter written with EmitBloom for a
+	// record, given the record as serialized by its ToBytes function. The returned
+	// slice is not retained. Lookups with BloomFilter.MayContain must pass keys in
+	// the same form.
+	// Default: nil (the whole serialized record is the key).
+	BloomKey func(raw []byte) []byte
+
+	// Heartbeat is called every HeartbeatInterval while chunks are being merged,
+	// whether or not records are being emitted, to signal that a quiet sort is still
+	// alive, for example to a watchdog. It is called from its own goroutine, never
+	// after the merge has finished, and must not block for long.
+	// Default: nil (no heartbeat).
+	Heartbeat func()
+
+	// HeartbeatInterval is the time between calls to Heartbeat.
+	// Default: 0 (one second).
+	HeartbeatInterval time.Duration
+
+	// MaxRunsBeforeMerge bounds the number of sorted chunks (runs) held in temporary
+	// storage while the input is still being read. Once that many runs have been
+	// spilled, they are merged into a single run in a new temporary file and the old
+	// file is released, before any further chunks are written. This keeps the number of
+	// runs, and so the fan-in of the final merge, bounded for very large streaming inputs,
+	// at the cost of rewriting the merged records. The ids passed to the
+	// SetOnChunkSpilled callback refer to the current temporary file, so after a merge
+	// they start again from 1, the merged run being 0. It cannot be combined with
+	// ChunkTransformWrite. MergeFiles also merges at most this many files at once.
+	// Must be 0 or >= 2.
+	// Default: 0 (no intermediate merges; MergeFiles merges up to 64 files at once).
+	MaxRunsBeforeMerge int
+
+	// ReuseTempFiles makes the intermediate merges triggered by MaxRunsBeforeMerge or
+	// Limit reuse temporary files rather than create a new one for every merge. The
+	// file holding the runs just merged is truncated and kept as a spare, and the
+	// next merge is written to it, so at most two temporary files are ever created.
+	// This helps where creating files is expensive or limited by quota. Reuse
+	// requires a single temporary directory; with TempFilesDirs, files are created
+	// for every merge as usual.
+	// Default: false.
+	ReuseTempFiles bool
+
+	// VerifyDeterministicSerialization is a debugging aid that serializes a sample
+	// of the records written to temporary storage a second time, failing the sort with
+	// ErrNondeterministicSerialization if the two encodings differ. This catches
+	// serializers whose output varies for the same record, for example by iterating
+	// over a map. The first record of every chunk and every 1024th record after it are
+	// checked, so the overhead is small. Inputs sorted entirely in memory are never
+	// serialized and so are not checked.
+	// Default: false.
+	VerifyDeterministicSerialization bool
+
+	// BufferPool supplies the I/O buffers of temporary files, each of
+	// tempfile.BufferSize bytes, and takes them back once a sort releases its
+	// temporary file. Buffers are reused by later sorts instead of being allocated
+	// again, reducing GC pressure when many sorts run. A pool may be shared by any
+	// number of concurrent sorts; a separate pool keeps a group of sorts from
+	// retaining buffers for the others. Read buffers sized by MergeBufferBytes are
+	// not pooled.
+	// Default: nil (a pool shared by the whole process).
+	BufferPool *tempfile.BufferPool
+
+	// Limit keeps only the first Limit records of the sorted output, an external
+	// top-K. Each sorted chunk is cut down to its first Limit records before it is
+	// spilled, and unless MaxRunsBeforeMerge is set, spilled runs are merged and cut
+	// down again as soon as there are two of them. Temporary storage therefore stays
+	// proportional to Limit rather than to the size of the input. It cannot be
+	// combined with Quantiles or SetKeyRange. Must be >= 0.
+	// Default: 0 (no limit).
+	Limit int
+
+	// ProgressInterval is the time between the snapshots sent on the channel
+	// returned by GenericSorter.Progress.
+	// Default: 0 (one second).
+	ProgressInterval time.Duration
+
+	// ExpectedCount, when set, is the number of records the input is expected to
+	// contain. It is advisory: it is reported as Progress.ExpectedRecords until the
+	// input has been read, and used to pre-size the first chunk, but a wrong count
+	// does not affect the sorted output. Must be >= 0.
+	// Default: 0 (unknown).
+	ExpectedCount int
+
+	// ChunkSortSemaphore, when set, bounds the number of chunks sorted at once by
+	// this sort together with every other sort using the same semaphore, so that
+	// many concurrent sorts do not oversubscribe the CPU. It does not bound the
+	// goroutines of the sort: merges, saves and temporary file I/O are not limited.
+	// Default: nil (only ChunkSortParallelism bounds chunk sorting).
+	ChunkSortSemaphore *ChunkSortSemaphore
+
+	// WriteRetries is the number of times a read or write of the temporary files
+	// that fails with a transient error, one reporting itself as temporary or as a
+	// timeout such as EAGAIN or ETIMEDOUT, is retried before the sort fails. This
+	// helps on network or cloud storage that fails intermittently. Other errors,
+	// such as permission denied or EIO, fail the sort immediately, and the final
+	// fsync of a temporary file is never retried. Must be >= 0.
+	// Default: 0 (no retries).
+	WriteRetries int
+
+	// RetryBackoff is the wait before the first retry selected by WriteRetries.
+	// Each later retry of the same operation waits twice as long as the one before,
+	// up to a minute. Cancelling the sort ends the wait and fails the operation.
+	// Default: 0 (10ms).
+	RetryBackoff time.Duration
 }
 
+// mergeMemoryShare is the share of the memory given to RecommendChunkSize that is
+// left for the merge read buffers and output channels, as 1/mergeMemoryShare.
+const mergeMemoryShare = 8
+
+// RecommendChunkSize returns a ChunkSize that keeps the chunks a sort holds in
+// memory within memBytes, for records taking avgRecordBytes each in memory and a
+// sort using numWorkers workers with otherwise default settings. At most
+// 2+ChanBuffSize+3*numWorkers chunks are held at once: the one being filled, the
+// ChanBuffSize chunks queued for sorting, one per sorting worker, the 2*numWorkers
+// queued to be written and the one being written. An eighth of memBytes is left
+// for the merge; setting MergeBufferBytes to memBytes/8 keeps the merge within it.
+// The result is at least 2, the smallest valid ChunkSize.
+func RecommendChunkSize(memBytes int64, avgRecordBytes int, numWorkers int) int {
+	d := DefaultConfig()
+	if numWorkers < 1 {
+		numWorkers = d.NumWorkers
+	}
+	chunks := int64(2 + d.ChanBuffSize + 3*numWorkers)
+	size := (memBytes - memBytes/mergeMemoryShare) / chunks / int64(max(avgRecordBytes, 1))
+	return int(min(max(size, 2), math.MaxInt))
+}
+
+// bloomFalsePositiveRate returns the false-positive rate of the filter written with
+// EmitBloom.
+func (c *Config) bloomFalsePositiveRate() float64 {
+	if c.BloomFalsePositiveRate == 0 {
+		return defaultBloomFalsePositiveRate
+	}
+	return c.BloomFalsePositiveRate
+}
+
+// bloomKey returns the key of the serialized record raw for the filter written with
+// EmitBloom.
+func (c *Config) bloomKey(raw []byte) []byte {
+	if c.BloomKey == nil {
+		return raw
+	}
+	return c.BloomKey(raw)
+}
+
+// tempFileOptions returns the tempfile options selected by the config. Retries
+// stop waiting once ctx is done.
+func (c *Config) tempFileOptions(ctx context.Context) []tempfile.Option {
+	var opts []tempfile.Option
+	if c.TempFileID != nil {
+		opts = append(opts, tempfile.WithFileID(c.TempFileID))
+	}
+	if c.BufferPool != nil {
+		opts = append(opts, tempfile.WithBufferPool(c.BufferPool))
+	}
+	if c.WriteRetries > 0 {
+		opts = append(opts, tempfile.WithRetry(c.WriteRetries, c.RetryBackoff), tempfile.WithContext(ctx))
+	}
+	return opts
+}
+
+// NilItemPolicy defines how a sorter handles nil items received on its input channel.
+type NilItemPolicy int
+
+const (
+	// NilItemError aborts the sort with ErrNilItem.
+	NilItemError NilItemPolicy = iota
+	// NilItemSkip silently drops nil items.
+	NilItemSkip
+	// NilItemPanic panics with ErrNilItem.
+	NilItemPanic
+)
+
 // DefaultConfig returns a Config with sensible default values optimized for
 // general-purpose external sorting. These defaults balance memory usage,
 // I/O efficiency, and parallelism for typical workloads.
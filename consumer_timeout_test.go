@@ -0,0 +1,76 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestConsumerTimeout verifies that a consumer that stops reading aborts the sort
+// for both the single-chunk and the merge output paths.
+func TestConsumerTimeout(t *testing.T) {
+	for _, chunkSize := range []int{100, 10000} {
+		data := generateRandomInts(1000)
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+		config.SortedChanBuffSize = 10
+		config.ConsumerTimeout = 50 * time.Millisecond
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+
+		// read a few records then stall
+		for i := 0; i < 5; i++ {
+			<-outChan
+		}
+		select {
+		case err := <-errChan:
+			if !errors.Is(err, extsort.ErrConsumerStalled) {
+				t.Fatalf("chunk size %d: expected ErrConsumerStalled, got %v", chunkSize, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("chunk size %d: sort did not abort for a stalled consumer", chunkSize)
+		}
+		for range outChan {
+		}
+	}
+}
+
+// TestConsumerTimeoutSlowConsumer verifies that a consumer reading within the
+// timeout is not treated as stalled.
+func TestConsumerTimeoutSlowConsumer(t *testing.T) {
+	data := generateRandomInts(50)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.SortedChanBuffSize = 0
+	config.ConsumerTimeout = time.Second
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	count := 0
+	for range outChan {
+		time.Sleep(time.Millisecond)
+		count++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if count != len(data) {
+		t.Fatalf("expected %d records, got %d", len(data), count)
+	}
+}
@@ -0,0 +1,115 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// sortIntsCounting sorts data with the given chunk size and Config.Descending and
+// returns the sorted output along with the number of comparisons performed.
+func sortIntsCounting(t *testing.T, data []int, chunkSize int, descending bool) ([]int, int64) {
+	t.Helper()
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	var comparisons int64
+	compare := func(a, b int) int {
+		atomic.AddInt64(&comparisons, 1)
+		return cmp.Compare(a, b)
+	}
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = chunkSize
+	config.Descending = descending
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, compare, config)
+	sorter.Sort(context.Background())
+
+	result := make([]int, 0, len(data))
+	for v := range outChan {
+		result = append(result, v)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	return result, atomic.LoadInt64(&comparisons)
+}
+
+// TestDescendingChunkReversed verifies that a fully descending chunk is reversed
+// with a single linear scan instead of being sorted.
+func TestDescendingChunkReversed(t *testing.T) {
+	const n = 1000
+	data := make([]int, n)
+	for i := range data {
+		data[i] = n - i
+	}
+
+	result, comparisons := sortIntsCounting(t, data, n, true)
+	for i, v := range result {
+		if v != i+1 {
+			t.Fatalf("expected %d at position %d, got %d", i+1, i, v)
+		}
+	}
+	if len(result) != n {
+		t.Fatalf("expected %d records, got %d", n, len(result))
+	}
+	if comparisons != n-1 {
+		t.Errorf("expected %d comparisons for a descending chunk, got %d", n-1, comparisons)
+	}
+}
+
+// TestDescendingChunksMixed verifies correct output when some chunks are
+// descending and others are not, including descending runs with duplicates.
+func TestDescendingChunksMixed(t *testing.T) {
+	data := []int{
+		9, 8, 7, 6, 5, // descending chunk
+		1, 4, 2, 3, 0, // unordered chunk
+		5, 5, 4, 3, 2, // descending with duplicates, must be sorted
+		14, 13, 12, 11, 10, // descending chunk
+	}
+
+	for _, descending := range []bool{true, false} {
+		result, _ := sortIntsCounting(t, data, 5, descending)
+		if len(result) != len(data) {
+			t.Fatalf("Descending %v: expected %d records, got %d", descending, len(data), len(result))
+		}
+		for i := 1; i < len(result); i++ {
+			if result[i-1] > result[i] {
+				t.Fatalf("Descending %v: output not sorted at %d: %v", descending, i, result)
+			}
+		}
+	}
+}
+
+// TestDescendingEqualRecordsLegacy verifies that a chunk of equal records is not
+// mistaken for a descending one through the legacy New API.
+func TestDescendingEqualRecordsLegacy(t *testing.T) {
+	const n = 10
+	inputChan := make(chan extsort.SortType, n)
+	for i := 0; i < n; i++ {
+		inputChan <- val{Key: 1, Order: i}
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = n
+	config.Descending = true
+	config.Stable = true
+	sorter, outChan, errChan := extsort.New(inputChan, fromBytesForTest, KeyLessThan, config)
+	sorter.Sort(context.Background())
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	for i, r := range result {
+		if got := r.(val).Order; got != i {
+			t.Fatalf("position %d holds record %d", i, got)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+)
+
+// Entry is a single item reported by Entries, tagged with the stream it came from.
+type Entry[T any] struct {
+	// D is OLD if the item exists only in stream A, NEW if only in stream B,
+	// and BOTH if it exists in both
+	D Delta
+	// V is the item; for BOTH it is the item read from stream A
+	V T
+}
+
+// Entries walks two sorted channels like Generic, but reports every item on the
+// returned channel instead of only the differences: items found in both streams
+// are sent once, tagged BOTH, so that the output is the sorted union of the two
+// streams. Duplicates are matched one for one, so an item that appears twice in A
+// and three times in B is reported twice as BOTH and once as NEW.
+//
+// The entry channel is closed once both streams are exhausted or an error occurs.
+// The error channel then receives the first error, if any, and is closed. The
+// caller must drain the entry channel or cancel ctx.
+func Entries[T any](ctx context.Context, aChan, bChan <-chan T, aErrChan, bErrChan <-chan error, compareFunc CompareFunc[T]) (<-chan Entry[T], <-chan error) {
+	out := make(chan Entry[T], 1)
+	errChan := make(chan error, 1)
+	if ctx == nil || aChan == nil || bChan == nil || aErrChan == nil || bErrChan == nil || compareFunc == nil {
+		close(out)
+		errChan <- fmt.Errorf("arguments must not be nil")
+		close(errChan)
+		return out, errChan
+	}
+
+	d := differ[T]{
+		ctx:          ctx,
+		aChan:        aChan,
+		aErrChan:     aErrChan,
+		bChan:        bChan,
+		bErrChan:     bErrChan,
+		compare:      compareFunc,
+		reportCommon: true,
+		resultFunc: func(delta Delta, v T) error {
+			select {
+			case out <- Entry[T]{D: delta, V: v}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+	go func() {
+		defer close(errChan)
+		_, err := d.diff()
+		close(out)
+		if err != nil {
+			errChan <- err
+		}
+	}()
+	return out, errChan
+}
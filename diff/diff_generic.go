@@ -14,6 +14,7 @@ type differ[T any] struct {
 	aErrChan, bErrChan <-chan error
 	resultFunc         ResultFunc[T]
 	compare            CompareFunc[T]
+	reportCommon       bool // call resultFunc with BOTH for items in both streams
 }
 
 // Generic performs a diff operation on two sorted channels of any comparable type T.
@@ -95,6 +96,12 @@ func (d *differ[T]) diff() (r Result, err error) {
 			r.Common++
 			r.TotalA++
 			r.TotalB++
+			if d.reportCommon {
+				err = d.resultFunc(BOTH, dataA)
+				if err != nil {
+					return
+				}
+			}
 			select {
 			case dataA, okA = <-d.aChan:
 			case <-d.ctx.Done():
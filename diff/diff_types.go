@@ -2,11 +2,12 @@
 // and identifying differences between them. It operates efficiently on pre-sorted
 // input channels and reports items that exist in only one stream or both streams.
 //
-// The package supports three main use cases:
+// The package supports four main use cases:
 //
 //  1. Generic comparison using Generic() with custom comparison functions
 //  2. Ordered type comparison using Ordered() with built-in comparison operators
 //  3. String comparison using Strings() for backward compatibility
+//  4. Listing every item with its origin on a channel using Entries()
 //
 // All diff operations assume that input channels provide data in sorted order.
 // This assumption is not validated for performance reasons.
@@ -45,6 +46,10 @@ const (
 	// OLD indicates an item that exists only in the first stream (A).
 	// This represents an "old" or "removed" item when comparing A to B.
 	OLD // -
+
+	// BOTH indicates an item that exists in both streams. It is only reported
+	// by Entries; ResultFunc callbacks are never called with it.
+	BOTH // =
 )
 
 // ResultFunc is a generic callback function type for processing diff results.
@@ -70,6 +75,8 @@ func (d Delta) String() string {
 		return ">"
 	case OLD:
 		return "<"
+	case BOTH:
+		return "="
 	default:
 		return "?"
 	}
@@ -323,3 +323,56 @@ func TestLargeDataset(t *testing.T) {
 		t.Errorf("expected %d result callbacks, got %d", expectedExtraA+expectedExtraB, resultCount)
 	}
 }
+
+// Test Entries with duplicates on both sides
+func TestEntries(t *testing.T) {
+	feed := func(items ...int) (<-chan int, <-chan error) {
+		c := make(chan int, len(items))
+		for _, i := range items {
+			c <- i
+		}
+		close(c)
+		errChan := make(chan error)
+		close(errChan)
+		return c, errChan
+	}
+	aChan, aErrChan := feed(1, 2, 2, 3, 3, 5)
+	bChan, bErrChan := feed(2, 3, 3, 3, 4, 5, 5)
+
+	compareF := func(a, b int) int { return a - b }
+	entries, errChan := diff.Entries(context.Background(), aChan, bChan, aErrChan, bErrChan, compareF)
+
+	var results []string
+	for e := range entries {
+		results = append(results, fmt.Sprintf("%s %d", e.D, e.V))
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"< 1", "= 2", "< 2", "= 3", "= 3", "> 3", "> 4", "= 5", "> 5"}
+	if fmt.Sprint(results) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, got %v", expected, results)
+	}
+}
+
+// Test that Entries reports errors from the input streams
+func TestEntriesError(t *testing.T) {
+	aChan := make(chan int)
+	bChan := make(chan int)
+	close(aChan)
+	close(bChan)
+	aErrChan := make(chan error, 1)
+	bErrChan := make(chan error)
+	close(bErrChan)
+	aErrChan <- fmt.Errorf("stream A failed")
+	close(aErrChan)
+
+	entries, errChan := diff.Entries(context.Background(), aChan, bChan, aErrChan, bErrChan, func(a, b int) int { return a - b })
+	for range entries {
+		t.Fatal("expected no entries")
+	}
+	if err := <-errChan; err == nil {
+		t.Fatal("expected error from stream A")
+	}
+}
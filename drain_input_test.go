@@ -0,0 +1,97 @@
+package extsort_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+var errBadRecord = errors.New("bad record")
+
+// failingCompare fails the sort as soon as the first chunk is sorted.
+var failingCompare = extsort.FallibleCompare(func(a, b int) (int, error) {
+	return 0, errBadRecord
+})
+
+// produce sends n records on an unbuffered channel, closing done once all are sent.
+func produce(n int) (<-chan int, <-chan struct{}) {
+	input := make(chan int)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(input)
+		for i := range n {
+			input <- n - i
+		}
+	}()
+	return input, done
+}
+
+func TestDrainInputOnAbort(t *testing.T) {
+	// the input is drained by default, including with a Config literal
+	for _, config := range []*extsort.Config{extsort.DefaultConfig(), {}} {
+		input, done := produce(10000)
+		config.ChunkSize = 10
+		sorter, outChan, errChan := extsort.Generic(input, intFromBytes, intToBytes, failingCompare, config)
+		sorter.Sort(context.Background())
+		for range outChan {
+		}
+		if err := <-errChan; !errors.Is(err, errBadRecord) {
+			t.Fatalf("expected the comparison error, got %v", err)
+		}
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("producer still blocked after the sort aborted")
+		}
+	}
+}
+
+func TestNoDrainOnAbort(t *testing.T) {
+	input, done := produce(10000)
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 10
+	config.NoDrainOnAbort = true
+	sorter, outChan, errChan := extsort.Generic(input, intFromBytes, intToBytes, failingCompare, config)
+	sorter.Sort(context.Background())
+	for range outChan {
+	}
+	if err := <-errChan; !errors.Is(err, errBadRecord) {
+		t.Fatalf("expected the comparison error, got %v", err)
+	}
+	select {
+	case <-done:
+		t.Fatal("input was drained with NoDrainOnAbort set")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// release the producer
+	for range input {
+	}
+}
+
+// TestDrainInputOnAbortKeyed verifies that wrapping sorters drain their own input
+// when the sort is canceled.
+func TestDrainInputOnAbortKeyed(t *testing.T) {
+	input, done := produce(10000)
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 10
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	key := func(v int) []byte {
+		b, _ := intToBytes(v)
+		return b
+	}
+	sorter, outChan, errChan := extsort.Keyed(input, intFromBytes, intToBytes, key, config)
+	sorter.Sort(ctx)
+	for range outChan {
+	}
+	<-errChan
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("producer still blocked after the sort was canceled")
+	}
+}
@@ -1,9 +1,48 @@
 package extsort
 
 import (
+	"errors"
 	"fmt"
 )
 
+var (
+	// ErrInvalidSortedFile is returned when a file is not a sorted file written by
+	// SortToFile, or was written with an unsupported version of the format. It is
+	// also returned for an invalid bloom filter file.
+	ErrInvalidSortedFile = errors.New("invalid sorted file")
+
+	// ErrComparatorBudgetExceeded is returned when sorting a chunk requires more
+	// comparisons than allowed by Config.MaxComparisons.
+	ErrComparatorBudgetExceeded = errors.New("comparator budget exceeded")
+
+	// ErrCompareTimeExceeded is returned when the time spent comparing records
+	// exceeds Config.MaxCompareTime.
+	ErrCompareTimeExceeded = errors.New("comparator time budget exceeded")
+
+	// ErrNilItem is returned when a nil item is received on the input channel
+	// and Config.OnNilItem is NilItemError.
+	ErrNilItem = errors.New("nil item received on input channel")
+
+	// ErrPartialRecord is returned by SortMmap when the input file size is not a
+	// multiple of the record size.
+	ErrPartialRecord = errors.New("file size is not a multiple of the record size")
+
+	// ErrConsumerStalled is returned when a record could not be sent on the output
+	// channel within Config.ConsumerTimeout.
+	ErrConsumerStalled = errors.New("output consumer stalled")
+
+	// ErrTimeout is returned when a sort does not complete within Config.MaxDuration.
+	ErrTimeout = errors.New("sort exceeded maximum duration")
+
+	// ErrNondeterministicSerialization is returned when a record serializes to
+	// different bytes twice in a row and Config.VerifyDeterministicSerialization is set.
+	ErrNondeterministicSerialization = errors.New("record serialization is not deterministic")
+
+	// ErrInvalidRank is returned by Arrange when a record has a negative rank or
+	// shares its rank with another record.
+	ErrInvalidRank = errors.New("invalid rank")
+)
+
 // SerializationError represents an error that occurred during item serialization (ToBytes)
 type SerializationError struct {
 	// Cause is the original panic or error that occurred during serialization
@@ -87,12 +126,30 @@ func NewComparisonError(cause interface{}, context string) error {
 	return &ComparisonError{Cause: cause, Context: context}
 }
 
+// DiskError represents a failed read or write of a temporary or sorted file
+type DiskError struct {
+	// Operation describes what was being done when the error occurred
+	Operation string
+	// Path is the file involved, if known
+	Path string
+	// Err is the underlying I/O error
+	Err error
+}
+
+func (e *DiskError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("disk error during %s on %s: %v", e.Operation, e.Path, e.Err)
+	}
+	return fmt.Sprintf("disk error during %s: %v", e.Operation, e.Err)
+}
+
+func (e *DiskError) Unwrap() error {
+	return e.Err
+}
+
 // NewDiskError creates a DiskError wrapping the underlying I/O error
 func NewDiskError(err error, operation, path string) error {
-	if path != "" {
-		return fmt.Errorf("disk error during %s on %s: %w", operation, path, err)
-	}
-	return fmt.Errorf("disk error during %s: %w", operation, err)
+	return &DiskError{Operation: operation, Path: path, Err: err}
 }
 
 // ConfigError represents an error in configuration parameters
@@ -0,0 +1,92 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestExpectedCountWrong(t *testing.T) {
+	const n = 1000
+	for _, expected := range []int{1, n / 3, n * 50} {
+		input := make(chan int, n)
+		for i := n; i > 0; i-- {
+			input <- i
+		}
+		close(input)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 200
+		config.ExpectedCount = expected
+		sorter, outChan, errChan := extsort.Generic(input, intFromBytes, intToBytes, cmp.Compare[int], config)
+		progress := sorter.Progress()
+		sorter.Sort(context.Background())
+
+		want := 1
+		for v := range outChan {
+			if v != want {
+				t.Fatalf("ExpectedCount %d: got %d, want %d", expected, v, want)
+			}
+			want++
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("ExpectedCount %d: unexpected error: %v", expected, err)
+		}
+		if want != n+1 {
+			t.Fatalf("ExpectedCount %d: got %d records, want %d", expected, want-1, n)
+		}
+		var last extsort.Progress
+		for p := range progress {
+			last = p
+		}
+		if last.ExpectedRecords != n {
+			t.Errorf("ExpectedCount %d: final ExpectedRecords = %d, want %d", expected, last.ExpectedRecords, n)
+		}
+	}
+}
+
+func TestExpectedCountReportedWhileReading(t *testing.T) {
+	input := make(chan int)
+	config := extsort.DefaultConfig()
+	config.ExpectedCount = 42
+	config.ProgressInterval = time.Millisecond
+	sorter, outChan, errChan := extsort.Generic(input, intFromBytes, intToBytes, cmp.Compare[int], config)
+	progress := sorter.Progress()
+	go sorter.Sort(context.Background())
+
+	input <- 1
+	for p := range progress {
+		if p.RecordsRead == 0 {
+			continue
+		}
+		if p.ExpectedRecords != 42 {
+			t.Errorf("ExpectedRecords while reading = %d, want 42", p.ExpectedRecords)
+		}
+		break
+	}
+	close(input)
+	for range outChan {
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestExpectedCountNegative(t *testing.T) {
+	input := make(chan int)
+	close(input)
+	config := extsort.DefaultConfig()
+	config.ExpectedCount = -1
+	sorter, outChan, errChan := extsort.Generic(input, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	for range outChan {
+	}
+	var cfgErr *extsort.ConfigError
+	if err := <-errChan; !errors.As(err, &cfgErr) || cfgErr.Field != "ExpectedCount" {
+		t.Fatalf("expected ConfigError for ExpectedCount, got %v", err)
+	}
+}
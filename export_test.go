@@ -0,0 +1,30 @@
+package extsort
+
+import "github.com/lanrat/extsort/tempfile"
+
+// SetTempWriter replaces the temporary storage of a sorter created by Generic or
+// MockGeneric with writers returned by newWriter, so tests can inject faulty
+// backends. It must be called before Sort.
+func SetTempWriter[E any](s *GenericSorter[E], newWriter func() (tempfile.TempWriter, error)) error {
+	if s.tempWriter != nil {
+		_ = s.tempWriter.Close()
+	}
+	s.newTempWriter = newWriter
+	w, err := newWriter()
+	if err != nil {
+		return err
+	}
+	s.tempWriter = w
+	return nil
+}
+
+// SortedFileHeaderSize is the offset of the first record in a file written by SortToFile.
+const SortedFileHeaderSize = sortedFileHeaderSize
+
+// SetTempFileOptions makes a sorter created by Generic create its temporary files
+// with opts in addition to those selected by its Config. It must be called before Sort.
+func SetTempFileOptions[E any](s *GenericSorter[E], opts ...tempfile.Option) error {
+	return SetTempWriter(s, func() (tempfile.TempWriter, error) {
+		return tempfile.New(s.config.TempFilesDir, true, append(s.config.tempFileOptions(s.retryCtx), opts...)...)
+	})
+}
@@ -0,0 +1,147 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+	"github.com/lanrat/extsort/tempfile"
+)
+
+var errInjected = errors.New("injected write failure")
+
+// faultyBackend hands out in-memory temporary writers that fail once a byte or
+// chunk budget is used up, and records whether every writer was closed.
+type faultyBackend struct {
+	failAfterBytes  int // fail the write that would exceed this many bytes; 0 for no limit
+	failAfterChunks int // fail finishing chunk number failAfterChunks+1; 0 for no limit
+	bytes, chunks   int
+	writers         []*faultyWriter
+}
+
+func (b *faultyBackend) newWriter() (tempfile.TempWriter, error) {
+	w := &faultyWriter{MockFileWriter: tempfile.Mock(0), backend: b}
+	b.writers = append(b.writers, w)
+	return w, nil
+}
+
+// allClosed reports whether every writer handed out was closed or saved.
+func (b *faultyBackend) allClosed() bool {
+	for _, w := range b.writers {
+		if !w.closed {
+			return false
+		}
+	}
+	return true
+}
+
+type faultyWriter struct {
+	*tempfile.MockFileWriter
+	backend *faultyBackend
+	closed  bool
+}
+
+func (w *faultyWriter) Write(p []byte) (int, error) {
+	b := w.backend
+	if b.failAfterBytes > 0 && b.bytes+len(p) > b.failAfterBytes {
+		return 0, errInjected
+	}
+	b.bytes += len(p)
+	return w.MockFileWriter.Write(p)
+}
+
+func (w *faultyWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *faultyWriter) Next() (int64, error) {
+	b := w.backend
+	if b.failAfterChunks > 0 && b.chunks >= b.failAfterChunks {
+		return 0, errInjected
+	}
+	b.chunks++
+	return w.MockFileWriter.Next()
+}
+
+func (w *faultyWriter) Close() error {
+	w.closed = true
+	return w.MockFileWriter.Close()
+}
+
+func (w *faultyWriter) Save() (tempfile.TempReader, error) {
+	w.closed = true
+	return w.MockFileWriter.Save()
+}
+
+func TestInjectedWriteFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend *faultyBackend
+	}{
+		{"after bytes", &faultyBackend{failAfterBytes: 2500}},
+		{"third chunk", &faultyBackend{failAfterChunks: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := generateRandomInts(1000)
+			inputChan := make(chan int, len(data))
+			for _, v := range data {
+				inputChan <- v
+			}
+			close(inputChan)
+
+			config := extsort.DefaultConfig()
+			config.ChunkSize = 100
+			sorter, outChan, errChan := extsort.MockGeneric(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config, 0)
+			if err := extsort.SetTempWriter(sorter, tt.backend.newWriter); err != nil {
+				t.Fatal(err)
+			}
+			sorter.Sort(context.Background())
+			_, err := extsort.Collect(outChan, errChan, 0)
+			if !errors.Is(err, errInjected) {
+				t.Fatalf("expected the injected failure, got %v", err)
+			}
+			var diskErr *extsort.DiskError
+			if !errors.As(err, &diskErr) {
+				t.Fatalf("expected a DiskError, got %T: %v", err, err)
+			}
+			if !tt.backend.allClosed() {
+				t.Error("temporary writer was not closed after the failure")
+			}
+		})
+	}
+}
+
+// TestInjectedBackendSucceeds verifies the faulty backend sorts correctly when no
+// fault is configured, so the failures above come from the injected faults.
+func TestInjectedBackendSucceeds(t *testing.T) {
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	backend := &faultyBackend{}
+	sorter, outChan, errChan := extsort.MockGeneric(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config, 0)
+	if err := extsort.SetTempWriter(sorter, backend.newWriter); err != nil {
+		t.Fatal(err)
+	}
+	sorter.Sort(context.Background())
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	slices.Sort(data)
+	if !slices.Equal(result, data) {
+		t.Fatal("output does not match sorted input")
+	}
+	if backend.chunks != 10 {
+		t.Errorf("expected 10 chunks written, got %d", backend.chunks)
+	}
+}
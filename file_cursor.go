@@ -0,0 +1,133 @@
+package extsort
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/lanrat/extsort/tempfile"
+)
+
+// FileCursor iterates over the records of a file written by SortToFile, in sorted
+// order, starting from any key. Seek locates a key by binary search over the offset
+// index stored at the end of the file, after which Next reads records sequentially,
+// so a range scan reads only the records in the range. A FileCursor is not safe for
+// concurrent use.
+type FileCursor[E any] struct {
+	f           *SortedFile[E]
+	compareFunc CompareGeneric[E]
+	section     *io.SectionReader // records between the current offset and the index
+	reader      *bufio.Reader
+	value       E
+	err         error
+}
+
+// NewFileCursor opens a file written by SortToFile and returns a cursor positioned
+// before its first record. compareFunc must order records as they were sorted into
+// the file. The header and trailer are validated; files that were not written by
+// SortToFile or that use an unsupported format version produce ErrInvalidSortedFile.
+// The returned FileCursor must be closed when no longer needed.
+func NewFileCursor[E any](path string, fromBytes FromBytesGeneric[E], compareFunc CompareGeneric[E]) (*FileCursor[E], error) {
+	f, err := OpenSortedFileReader(path, fromBytes)
+	if err != nil {
+		return nil, err
+	}
+	c := &FileCursor[E]{f: f, compareFunc: compareFunc}
+	c.reset(int64(sortedFileHeaderSize))
+	return c, nil
+}
+
+// Seek positions the cursor before the first record that is greater than or equal
+// to key, so that the following call to Next reads it. If every record is less
+// than key, the cursor is positioned at the end and Next returns false.
+func (c *FileCursor[E]) Seek(key E) error {
+	i, err := c.f.Search(func(rec E) bool {
+		return c.compareFunc(rec, key) >= 0
+	})
+	if err != nil {
+		return err
+	}
+	offset := c.f.sf.indexOffset
+	if i < c.f.sf.count {
+		offsets := []int64{0}
+		if err := c.f.sf.readIndex(i, offsets); err != nil {
+			return err
+		}
+		offset = offsets[0]
+		if offset < int64(sortedFileHeaderSize) || offset > c.f.sf.indexOffset {
+			return fmt.Errorf("%s: %w: corrupt index", c.f.sf.path, ErrInvalidSortedFile)
+		}
+	}
+	c.reset(offset)
+	return nil
+}
+
+// Next advances the cursor to the next record, which is then available through
+// Value. It returns false at the end of the file or if an error occurs, which is
+// reported by Err.
+func (c *FileCursor[E]) Next() bool {
+	var zero E
+	c.value = zero
+	if c.err != nil {
+		return false
+	}
+	size, err := binary.ReadUvarint(c.reader)
+	if err == io.EOF {
+		return false
+	}
+	if err != nil {
+		c.err = NewDiskError(err, "read record", c.f.sf.path)
+		return false
+	}
+	// a corrupt header must not claim more than the records left in the file
+	if left := c.remaining(); size > uint64(left) {
+		c.err = fmt.Errorf("%s: %w: record of %d bytes with %d bytes left", c.f.sf.path, ErrInvalidSortedFile, size, left)
+		return false
+	}
+	raw := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, raw); err != nil {
+		c.err = NewDiskError(err, "read record", c.f.sf.path)
+		return false
+	}
+	c.value, err = c.f.fromBytes(raw)
+	if err != nil {
+		c.err = NewDeserializationError(err, len(raw), "FileCursor.Next")
+		return false
+	}
+	return true
+}
+
+// Value returns the record read by the last call to Next.
+func (c *FileCursor[E]) Value() E {
+	return c.value
+}
+
+// Err returns the error that stopped Next, if any. It is cleared by Seek.
+func (c *FileCursor[E]) Err() error {
+	return c.err
+}
+
+// Close closes the underlying file.
+func (c *FileCursor[E]) Close() error {
+	return c.f.Close()
+}
+
+// reset positions the cursor at offset in the file, before the record stored there.
+func (c *FileCursor[E]) reset(offset int64) {
+	var zero E
+	c.section = io.NewSectionReader(c.f.sf.file, offset, c.f.sf.indexOffset-offset)
+	if c.reader == nil {
+		c.reader = bufio.NewReaderSize(c.section, tempfile.BufferSize)
+	} else {
+		c.reader.Reset(c.section)
+	}
+	c.value = zero
+	c.err = nil
+}
+
+// remaining returns the number of record bytes left to read before the index.
+func (c *FileCursor[E]) remaining() int64 {
+	pos, _ := c.section.Seek(0, io.SeekCurrent) // never fails for SeekCurrent
+	return c.section.Size() - pos + int64(c.reader.Buffered())
+}
@@ -0,0 +1,63 @@
+package extsort_test
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestFloat64s verifies the placement of NaN, +Inf and -Inf for both NaN orders,
+// in both the single-chunk and the multi-chunk merge paths.
+func TestFloat64s(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	var data []float64
+	for i := 0; i < 100; i++ {
+		data = append(data, float64(i)-49.5)
+	}
+	data = append(data, nan, inf, -inf, nan, math.MaxFloat64, -math.MaxFloat64, nan)
+	rand.Shuffle(len(data), func(i, j int) { data[i], data[j] = data[j], data[i] })
+
+	for _, nanOrder := range []extsort.NaNOrder{extsort.NaNFirst, extsort.NaNLast} {
+		for _, chunkSize := range []int{10, 1000} {
+			inputChan := make(chan float64, len(data))
+			for _, v := range data {
+				inputChan <- v
+			}
+			close(inputChan)
+
+			config := extsort.DefaultConfig()
+			config.ChunkSize = chunkSize
+			sorter, outChan, errChan := extsort.Float64s(inputChan, nanOrder, config)
+			sorter.Sort(context.Background())
+			result, err := extsort.Collect(outChan, errChan, 0)
+			if err != nil {
+				t.Fatalf("nan order %d, chunk size %d: sort error: %v", nanOrder, chunkSize, err)
+			}
+			if len(result) != len(data) {
+				t.Fatalf("nan order %d, chunk size %d: expected %d values, got %d", nanOrder, chunkSize, len(data), len(result))
+			}
+
+			// the NaNs are grouped at one end, and the rest is ordered from -Inf to +Inf
+			nans, rest := result[:3], result[3:]
+			if nanOrder == extsort.NaNLast {
+				nans, rest = result[len(result)-3:], result[:len(result)-3]
+			}
+			for _, v := range nans {
+				if !math.IsNaN(v) {
+					t.Fatalf("nan order %d, chunk size %d: expected NaN, got %v in %v", nanOrder, chunkSize, v, result)
+				}
+			}
+			if rest[0] != -inf || rest[len(rest)-1] != inf {
+				t.Fatalf("nan order %d, chunk size %d: expected -Inf first and +Inf last, got %v", nanOrder, chunkSize, rest)
+			}
+			for i := 1; i < len(rest); i++ {
+				if !(rest[i-1] < rest[i]) {
+					t.Fatalf("nan order %d, chunk size %d: output not sorted at %d: %v", nanOrder, chunkSize, i, rest)
+				}
+			}
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package extsort_test
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/lanrat/extsort"
@@ -190,6 +192,41 @@ type GenericErrorItem struct {
 	ShouldFailSerialization bool `json:"shouldFailSerialization"`
 }
 
+// TestVerifyDeterministicSerialization tests that a serializer returning different
+// bytes for the same record is detected, and that a deterministic one passes.
+func TestVerifyDeterministicSerialization(t *testing.T) {
+	var calls atomic.Int64
+	nondeterministic := func(i int) ([]byte, error) {
+		b, err := intToBytes(i)
+		return append(b, byte(calls.Add(1))), err
+	}
+	for _, tc := range []struct {
+		name    string
+		toBytes extsort.ToBytesGeneric[int]
+		wantErr error
+	}{
+		{"deterministic", intToBytes, nil},
+		{"nondeterministic", nondeterministic, extsort.ErrNondeterministicSerialization},
+	} {
+		inputChan := make(chan int, 100)
+		for i := 100; i > 0; i-- {
+			inputChan <- i
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 10
+		config.VerifyDeterministicSerialization = true
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, tc.toBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+		_, err := extsort.Collect(outChan, errChan, 0)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
 func genericToBytes(item *GenericErrorItem) ([]byte, error) {
 	if item.ShouldFailSerialization {
 		return nil, errors.New("serialization failed for test")
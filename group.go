@@ -0,0 +1,42 @@
+package extsort
+
+import "context"
+
+// GroupChan returns a channel that gathers consecutive records comparing equal
+// under compareFunc into slices, so that each slice holds a whole group of records
+// sharing a key. Like UniqKeyChan, it assumes records of a group arrive
+// consecutively, which holds for sorted output. Each slice is newly allocated and
+// may be retained by the receiver.
+//
+// The returned channel will be closed when the input channel is closed.
+// This function spawns a goroutine that will terminate when the input channel is closed.
+func GroupChan[E any](in <-chan E, compareFunc CompareGeneric[E]) <-chan []E {
+	out := make(chan []E)
+	go func() {
+		var group []E
+		for d := range in {
+			if len(group) > 0 && compareFunc(group[0], d) != 0 {
+				out <- group
+				group = nil
+			}
+			group = append(group, d)
+		}
+		if len(group) > 0 {
+			out <- group
+		}
+		close(out)
+	}()
+	return out
+}
+
+// SortGroups sorts like Sort, and delivers the sorted output in groups of records
+// that compare equal, as by GroupChan with the sorter's comparison function. Groups
+// are formed from the merged output, so records of a group that were spilled in
+// different chunks are delivered together. The returned error channel is the one
+// returned when the sorter was created. Once SortGroups is called, the record channel
+// returned when the sorter was created must not be read.
+func (s *GenericSorter[E]) SortGroups(ctx context.Context) (<-chan []E, <-chan error) {
+	groups := GroupChan(s.mergeChunkChan, s.compareFunc)
+	s.Sort(ctx)
+	return groups, s.mergeErrChan
+}
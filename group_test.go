@@ -0,0 +1,79 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestSortGroups(t *testing.T) {
+	// each of 500 values appears 20 times, spread over many chunks
+	counts := make(map[int]int)
+	inputChan := make(chan int, 10000)
+	for i := range 10000 {
+		v := (i * 7919) % 500
+		inputChan <- v
+		counts[v]++
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	sorter, _, _ := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	groups, errChan := sorter.SortGroups(context.Background())
+
+	prev := -1
+	for g := range groups {
+		if g[0] <= prev {
+			t.Fatalf("group %d out of order after %d", g[0], prev)
+		}
+		for _, v := range g {
+			if v != g[0] {
+				t.Fatalf("group of %d holds %d", g[0], v)
+			}
+		}
+		if len(g) != counts[g[0]] {
+			t.Fatalf("expected group of %d to hold %d records, got %d", g[0], counts[g[0]], len(g))
+		}
+		delete(counts, g[0])
+		prev = g[0]
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if len(counts) != 0 {
+		t.Fatalf("%d groups missing from the output", len(counts))
+	}
+}
+
+// TestSortGroupsLegacy verifies that records with equal keys are grouped with the
+// legacy New API, whose less function cannot report equality directly.
+func TestSortGroupsLegacy(t *testing.T) {
+	inputChan := make(chan extsort.SortType, 10)
+	for i := 0; i < 10; i++ {
+		inputChan <- val{Key: i % 3, Order: i}
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 4
+	sorter, _, _ := extsort.New(inputChan, fromBytesForTest, KeyLessThan, config)
+	groups, errChan := sorter.SortGroups(context.Background())
+	var sizes []int
+	for g := range groups {
+		for _, r := range g {
+			if r.(val).Key != g[0].(val).Key {
+				t.Fatalf("group mixes keys: %v", g)
+			}
+		}
+		sizes = append(sizes, len(g))
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if len(sizes) != 3 || sizes[0] != 4 || sizes[1] != 3 || sizes[2] != 3 {
+		t.Errorf("expected groups of 4, 3 and 3 records, got sizes %v", sizes)
+	}
+}
@@ -0,0 +1,34 @@
+package extsort
+
+import "time"
+
+// defaultHeartbeatInterval is used when Config.HeartbeatInterval is not set.
+const defaultHeartbeatInterval = time.Second
+
+// startHeartbeat calls fn every interval from a new goroutine until the returned
+// function is called. Once that function returns, fn is no longer running and
+// will not be called again.
+func startHeartbeat(fn func(), interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,48 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestHeartbeat verifies that the heartbeat fires while the merge is stalled on a
+// quiet consumer, and stops once the sort has finished.
+func TestHeartbeat(t *testing.T) {
+	inputChan := make(chan int, 1000)
+	for _, v := range rand.Perm(1000) {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	var beats atomic.Int32
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.SortedChanBuffSize = 0
+	config.Heartbeat = func() { beats.Add(1) }
+	config.HeartbeatInterval = 5 * time.Millisecond
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+
+	// the merge emits nothing while the first record is held back
+	<-outChan
+	time.Sleep(50 * time.Millisecond)
+	if beats.Load() == 0 {
+		t.Fatal("expected heartbeats while the merge is stalled")
+	}
+	if _, err := extsort.Collect(outChan, errChan, 0); err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+
+	after := beats.Load()
+	time.Sleep(20 * time.Millisecond)
+	if got := beats.Load(); got != after {
+		t.Fatalf("expected no heartbeats after the sort finished, got %d more", got-after)
+	}
+}
@@ -0,0 +1,236 @@
+// Package jsonl merges JSON lines files that are each already sorted by a field of
+// their records into a single sorted stream. Only the key field of each line is
+// decoded, for comparison; the lines themselves are passed through unchanged. This
+// is the final step of a common log processing task, where shards of a log are
+// sorted separately, for example with extsort.SortLines, and merged centrally.
+package jsonl
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lanrat/extsort"
+	"github.com/lanrat/extsort/queue"
+)
+
+var (
+	// ErrMissingKey is returned, wrapped in a MalformedLineError, when a line does
+	// not contain the key field.
+	ErrMissingKey = errors.New("key field not found")
+
+	// ErrInvalidKey is returned, wrapped in a MalformedLineError, when the key field
+	// of a line is neither a string nor a number.
+	ErrInvalidKey = errors.New("key field is not a string or number")
+)
+
+// ErrorPolicy defines how MergeSortedJSONL handles malformed lines: lines that are
+// not valid JSON objects, or whose key field is missing or of an unsupported type.
+type ErrorPolicy int
+
+const (
+	// ErrorAbort stops the merge with a MalformedLineError.
+	ErrorAbort ErrorPolicy = iota
+	// ErrorSkip silently drops malformed lines.
+	ErrorSkip
+)
+
+// MalformedLineError reports a line whose key could not be read.
+type MalformedLineError struct {
+	// Path is the file containing the line
+	Path string
+	// Line is the 1-based line number within the file
+	Line int
+	// Err describes what is wrong with the line
+	Err error
+}
+
+func (e *MalformedLineError) Error() string {
+	return fmt.Sprintf("malformed JSON line %s:%d: %v", e.Path, e.Line, e.Err)
+}
+
+func (e *MalformedLineError) Unwrap() error {
+	return e.Err
+}
+
+// key is the decoded key field of a line. Numbers sort before strings; numbers are
+// compared exactly when both are integers that fit in an int64.
+type key struct {
+	isString bool
+	s        string
+	isInt    bool
+	i        int64
+	f        float64
+}
+
+func compareKeys(a, b key) int {
+	if a.isString != b.isString {
+		if a.isString {
+			return 1
+		}
+		return -1
+	}
+	if a.isString {
+		return strings.Compare(a.s, b.s)
+	}
+	if a.isInt && b.isInt {
+		return cmp.Compare(a.i, b.i)
+	}
+	return cmp.Compare(a.f, b.f)
+}
+
+// parseKey decodes the field at path from line, descending one object per element.
+func parseKey(line []byte, path []string) (key, error) {
+	raw := json.RawMessage(line)
+	for _, field := range path {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return key{}, err
+		}
+		var ok bool
+		if raw, ok = obj[field]; !ok {
+			return key{}, ErrMissingKey
+		}
+	}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return key{}, err
+	}
+	switch v := v.(type) {
+	case string:
+		return key{isString: true, s: v}, nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return key{}, err
+		}
+		i, err := v.Int64()
+		return key{isInt: err == nil, i: i, f: f}, nil
+	}
+	return key{}, ErrInvalidKey
+}
+
+// source is one file being merged, positioned at its next line.
+type source struct {
+	index   int
+	path    string
+	scanner *bufio.Scanner
+	lineNo  int
+	line    string
+	key     key
+}
+
+// advance reads the next well-formed line of the file, reporting false at the end.
+func (src *source) advance(path []string, policy ErrorPolicy) (bool, error) {
+	for src.scanner.Scan() {
+		src.lineNo++
+		line := src.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		k, err := parseKey(line, path)
+		if err != nil {
+			if policy == ErrorSkip {
+				continue
+			}
+			return false, &MalformedLineError{Path: src.path, Line: src.lineNo, Err: err}
+		}
+		src.line = string(line)
+		src.key = k
+		return true, nil
+	}
+	if err := src.scanner.Err(); err != nil {
+		return false, extsort.NewDiskError(err, "read JSON lines file", src.path)
+	}
+	return false, nil
+}
+
+// MergeSortedJSONL performs a k-way merge of JSON lines files, each sorted by the
+// field at keyPath, into a single sorted stream of their lines. The keyPath names
+// nested fields separated by dots, such as "request.time". String keys are ordered
+// by bytes and numeric keys by value, with all numbers before all strings. Lines
+// with equal keys are emitted in the order of their files in paths, and blank lines
+// are ignored. The files are not checked to be sorted; lines of a file that is not
+// sorted by keyPath are still all emitted, but the output is not sorted.
+//
+// Malformed lines are handled as selected by policy. Lines longer than
+// config.MaxLineSize abort the merge with an error wrapping bufio.ErrTooLong.
+// All files are opened before any line is emitted, so a missing file is reported
+// without producing partial output. Errors are delivered on the error channel after
+// the output channel is closed.
+func MergeSortedJSONL(ctx context.Context, paths []string, keyPath string, policy ErrorPolicy, config *extsort.Config) (<-chan string, <-chan error) {
+	if config == nil {
+		config = extsort.DefaultConfig()
+	}
+	output := make(chan string, max(config.SortedChanBuffSize, 0))
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(errChan)
+		defer close(output)
+		if err := merge(ctx, paths, strings.Split(keyPath, "."), policy, config.MaxLineSize, output); err != nil {
+			errChan <- err
+		}
+	}()
+
+	return output, errChan
+}
+
+// merge opens every file in paths, then merges their lines onto output.
+func merge(ctx context.Context, paths []string, path []string, policy ErrorPolicy, maxLineSize int, output chan<- string) error {
+	if maxLineSize <= 0 {
+		maxLineSize = bufio.MaxScanTokenSize
+	}
+	sources := make([]*source, 0, len(paths))
+	for i, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return extsort.NewDiskError(err, "open JSON lines file", p)
+		}
+		defer func() { _ = f.Close() }()
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, min(maxLineSize, 4096)), maxLineSize)
+		sources = append(sources, &source{index: i, path: p, scanner: scanner})
+	}
+
+	// equal keys are taken from the earlier file
+	pq := queue.NewPriorityQueue(func(a, b *source) int {
+		if c := compareKeys(a.key, b.key); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.index, b.index)
+	})
+	for _, src := range sources {
+		ok, err := src.advance(path, policy)
+		if err != nil {
+			return err
+		}
+		if ok {
+			pq.Push(src)
+		}
+	}
+	for pq.Len() > 0 {
+		src := pq.Pop()
+		select {
+		case output <- src.line:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		ok, err := src.advance(path, policy)
+		if err != nil {
+			return err
+		}
+		if ok {
+			pq.Push(src)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package jsonl_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lanrat/extsort"
+	"github.com/lanrat/extsort/jsonl"
+)
+
+// writeFiles writes each element of contents to its own file and returns their paths.
+func writeFiles(t *testing.T, contents ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := make([]string, len(contents))
+	for i, c := range contents {
+		paths[i] = filepath.Join(dir, string(rune('a'+i))+".jsonl")
+		if err := os.WriteFile(paths[i], []byte(c), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+func collect(output <-chan string, errChan <-chan error) ([]string, error) {
+	var lines []string
+	for line := range output {
+		lines = append(lines, line)
+	}
+	return lines, <-errChan
+}
+
+func TestMergeSortedJSONL(t *testing.T) {
+	paths := writeFiles(t,
+		`{"ts":1,"msg":"a1"}`+"\n"+`{"ts":4,"msg":"a4"}`+"\n"+`{"ts":9,"msg":"a9"}`+"\n",
+		`{"msg":"b2","ts":2}`+"\n\n"+`{"ts":4, "msg":"b4"}`+"\n"+`{"ts":10,"msg":"b10"}`,
+		``,
+		`{"ts":3.5,"msg":"c3.5"}`+"\n",
+	)
+	output, errChan := jsonl.MergeSortedJSONL(context.Background(), paths, "ts", jsonl.ErrorAbort, nil)
+	lines, err := collect(output, errChan)
+	if err != nil {
+		t.Fatalf("merge error: %v", err)
+	}
+	want := []string{
+		`{"ts":1,"msg":"a1"}`,
+		`{"msg":"b2","ts":2}`,
+		`{"ts":3.5,"msg":"c3.5"}`,
+		`{"ts":4,"msg":"a4"}`, // equal keys keep the order of the files
+		`{"ts":4, "msg":"b4"}`,
+		`{"ts":9,"msg":"a9"}`,
+		`{"ts":10,"msg":"b10"}`,
+	}
+	if !slices.Equal(lines, want) {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMergeSortedJSONLNestedKey(t *testing.T) {
+	paths := writeFiles(t,
+		`{"req":{"id":"b"}}`+"\n"+`{"req":{"id":"d"}}`+"\n",
+		`{"req":{"id":"a"}}`+"\n"+`{"req":{"id":"c"}}`+"\n",
+	)
+	output, errChan := jsonl.MergeSortedJSONL(context.Background(), paths, "req.id", jsonl.ErrorAbort, nil)
+	lines, err := collect(output, errChan)
+	if err != nil {
+		t.Fatalf("merge error: %v", err)
+	}
+	want := []string{`{"req":{"id":"a"}}`, `{"req":{"id":"b"}}`, `{"req":{"id":"c"}}`, `{"req":{"id":"d"}}`}
+	if !slices.Equal(lines, want) {
+		t.Fatalf("got %q, want %q", lines, want)
+	}
+}
+
+func TestMergeSortedJSONLMalformed(t *testing.T) {
+	tests := []struct {
+		line string
+		err  error
+	}{
+		{`not json`, nil},
+		{`{"other":1}`, jsonl.ErrMissingKey},
+		{`{"ts":[1]}`, jsonl.ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		paths := writeFiles(t, `{"ts":1}`+"\n"+tt.line+"\n"+`{"ts":3}`+"\n", `{"ts":2}`+"\n")
+
+		output, errChan := jsonl.MergeSortedJSONL(context.Background(), paths, "ts", jsonl.ErrorAbort, nil)
+		_, err := collect(output, errChan)
+		var lineErr *jsonl.MalformedLineError
+		if !errors.As(err, &lineErr) || lineErr.Path != paths[0] || lineErr.Line != 2 {
+			t.Fatalf("%s: expected a MalformedLineError for line 2 of %s, got %v", tt.line, paths[0], err)
+		}
+		if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.line, tt.err, err)
+		}
+
+		output, errChan = jsonl.MergeSortedJSONL(context.Background(), paths, "ts", jsonl.ErrorSkip, nil)
+		lines, err := collect(output, errChan)
+		if err != nil {
+			t.Fatalf("%s: merge error: %v", tt.line, err)
+		}
+		want := []string{`{"ts":1}`, `{"ts":2}`, `{"ts":3}`}
+		if !slices.Equal(lines, want) {
+			t.Errorf("%s: got %q, want %q", tt.line, lines, want)
+		}
+	}
+}
+
+func TestMergeSortedJSONLMissingFile(t *testing.T) {
+	paths := writeFiles(t, `{"ts":1}`+"\n")
+	paths = append(paths, filepath.Join(t.TempDir(), "missing.jsonl"))
+	output, errChan := jsonl.MergeSortedJSONL(context.Background(), paths, "ts", jsonl.ErrorAbort, nil)
+	lines, err := collect(output, errChan)
+	var diskErr *extsort.DiskError
+	if !errors.As(err, &diskErr) || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a DiskError for the missing file, got %v", err)
+	}
+	if len(lines) != 0 {
+		t.Errorf("expected no output, got %q", lines)
+	}
+}
+
+func TestMergeSortedJSONLLongLine(t *testing.T) {
+	paths := writeFiles(t, `{"ts":1,"pad":"`+strings.Repeat("x", 100)+`"}`+"\n")
+	config := extsort.DefaultConfig()
+	config.MaxLineSize = 64
+	output, errChan := jsonl.MergeSortedJSONL(context.Background(), paths, "ts", jsonl.ErrorSkip, config)
+	if _, err := collect(output, errChan); !errors.Is(err, bufio.ErrTooLong) {
+		t.Fatalf("expected bufio.ErrTooLong, got %v", err)
+	}
+}
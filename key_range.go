@@ -0,0 +1,54 @@
+package extsort
+
+// keyRange is the inclusive range of records kept by SetKeyRange.
+type keyRange[E any] struct {
+	lo, hi E
+}
+
+// chunkBounds holds the smallest and largest records of a spilled chunk.
+type chunkBounds[E any] struct {
+	min, max E
+	empty    bool
+}
+
+// SetKeyRange restricts the output to the records r for which lo <= r <= hi under
+// the compare function of the sort. The smallest and largest records of every
+// spilled chunk are recorded, and chunks that hold no record in the range are
+// skipped by the merge without being read, so extracting a narrow range from a
+// large sort reads little more than the chunks that overlap it. Records outside
+// the range are never emitted, nor seen by SetMapOutput or SetAggregator. It
+// cannot be combined with Config.Quantiles. It must be called before Sort.
+func (s *GenericSorter[E]) SetKeyRange(lo, hi E) {
+	s.keyRange = &keyRange[E]{lo: lo, hi: hi}
+}
+
+// inKeyRange reports whether rec is within the range set by SetKeyRange, if any.
+func (s *GenericSorter[E]) inKeyRange(rec E) bool {
+	// only ask whether one record is less than another, which the adapter of a
+	// legacy CompareLessFunc answers without telling equal records apart
+	return s.keyRange == nil ||
+		(s.compareFunc(rec, s.keyRange.lo) >= 0 && s.compareFunc(s.keyRange.hi, rec) >= 0)
+}
+
+// recordChunkBounds records the bounds of the chunk just spilled, when a key
+// range is set. data must be sorted.
+func (s *GenericSorter[E]) recordChunkBounds(data []E) {
+	if s.keyRange == nil {
+		return
+	}
+	if len(data) == 0 {
+		s.chunkBounds = append(s.chunkBounds, chunkBounds[E]{empty: true})
+		return
+	}
+	s.chunkBounds = append(s.chunkBounds, chunkBounds[E]{min: data[0], max: data[len(data)-1]})
+}
+
+// skipChunk reports whether chunk i holds no record in the range set by
+// SetKeyRange, so that the merge does not need to read it.
+func (s *GenericSorter[E]) skipChunk(i int) bool {
+	if s.keyRange == nil || i >= len(s.chunkBounds) {
+		return false
+	}
+	b := s.chunkBounds[i]
+	return b.empty || s.compareFunc(b.max, s.keyRange.lo) < 0 || s.compareFunc(s.keyRange.hi, b.min) < 0
+}
@@ -0,0 +1,127 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestKeyRange verifies that only records within the key range are emitted, for the
+// single-chunk, merge and intermediate merge paths.
+func TestKeyRange(t *testing.T) {
+	var expected []int
+	for i := 250; i <= 260; i++ {
+		expected = append(expected, i)
+	}
+	for _, tc := range []struct {
+		chunkSize, maxRuns int
+	}{
+		{2000, 0}, // single chunk sorted in memory
+		{100, 0},  // merge
+		{100, 3},  // intermediate merges
+	} {
+		inputChan := make(chan int, 1000)
+		for _, v := range rand.Perm(1000) {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = tc.chunkSize
+		config.MaxRunsBeforeMerge = tc.maxRuns
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.SetKeyRange(250, 260)
+		sorter.Sort(context.Background())
+		result, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("%+v: sort error: %v", tc, err)
+		}
+		if !slices.Equal(result, expected) {
+			t.Fatalf("%+v: expected %v, got %v", tc, expected, result)
+		}
+	}
+}
+
+// TestKeyRangeSkipsChunks verifies that chunks entirely outside the key range are
+// not read by the merge.
+func TestKeyRangeSkipsChunks(t *testing.T) {
+	// sorted input gives every chunk a narrow range of its own
+	inputChan := make(chan int, 1000)
+	for i := 0; i < 1000; i++ {
+		inputChan <- i
+	}
+	close(inputChan)
+
+	var decoded atomic.Int32
+	fromBytes := func(b []byte) (int, error) {
+		decoded.Add(1)
+		return intFromBytes(b)
+	}
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	sorter, outChan, errChan := extsort.Generic(inputChan, fromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.SetKeyRange(250, 260)
+	sorter.Sort(context.Background())
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if len(result) != 11 {
+		t.Fatalf("expected 11 records, got %v", result)
+	}
+	if n := decoded.Load(); n != 100 {
+		t.Fatalf("expected only the overlapping chunk of 100 records to be read, got %d records", n)
+	}
+}
+
+// TestKeyRangeQuantiles verifies that a key range cannot be combined with quantiles.
+func TestKeyRangeQuantiles(t *testing.T) {
+	inputChan := make(chan int)
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.Quantiles = []float64{0.5}
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.SetKeyRange(0, 1)
+	sorter.Sort(context.Background())
+	_, err := extsort.Collect(outChan, errChan, 0)
+	var configErr *extsort.ConfigError
+	if !errors.As(err, &configErr) || configErr.Field != "Quantiles" {
+		t.Fatalf("expected a Quantiles ConfigError, got %v", err)
+	}
+}
+
+// TestKeyRangeLegacy verifies that both bounds of the range are inclusive with the
+// legacy New API, whose less function cannot report equality directly.
+func TestKeyRangeLegacy(t *testing.T) {
+	for _, chunkSize := range []int{100, 4} {
+		inputChan := make(chan extsort.SortType, 12)
+		for i := 0; i < 12; i++ {
+			inputChan <- val{Key: i % 4, Order: i}
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+		sorter, outChan, errChan := extsort.New(inputChan, fromBytesForTest, KeyLessThan, config)
+		sorter.SetKeyRange(val{Key: 1}, val{Key: 2})
+		sorter.Sort(context.Background())
+		result, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("ChunkSize %d: sort error: %v", chunkSize, err)
+		}
+		counts := make(map[int]int)
+		for _, r := range result {
+			counts[r.(val).Key]++
+		}
+		if len(result) != 6 || counts[1] != 3 || counts[2] != 3 {
+			t.Errorf("ChunkSize %d: expected three records each of keys 1 and 2, got %v", chunkSize, result)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package extsort_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// decimalKey returns the zero-padded decimal representation of i, which sorts
+// lexicographically in numeric order for non-negative values.
+func decimalKey(i int) []byte {
+	return []byte(fmt.Sprintf("%020d", i))
+}
+
+func TestKeyed(t *testing.T) {
+	for _, chunkSize := range []int{100, 100000} {
+		data := generateRandomInts(5000)
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		var keyCalls atomic.Int64
+		keyFunc := func(i int) []byte {
+			keyCalls.Add(1)
+			return decimalKey(i)
+		}
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+		sorter, outChan, errChan := extsort.Keyed(inputChan, intFromBytes, intToBytes, keyFunc, config)
+		sorter.Sort(context.Background())
+
+		result, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+		slices.Sort(data)
+		if !slices.Equal(result, data) {
+			t.Fatalf("chunk size %d: output does not match sorted input", chunkSize)
+		}
+		if got := keyCalls.Load(); got != int64(len(data)) {
+			t.Errorf("chunk size %d: expected key extracted %d times, got %d", chunkSize, len(data), got)
+		}
+	}
+}
+
+func TestKeyedMock(t *testing.T) {
+	inputChan := make(chan string, 5)
+	for _, s := range []string{"30", "4", "200", "1", "10"} {
+		inputChan <- s
+	}
+	close(inputChan)
+
+	// order by numeric value rather than lexicographically
+	keyFunc := func(s string) []byte {
+		i, _ := strconv.Atoi(s)
+		return decimalKey(i)
+	}
+	fromBytes := func(b []byte) (string, error) { return string(b), nil }
+	toBytes := func(s string) ([]byte, error) { return []byte(s), nil }
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 2
+	sorter, outChan, errChan := extsort.KeyedMock(inputChan, fromBytes, toBytes, keyFunc, config, 100)
+	sorter.Sort(context.Background())
+
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if want := []string{"1", "4", "10", "30", "200"}; !slices.Equal(result, want) {
+		t.Fatalf("expected %v, got %v", want, result)
+	}
+}
+
+// expensiveKey simulates a costly key extraction by hashing the record repeatedly.
+func expensiveKey(i int) []byte {
+	b, _ := intToBytes(i)
+	sum := sha256.Sum256(b)
+	for j := 0; j < 20; j++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return sum[:]
+}
+
+func BenchmarkExpensiveKey(b *testing.B) {
+	data := generateRandomInts(20000)
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 5000
+
+	b.Run("Compare", func(b *testing.B) {
+		compare := func(x, y int) int {
+			return slices.Compare(expensiveKey(x), expensiveKey(y))
+		}
+		for i := 0; i < b.N; i++ {
+			inputChan := make(chan int, len(data))
+			for _, v := range data {
+				inputChan <- v
+			}
+			close(inputChan)
+			sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, compare, config)
+			sorter.Sort(context.Background())
+			if _, err := extsort.Collect(outChan, errChan, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Keyed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			inputChan := make(chan int, len(data))
+			for _, v := range data {
+				inputChan <- v
+			}
+			close(inputChan)
+			sorter, outChan, errChan := extsort.Keyed(inputChan, intFromBytes, intToBytes, expensiveKey, config)
+			sorter.Sort(context.Background())
+			if _, err := extsort.Collect(outChan, errChan, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
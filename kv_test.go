@@ -0,0 +1,198 @@
+package extsort_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestKeyValues(t *testing.T) {
+	const n = 1000
+	inputChan := make(chan extsort.KV, n)
+	for _, i := range rand.Perm(n) {
+		inputChan <- extsort.KV{
+			Key:   []byte(fmt.Sprintf("key-%05d", i)),
+			Value: bytes.Repeat([]byte{byte(i)}, i%50),
+		}
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 64
+
+	sorter, outChan, errChan := extsort.KeyValues(inputChan, nil, config)
+	sorter.Sort(context.Background())
+
+	i := 0
+	for kv := range outChan {
+		if want := fmt.Sprintf("key-%05d", i); string(kv.Key) != want {
+			t.Fatalf("expected key %q at position %d, got %q", want, i, kv.Key)
+		}
+		if want := bytes.Repeat([]byte{byte(i)}, i%50); !bytes.Equal(kv.Value, want) {
+			t.Fatalf("value mismatch for key %q", kv.Key)
+		}
+		i++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if i != n {
+		t.Fatalf("expected %d records, got %d", n, i)
+	}
+}
+
+func TestKeyValuesCustomCompareMock(t *testing.T) {
+	records := []extsort.KV{
+		{Key: []byte("a"), Value: []byte("1")},
+		{Key: []byte("c"), Value: nil},
+		{Key: []byte(""), Value: []byte("empty key")},
+		{Key: []byte("b"), Value: []byte("2")},
+	}
+	inputChan := make(chan extsort.KV, len(records))
+	for _, r := range records {
+		inputChan <- r
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 2
+
+	// reverse key order
+	reverse := func(a, b []byte) int { return bytes.Compare(b, a) }
+	sorter, outChan, errChan := extsort.KeyValuesMock(inputChan, reverse, config, 1024)
+	sorter.Sort(context.Background())
+
+	expected := []string{"c", "b", "a", ""}
+	i := 0
+	for kv := range outChan {
+		if string(kv.Key) != expected[i] {
+			t.Fatalf("expected key %q at position %d, got %q", expected[i], i, kv.Key)
+		}
+		i++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if i != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), i)
+	}
+}
+
+func TestKeyValuesSplit(t *testing.T) {
+	const n = 1000
+	for _, chunkSize := range []int{64, 10000} {
+		inputChan := make(chan extsort.KV, n)
+		for _, i := range rand.Perm(n) {
+			inputChan <- extsort.KV{
+				Key:   []byte(fmt.Sprintf("key-%05d", i)),
+				Value: bytes.Repeat([]byte{byte(i)}, i%50),
+			}
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+		config.TempFilesDir = t.TempDir()
+
+		sorter, outChan, errChan := extsort.KeyValuesSplit(inputChan, nil, config)
+		sorter.Sort(context.Background())
+
+		i := 0
+		for kv := range outChan {
+			if want := fmt.Sprintf("key-%05d", i); string(kv.Key) != want {
+				t.Fatalf("chunk size %d: expected key %q at position %d, got %q", chunkSize, want, i, kv.Key)
+			}
+			if want := bytes.Repeat([]byte{byte(i)}, i%50); !bytes.Equal(kv.Value, want) {
+				t.Fatalf("chunk size %d: value mismatch for key %q", chunkSize, kv.Key)
+			}
+			i++
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+		if i != n {
+			t.Fatalf("chunk size %d: expected %d records, got %d", chunkSize, n, i)
+		}
+
+		// the payload file is removed once the output is complete
+		payloads, err := filepath.Glob(filepath.Join(config.TempFilesDir, "extsort-payload-*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(payloads) != 0 {
+			t.Errorf("chunk size %d: payload file not removed: %v", chunkSize, payloads)
+		}
+	}
+}
+
+func TestKeyValuesSplitCancel(t *testing.T) {
+	inputChan := make(chan extsort.KV, 1000)
+	for i := 0; i < 1000; i++ {
+		inputChan <- extsort.KV{Key: []byte(fmt.Sprintf("key-%05d", i)), Value: []byte("value")}
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.SortedChanBuffSize = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sorter, outChan, errChan := extsort.KeyValuesSplit(inputChan, nil, config)
+	sorter.Sort(ctx)
+	<-outChan
+	cancel()
+	for range outChan {
+	}
+	if err := <-errChan; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// BenchmarkKeyValuesSplit compares sorting records with 8-byte keys and 4KB values
+// with the keys and values stored together and apart.
+func BenchmarkKeyValuesSplit(b *testing.B) {
+	const n = 5000
+	records := make([]extsort.KV, n)
+	for i, k := range rand.Perm(n) {
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, uint64(k))
+		records[i] = extsort.KV{Key: key, Value: bytes.Repeat([]byte{byte(k)}, 4096)}
+	}
+	sorters := map[string]func(<-chan extsort.KV, *extsort.Config) (extsort.Sorter, <-chan extsort.KV, <-chan error){
+		"together": func(in <-chan extsort.KV, config *extsort.Config) (extsort.Sorter, <-chan extsort.KV, <-chan error) {
+			return extsort.KeyValues(in, nil, config)
+		},
+		"split": func(in <-chan extsort.KV, config *extsort.Config) (extsort.Sorter, <-chan extsort.KV, <-chan error) {
+			return extsort.KeyValuesSplit(in, nil, config)
+		},
+	}
+	for _, name := range []string{"together", "split"} {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(n * (8 + 4096))
+			for i := 0; i < b.N; i++ {
+				inputChan := make(chan extsort.KV, n)
+				for _, r := range records {
+					inputChan <- r
+				}
+				close(inputChan)
+
+				config := extsort.DefaultConfig()
+				config.ChunkSize = 250
+				sorter, outChan, errChan := sorters[name](inputChan, config)
+				sorter.Sort(context.Background())
+				for range outChan {
+				}
+				if err := <-errChan; err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestLimit verifies that a limited sort emits the smallest records while keeping
+// every spilled and merged run within the limit.
+func TestLimit(t *testing.T) {
+	const n, limit = 200000, 10
+	for _, numWorkers := range []int{1, 4} {
+		inputChan := make(chan int, 1000)
+		go func() {
+			for _, v := range rand.Perm(n) {
+				inputChan <- v
+			}
+			close(inputChan)
+		}()
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 1000
+		config.NumWorkers = numWorkers
+		config.Limit = limit
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		spilled := 0
+		sorter.SetOnChunkSpilled(func(_ int, _, _ int, count int) {
+			if count > limit {
+				t.Errorf("workers %d: spilled a chunk of %d records", numWorkers, count)
+			}
+			spilled++
+		})
+		sorter.Sort(context.Background())
+		result, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("workers %d: sort error: %v", numWorkers, err)
+		}
+		if expected := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}; !slices.Equal(result, expected) {
+			t.Fatalf("workers %d: expected %v, got %v", numWorkers, expected, result)
+		}
+		if spilled != n/1000 {
+			t.Fatalf("workers %d: expected %d chunks spilled, got %d", numWorkers, n/1000, spilled)
+		}
+		// runs are merged in pairs, so no merge ever holds more than two limits of records
+		for _, op := range sorter.MergeOps() {
+			if op.Records > 2*limit {
+				t.Fatalf("workers %d: merge of %d records exceeds the bound", numWorkers, op.Records)
+			}
+		}
+	}
+}
+
+// TestLimitLargerThanInput verifies that a limit above the input size keeps every record.
+func TestLimitLargerThanInput(t *testing.T) {
+	for _, chunkSize := range []int{30, 1000} {
+		data := rand.Perm(100)
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+		config.Limit = 500
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+		result, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+		if !slices.Equal(result, slices.Sorted(slices.Values(data))) {
+			t.Fatalf("chunk size %d: expected every record in order, got %v", chunkSize, result)
+		}
+	}
+}
+
+// TestLimitInvalid verifies that invalid limits and combinations are rejected.
+func TestLimitInvalid(t *testing.T) {
+	for _, config := range []*extsort.Config{
+		{Limit: -1},
+		{Limit: 1, Quantiles: []float64{0.5}},
+	} {
+		inputChan := make(chan int)
+		close(inputChan)
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+		_, err := extsort.Collect(outChan, errChan, 0)
+		var configErr *extsort.ConfigError
+		if !errors.As(err, &configErr) || configErr.Field != "Limit" {
+			t.Errorf("%+v: expected a Limit ConfigError, got %v", config, err)
+		}
+	}
+}
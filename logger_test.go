@@ -0,0 +1,116 @@
+package extsort_test
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestLogger verifies that lifecycle events are logged when a logger is configured.
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.Logger = logger
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	for range outChan {
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+
+	out := buf.String()
+	if n := strings.Count(out, "extsort: chunk spilled"); n != 10 {
+		t.Errorf("expected 10 chunk spill events, got %d:\n%s", n, out)
+	}
+	for _, msg := range []string{"extsort: merge started", "extsort: merge finished"} {
+		if !strings.Contains(out, msg) {
+			t.Errorf("expected log to contain %q:\n%s", msg, out)
+		}
+	}
+	if !strings.Contains(out, "bytes=") {
+		t.Errorf("expected chunk spill events to include bytes:\n%s", out)
+	}
+}
+
+// TestLoggerError verifies that sort errors are logged.
+func TestLoggerError(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	inputChan := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		inputChan <- i
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 2
+	config.Logger = logger
+
+	failingToBytes := func(int) ([]byte, error) {
+		return nil, bytes.ErrTooLarge
+	}
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, failingToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	for range outChan {
+	}
+	if err := <-errChan; err == nil {
+		t.Fatal("expected a serialization error")
+	}
+	if !strings.Contains(buf.String(), "extsort: sort failed") {
+		t.Errorf("expected error to be logged:\n%s", buf.String())
+	}
+}
+
+// TestLoggerJobID verifies that every log event carries the configured job id.
+func TestLoggerJobID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	data := generateRandomInts(100)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 10
+	config.Logger = logger
+	config.JobID = "nightly-42"
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	for range outChan {
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		t.Fatal("expected log events")
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "job=nightly-42") {
+			t.Errorf("expected log line to contain the job id: %s", line)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package extsort
+
+// MergeStrategy selects the data structure that picks the next record during a
+// k-way merge of sorted runs.
+type MergeStrategy int
+
+const (
+	// MergeHeap keeps the heads of the runs in a binary heap. Replacing the top
+	// costs up to two comparisons per level of the heap.
+	MergeHeap MergeStrategy = iota
+
+	// MergeLoserTree keeps the heads of the runs in a tournament tree of losers.
+	// Replacing the winner costs exactly one comparison per level of the tree,
+	// which pays off at high fan-in or with expensive comparisons.
+	MergeLoserTree
+)
+
+// loserTree is a tournament tree over the heads of sorted streams. Leaf i, for
+// stream i, is node k+i; every internal node n holds the stream that lost the
+// match between the winners of its children 2n and 2n+1, and node 0 holds the
+// overall winner. Exhausted streams lose every match.
+type loserTree[E any] struct {
+	streams     []*mergeFile[E]
+	done        []bool
+	nodes       []int
+	compareFunc CompareGeneric[E]
+}
+
+// newLoserTree plays the initial tournament between the preloaded streams.
+func newLoserTree[E any](streams []*mergeFile[E], compareFunc CompareGeneric[E]) *loserTree[E] {
+	k := len(streams)
+	t := &loserTree[E]{
+		streams:     streams,
+		done:        make([]bool, k),
+		nodes:       make([]int, k),
+		compareFunc: compareFunc,
+	}
+	winners := make([]int, 2*k)
+	for i := range k {
+		winners[k+i] = i
+	}
+	for n := k - 1; n >= 1; n-- {
+		a, b := winners[2*n], winners[2*n+1]
+		if t.beats(b, a) {
+			a, b = b, a
+		}
+		winners[n], t.nodes[n] = a, b
+	}
+	t.nodes[0] = winners[1]
+	return t
+}
+
+// beats reports whether the head of stream a comes before the head of stream b.
+// Equal records are taken from the stream with the lower index first.
+func (t *loserTree[E]) beats(a, b int) bool {
+	if t.done[a] || t.done[b] {
+		return !t.done[a]
+	}
+	return mergesBefore(t.compareFunc, t.streams[a].nextRec, a, t.streams[b].nextRec, b)
+}
+
+// replay advances stream w, the last winner, up the tree to find the next winner.
+func (t *loserTree[E]) replay(w int) {
+	for n := (len(t.streams) + w) / 2; n >= 1; n /= 2 {
+		if t.beats(t.nodes[n], w) {
+			t.nodes[n], w = w, t.nodes[n]
+		}
+	}
+	t.nodes[0] = w
+}
+
+// merge emits the records of all streams in order.
+func (t *loserTree[E]) merge(emit func(E) error) error {
+	for w := t.nodes[0]; !t.done[w]; w = t.nodes[0] {
+		rec, more, err := t.streams[w].getNext()
+		if err != nil {
+			return err
+		}
+		t.done[w] = !more
+		t.replay(w)
+		if err := emit(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
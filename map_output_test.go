@@ -0,0 +1,44 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestMapOutput verifies that the map function is applied to every emitted record
+// for both the single-chunk and the multi-chunk merge paths.
+func TestMapOutput(t *testing.T) {
+	for _, chunkSize := range []int{3, 1000} {
+		data := []int{9, 3, 7, 1, 8, 2, 6, 4, 5, 0}
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		// multiplying by a positive constant preserves the sort order
+		sorter.SetMapOutput(func(i int) int { return i * 10 })
+		sorter.Sort(context.Background())
+
+		expected := 0
+		for v := range outChan {
+			if v != expected {
+				t.Fatalf("chunk size %d: expected %d, got %d", chunkSize, expected, v)
+			}
+			expected += 10
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+		if expected != len(data)*10 {
+			t.Fatalf("chunk size %d: expected %d records, got %d", chunkSize, len(data), expected/10)
+		}
+	}
+}
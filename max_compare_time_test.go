@@ -0,0 +1,72 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestMaxCompareTimeExceeded verifies that a sort aborts once an artificially slow
+// comparator uses up its time budget, well before the sort could finish.
+func TestMaxCompareTimeExceeded(t *testing.T) {
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.MaxCompareTime = 50 * time.Millisecond
+	slowCompare := func(a, b int) int {
+		time.Sleep(100 * time.Microsecond)
+		return cmp.Compare(a, b)
+	}
+
+	start := time.Now()
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, slowCompare, config)
+	sorter.Sort(context.Background())
+	for range outChan {
+	}
+	if err := <-errChan; !errors.Is(err, extsort.ErrCompareTimeExceeded) {
+		t.Fatalf("expected ErrCompareTimeExceeded, got %v", err)
+	}
+	// sorting everything takes about 10,000 comparisons, or a second
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("sort took %v to abort", elapsed)
+	}
+}
+
+// TestMaxCompareTimeWithinBudget verifies that a generous budget does not affect sorting.
+func TestMaxCompareTimeWithinBudget(t *testing.T) {
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.MaxCompareTime = time.Minute
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if len(result) != len(data) {
+		t.Fatalf("expected %d records, got %d", len(data), len(result))
+	}
+	for i := 1; i < len(result); i++ {
+		if result[i-1] > result[i] {
+			t.Fatalf("output not sorted: %d before %d", result[i-1], result[i])
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestMaxComparisonsExceeded verifies that a sort aborts once a chunk needs more
+// comparisons than allowed.
+func TestMaxComparisonsExceeded(t *testing.T) {
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 500
+	config.MaxComparisons = 100
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	for range outChan {
+	}
+	if err := <-errChan; !errors.Is(err, extsort.ErrComparatorBudgetExceeded) {
+		t.Fatalf("expected ErrComparatorBudgetExceeded, got %v", err)
+	}
+}
+
+// TestMaxComparisonsWithinBudget verifies that a generous budget does not affect sorting.
+func TestMaxComparisonsWithinBudget(t *testing.T) {
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.MaxComparisons = 100 * 100
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	count := 0
+	prev := -1
+	for v := range outChan {
+		if v < prev {
+			t.Fatalf("output not sorted: %d before %d", prev, v)
+		}
+		prev = v
+		count++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if count != len(data) {
+		t.Fatalf("expected %d records, got %d", len(data), count)
+	}
+}
@@ -0,0 +1,93 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestMaxDurationInput verifies that a sort whose input never ends times out.
+func TestMaxDurationInput(t *testing.T) {
+	for _, chunkSize := range []int{10, 1000} {
+		inputChan := make(chan int)
+		go func() {
+			// send enough records to start the workers for small chunks, then stall
+			for i := 0; i < 50; i++ {
+				inputChan <- i
+			}
+		}()
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+		config.MaxDuration = 100 * time.Millisecond
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		start := time.Now()
+		sorter.Sort(context.Background())
+		for range outChan {
+		}
+		if err := <-errChan; !errors.Is(err, extsort.ErrTimeout) {
+			t.Fatalf("chunk size %d: expected ErrTimeout, got %v", chunkSize, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("chunk size %d: expected the sort to stop promptly, took %v", chunkSize, elapsed)
+		}
+	}
+}
+
+// TestMaxDurationOutput verifies that a slow consumer receives a prefix of the
+// sorted output before the sort times out.
+func TestMaxDurationOutput(t *testing.T) {
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.SortedChanBuffSize = 0
+	config.MaxDuration = 200 * time.Millisecond
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	count := 0
+	prev := -1
+	for v := range outChan {
+		if v < prev {
+			t.Fatalf("output not sorted: %d before %d", prev, v)
+		}
+		prev = v
+		count++
+		time.Sleep(time.Millisecond)
+	}
+	if err := <-errChan; !errors.Is(err, extsort.ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if count == 0 || count >= len(data) {
+		t.Fatalf("expected a partial output, got %d records", count)
+	}
+}
+
+// TestMaxDurationParentDeadline verifies that a deadline on the parent context is
+// still reported as a context error.
+func TestMaxDurationParentDeadline(t *testing.T) {
+	inputChan := make(chan int)
+	config := extsort.DefaultConfig()
+	config.MaxDuration = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(ctx)
+	for range outChan {
+	}
+	if err := <-errChan; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestMaxRunsBeforeMerge verifies that intermediate merges keep the number of runs
+// bounded while preserving the sorted order and the input order of equal records.
+func TestMaxRunsBeforeMerge(t *testing.T) {
+	const n = 2000
+	key := func(v int) int { return v * 7919 % 10 }
+	compare := func(a, b int) int { return cmp.Compare(key(a), key(b)) }
+
+	for _, maxRuns := range []int{2, 3} {
+		inputChan := make(chan int, n)
+		for i := 0; i < n; i++ {
+			inputChan <- i
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 50
+		config.Stable = true
+		config.MaxRunsBeforeMerge = maxRuns
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, compare, config)
+		maxID, spilled := 0, 0
+		sorter.SetOnChunkSpilled(func(id int, _, _ int, _ int) {
+			maxID = max(maxID, id)
+			spilled++
+		})
+		sorter.Sort(context.Background())
+		result, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("max runs %d: sort error: %v", maxRuns, err)
+		}
+		if spilled != n/50 {
+			t.Fatalf("max runs %d: expected %d chunks spilled, got %d", maxRuns, n/50, spilled)
+		}
+		if maxID >= maxRuns {
+			t.Fatalf("max runs %d: expected at most %d runs, got chunk id %d", maxRuns, maxRuns, maxID)
+		}
+		if len(result) != n {
+			t.Fatalf("max runs %d: expected %d records, got %d", maxRuns, n, len(result))
+		}
+		for i := 1; i < len(result); i++ {
+			prev, cur := result[i-1], result[i]
+			if c := compare(prev, cur); c > 0 || (c == 0 && prev > cur) {
+				t.Fatalf("max runs %d: %d emitted before %d at position %d", maxRuns, prev, cur, i)
+			}
+		}
+	}
+}
+
+// TestMaxRunsBeforeMergeInvalid verifies that a threshold of one run is rejected.
+func TestMaxRunsBeforeMergeInvalid(t *testing.T) {
+	inputChan := make(chan int)
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.MaxRunsBeforeMerge = 1
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	_, err := extsort.Collect(outChan, errChan, 0)
+	var configErr *extsort.ConfigError
+	if !errors.As(err, &configErr) || configErr.Field != "MaxRunsBeforeMerge" {
+		t.Fatalf("expected a MaxRunsBeforeMerge ConfigError, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+	"github.com/lanrat/extsort/tempfile"
+)
+
+// TestMemUsageBytesBuffering verifies that buffered records are reflected in the estimate.
+func TestMemUsageBytesBuffering(t *testing.T) {
+	inputChan := make(chan int)
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 10000
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	if got := sorter.MemUsageBytes(); got != 0 {
+		t.Fatalf("expected 0 bytes before sorting, got %d", got)
+	}
+
+	go sorter.Sort(context.Background())
+	for i := 0; i < 500; i++ {
+		inputChan <- i
+	}
+
+	// the last record may still be in flight to the chunk
+	deadline := time.Now().Add(5 * time.Second)
+	for sorter.MemUsageBytes() < 499*8 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected at least %d bytes buffered, got %d", 499*8, sorter.MemUsageBytes())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(inputChan)
+	for range outChan {
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if got := sorter.MemUsageBytes(); got != 0 {
+		t.Fatalf("expected 0 bytes after sorting, got %d", got)
+	}
+}
+
+// TestMemUsageBytesMerge verifies that merge read buffers are included in the estimate.
+func TestMemUsageBytesMerge(t *testing.T) {
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.SortedChanBuffSize = 0
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+
+	<-outChan // the merge has started once the first record is delivered
+	if got, min := sorter.MemUsageBytes(), int64(10*tempfile.BufferSize); got < min {
+		t.Errorf("expected at least %d bytes during merge, got %d", min, got)
+	}
+
+	for range outChan {
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if got := sorter.MemUsageBytes(); got != 0 {
+		t.Fatalf("expected 0 bytes after sorting, got %d", got)
+	}
+}
@@ -0,0 +1,52 @@
+package extsort_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestMergeBufferBytes verifies that records larger than the per-chunk read buffer
+// are merged correctly and that the merge buffers stay within the cap.
+func TestMergeBufferBytes(t *testing.T) {
+	const count = 200
+	data := make([]string, count)
+	for i := range data {
+		// records of 10-30KB, far larger than the read buffer of each chunk
+		data[i] = fmt.Sprintf("%05d", (i*7919)%count) + strings.Repeat("x", 10000+(i%3)*10000)
+	}
+	inputChan := make(chan string, len(data))
+	for _, s := range data {
+		inputChan <- s
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 10
+	config.SortedChanBuffSize = 0
+	config.MergeBufferBytes = 4096
+
+	sorter, outChan, errChan := extsort.Strings(inputChan, config)
+	sorter.Sort(context.Background())
+
+	first, ok := <-outChan
+	if !ok {
+		t.Fatalf("no output: %v", <-errChan)
+	}
+	if got := sorter.MemUsageBytes(); got > int64(config.MergeBufferBytes) {
+		t.Errorf("expected at most %d bytes of merge buffers, got %d", config.MergeBufferBytes, got)
+	}
+
+	rest, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	got := append([]string{first}, rest...)
+	if !slices.Equal(got, slices.Sorted(slices.Values(data))) {
+		t.Fatal("output does not match sorted input")
+	}
+}
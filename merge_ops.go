@@ -0,0 +1,59 @@
+package extsort
+
+// MergeOp describes one merge performed by a sort. Runs are numbered in the order
+// they are created: every chunk spilled to temporary storage is a run, and so is
+// the output of every merge except the final one. Together the merge operations
+// form a tree whose leaves are the spilled chunks and whose root is the final merge,
+// which shows how a configuration combined its runs, for example through
+// Config.MaxRunsBeforeMerge or the parallel merge workers started by NumWorkers.
+type MergeOp struct {
+	// Inputs lists the runs merged, in the order used to break ties between equal records.
+	Inputs []int
+	// Output is the run created by the merge, or -1 for the final merge into the
+	// sorted output.
+	Output int
+	// Records is the number of records in the input runs.
+	Records int64
+	// Bytes is the serialized size of the input runs, before any ChunkTransformWrite.
+	Bytes int64
+}
+
+// runInfo describes a run in the current temporary file.
+type runInfo struct {
+	id      int
+	records int64
+	bytes   int64
+}
+
+// MergeOps returns the merges performed by the sort, in the order they started.
+// It returns nil when the input was sorted entirely in memory. It must only be
+// called once the output channel has been closed.
+func (s *GenericSorter[E]) MergeOps() []MergeOp {
+	return s.mergeOps
+}
+
+// newRunID returns the id of a newly created run.
+func (s *GenericSorter[E]) newRunID() int {
+	id := s.nextRun
+	s.nextRun++
+	return id
+}
+
+// recordMerge records the merge of runs into a run of the given id, or into the
+// sorted output if output is -1, and returns the merged size as a run.
+func (s *GenericSorter[E]) recordMerge(runs []runInfo, output int) runInfo {
+	op := MergeOp{Inputs: make([]int, 0, len(runs)), Output: output}
+	for _, r := range runs {
+		op.Inputs = append(op.Inputs, r.id)
+		op.Records += r.records
+		op.Bytes += r.bytes
+	}
+	s.mergeOps = append(s.mergeOps, op)
+	return runInfo{id: output, records: op.Records, bytes: op.Bytes}
+}
+
+// chunkRuns returns the runs stored at positions [start, end) of the temporary
+// file, ignoring the empty trailing section added when it is saved.
+func (s *GenericSorter[E]) chunkRuns(start, end int) []runInfo {
+	return s.runs[min(start, len(s.runs)):min(end, len(s.runs))]
+}
@@ -0,0 +1,56 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestMergeOps verifies the merge tree reported for the in-memory, single-threaded,
+// parallel and intermediate merge paths.
+func TestMergeOps(t *testing.T) {
+	// every record is serialized as 8 bytes plus a 1 byte length prefix
+	const recordBytes = 9
+	for _, tc := range []struct {
+		name                string
+		n, numWorkers, runs int
+		want                []extsort.MergeOp
+	}{
+		{"in memory", 50, 2, 0, nil},
+		{"single-threaded", 500, 10, 0, []extsort.MergeOp{
+			{Inputs: []int{0, 1, 2, 3, 4}, Output: -1, Records: 500, Bytes: 500 * recordBytes},
+		}},
+		{"parallel", 500, 2, 0, []extsort.MergeOp{
+			{Inputs: []int{0, 1, 2}, Output: 5, Records: 300, Bytes: 300 * recordBytes},
+			{Inputs: []int{3, 4}, Output: 6, Records: 200, Bytes: 200 * recordBytes},
+			{Inputs: []int{5, 6}, Output: -1, Records: 500, Bytes: 500 * recordBytes},
+		}},
+		{"intermediate", 500, 10, 3, []extsort.MergeOp{
+			{Inputs: []int{0, 1, 2}, Output: 3, Records: 300, Bytes: 300 * recordBytes},
+			{Inputs: []int{3, 4, 5}, Output: 6, Records: 500, Bytes: 500 * recordBytes},
+			{Inputs: []int{6}, Output: -1, Records: 500, Bytes: 500 * recordBytes},
+		}},
+	} {
+		inputChan := make(chan int, tc.n)
+		for i := tc.n; i > 0; i-- {
+			inputChan <- i
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 100
+		config.NumWorkers = tc.numWorkers
+		config.MaxRunsBeforeMerge = tc.runs
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+		if _, err := extsort.Collect(outChan, errChan, 0); err != nil {
+			t.Fatalf("%s: sort error: %v", tc.name, err)
+		}
+		if got := sorter.MergeOps(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected merge ops %+v, got %+v", tc.name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestMergeEqualOrder verifies that records comparing equal are merged in input order
+// across chunks, for both the single-threaded and the parallel merge.
+func TestMergeEqualOrder(t *testing.T) {
+	const n = 2000
+	const keys = 10
+	// only the last digit is compared, the full value is the payload
+	compare := func(a, b int) int {
+		return cmp.Compare(a%keys, b%keys)
+	}
+
+	for _, numWorkers := range []int{4, 500} {
+		inputChan := make(chan int, n)
+		for i := 0; i < n; i++ {
+			inputChan <- i
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		// every chunk holds each key exactly once, so only the merge orders equal records
+		config.ChunkSize = keys
+		config.NumWorkers = numWorkers
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, compare, config)
+		sorter.Sort(context.Background())
+
+		var result []int
+		for v := range outChan {
+			result = append(result, v)
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("workers %d: sort error: %v", numWorkers, err)
+		}
+		if len(result) != n {
+			t.Fatalf("workers %d: expected %d records, got %d", numWorkers, n, len(result))
+		}
+		for i, v := range result {
+			if want := (i%(n/keys))*keys + i/(n/keys); v != want {
+				t.Fatalf("workers %d: expected %d at position %d, got %d", numWorkers, want, i, v)
+			}
+		}
+	}
+}
+
+// TestMergeEqualOrderLegacy verifies that the merge takes equal records in input
+// order with the legacy New API, whose less function cannot report equality directly.
+func TestMergeEqualOrderLegacy(t *testing.T) {
+	const n = 200
+	const keys = 10
+	inputChan := make(chan extsort.SortType, n)
+	for i := 0; i < n; i++ {
+		inputChan <- val{Key: i % keys, Order: i}
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = keys
+	sorter, outChan, errChan := extsort.New(inputChan, fromBytesForTest, KeyLessThan, config)
+	sorter.Sort(context.Background())
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if len(result) != n {
+		t.Fatalf("expected %d records, got %d", n, len(result))
+	}
+	for i := 1; i < len(result); i++ {
+		if !KeyOrderLessThan(result[i-1], result[i]) {
+			t.Fatalf("%+v emitted before %+v at position %d", result[i-1], result[i], i)
+		}
+	}
+}
+
+// TestStalledChunkBoundsReading verifies that while the sort of one chunk stalls,
+// the sorter stops reading input instead of piling up the chunks sorted after it.
+func TestStalledChunkBoundsReading(t *testing.T) {
+	const n = 10000
+	const chunkSize = 10
+	var read atomic.Int64
+	inputChan := make(chan int)
+	go func() {
+		for i := n - 1; i >= 0; i-- {
+			inputChan <- i
+			read.Add(1)
+		}
+		close(inputChan)
+	}()
+
+	release := make(chan struct{})
+	compare := func(a, b int) int {
+		// the first chunk holds the records above n-chunkSize
+		if a >= n-chunkSize || b >= n-chunkSize {
+			<-release
+		}
+		return cmp.Compare(a, b)
+	}
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = chunkSize
+	config.NumWorkers = 2
+	config.ChanBuffSize = 1
+	config.MaxPendingChunks = 1
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, compare, config)
+	go sorter.Sort(context.Background())
+
+	time.Sleep(200 * time.Millisecond)
+	// the save window holds 5 chunks here (2 workers, 1 queued on each side, 1 more),
+	// plus the chunk being filled, with one chunk of slack
+	if got, limit := read.Load(), int64(7*chunkSize); got > limit {
+		t.Errorf("read %d records while a chunk sort stalled, want at most %d", got, limit)
+	}
+	close(release)
+
+	want := 0
+	for v := range outChan {
+		if v != want {
+			t.Fatalf("expected %d, got %d", want, v)
+		}
+		want++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if want != n {
+		t.Fatalf("expected %d records, got %d", n, want)
+	}
+}
@@ -0,0 +1,107 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestMergeStrategy(t *testing.T) {
+	// many records share a key, so the order of equal records is checked too
+	compareTens := func(a, b int) int { return cmp.Compare(a/10, b/10) }
+	data := generateRandomInts(3000)
+	for i := range data {
+		data[i] %= 5000
+	}
+	expected := slices.Clone(data)
+	slices.SortStableFunc(expected, compareTens)
+
+	for _, chunkSize := range []int{3000, 1500, 430, 97} {
+		for _, workers := range []int{1, 4} {
+			inputChan := make(chan int, len(data))
+			for _, v := range data {
+				inputChan <- v
+			}
+			close(inputChan)
+
+			config := extsort.DefaultConfig()
+			config.ChunkSize = chunkSize
+			config.NumWorkers = workers
+			config.Stable = true
+			config.MergeStrategy = extsort.MergeLoserTree
+			sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, compareTens, config)
+			sorter.Sort(context.Background())
+			result, err := extsort.Collect(outChan, errChan, 0)
+			if err != nil {
+				t.Fatalf("chunk size %d, %d workers: sort error: %v", chunkSize, workers, err)
+			}
+			if !slices.Equal(result, expected) {
+				t.Fatalf("chunk size %d, %d workers: output does not match a stable sort", chunkSize, workers)
+			}
+		}
+	}
+}
+
+// BenchmarkMergeStrategy merges fan-in sorted files of random records with each
+// strategy, reporting the comparisons made per merged record.
+func BenchmarkMergeStrategy(b *testing.B) {
+	const records = 1 << 17
+	for _, fanIn := range []int{2, 16, 256} {
+		var paths []string
+		for range fanIn {
+			paths = append(paths, sortIntsToFile(b, generateRandomInts(records/fanIn), nil))
+		}
+		for _, strategy := range []struct {
+			name     string
+			strategy extsort.MergeStrategy
+		}{{"heap", extsort.MergeHeap}, {"losertree", extsort.MergeLoserTree}} {
+			b.Run(fmt.Sprintf("fanin=%d/%s", fanIn, strategy.name), func(b *testing.B) {
+				compareFunc, comparisons := extsort.CountingCompare(cmp.Compare[int])
+				config := extsort.DefaultConfig()
+				config.MergeStrategy = strategy.strategy
+				config.MaxRunsBeforeMerge = fanIn // merge every file in a single pass
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					outChan, errChan := extsort.MergeFiles(context.Background(), paths, intFromBytes, compareFunc, false, config)
+					for range outChan {
+					}
+					if err := <-errChan; err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(comparisons.Load())/float64(b.N)/records, "cmps/record")
+			})
+		}
+	}
+}
+
+// BenchmarkSingleRunMerge sorts an input of two chunks that are merged into a single
+// run while the input is read, so the final merge streams one run without the heap.
+// Chunk sorting and the intermediate merge dominate its time, so streaming the run
+// directly makes no measurable difference here; it guards against regressions.
+func BenchmarkSingleRunMerge(b *testing.B) {
+	data := generateRandomInts(200000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 100000
+		config.MaxRunsBeforeMerge = 2
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+		for range outChan {
+		}
+		if err := <-errChan; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package extsort
+
+// MinMax reads every record from input in a single pass and returns the smallest
+// and largest records according to compareFunc, along with the number of records read.
+// Nothing is buffered or spilled, so it is a cheap way to find the range of a dataset,
+// for example to plan key ranges before sorting. When several records compare equal
+// to the minimum or maximum, the first one read is returned. If input is empty, the
+// zero values of E are returned with a count of 0. An error is returned only if
+// compareFunc, wrapped with FallibleCompare, reports one, in which case reading stops
+// and the records read so far are summarized.
+func MinMax[E any](input <-chan E, compareFunc CompareGeneric[E]) (min, max E, count int, err error) {
+	defer recoverCompareFailure(&err)
+	for rec := range input {
+		if count == 0 {
+			min, max = rec, rec
+		} else if compareFunc(rec, min) < 0 {
+			min = rec
+		} else if compareFunc(rec, max) > 0 {
+			max = rec
+		}
+		count++
+	}
+	return min, max, count, nil
+}
+
+// IsSorted reads records from input and reports whether they are in non-decreasing
+// order according to compareFunc, for example to validate the inputs of MergeFiles.
+// Only the previous record is kept, so memory use is constant. It stops reading at
+// the first record that is smaller than its predecessor, leaving the rest of input
+// unread. An error is returned only if compareFunc, wrapped with FallibleCompare,
+// reports one. An empty input is sorted.
+func IsSorted[E any](input <-chan E, compareFunc CompareGeneric[E]) (sorted bool, err error) {
+	defer recoverCompareFailure(&err)
+	var prev E
+	first := true
+	for rec := range input {
+		if !first && compareFunc(prev, rec) > 0 {
+			return false, nil
+		}
+		prev, first = rec, false
+	}
+	return true, nil
+}
@@ -0,0 +1,133 @@
+package extsort_test
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestMinMax(t *testing.T) {
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	min, max, count, err := extsort.MinMax(inputChan, cmp.Compare[int])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != len(data) {
+		t.Fatalf("expected count %d, got %d", len(data), count)
+	}
+	if want := slices.Min(data); min != want {
+		t.Errorf("expected min %d, got %d", want, min)
+	}
+	if want := slices.Max(data); max != want {
+		t.Errorf("expected max %d, got %d", want, max)
+	}
+}
+
+func TestMinMaxEmpty(t *testing.T) {
+	inputChan := make(chan string)
+	close(inputChan)
+
+	min, max, count, err := extsort.MinMax(inputChan, cmp.Compare[string])
+	if err != nil || min != "" || max != "" || count != 0 {
+		t.Fatalf("expected zero values, got %q %q %d %v", min, max, count, err)
+	}
+}
+
+func TestMinMaxTies(t *testing.T) {
+	type rec struct{ key, id int }
+	inputChan := make(chan rec, 4)
+	for _, r := range []rec{{1, 0}, {1, 1}, {0, 2}, {0, 3}} {
+		inputChan <- r
+	}
+	close(inputChan)
+
+	min, max, count, err := extsort.MinMax(inputChan, func(a, b rec) int { return cmp.Compare(a.key, b.key) })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 4 {
+		t.Fatalf("expected count 4, got %d", count)
+	}
+	if min.id != 2 || max.id != 0 {
+		t.Fatalf("expected the first of equal records, got min %+v max %+v", min, max)
+	}
+}
+
+func TestMinMaxCompareError(t *testing.T) {
+	errBad := errors.New("bad record")
+	inputChan := make(chan int, 3)
+	inputChan <- 1
+	inputChan <- -1
+	inputChan <- 2
+	close(inputChan)
+
+	compareFunc := extsort.FallibleCompare(func(a, b int) (int, error) {
+		if a < 0 || b < 0 {
+			return 0, errBad
+		}
+		return cmp.Compare(a, b), nil
+	})
+	if _, _, _, err := extsort.MinMax(inputChan, compareFunc); !errors.Is(err, errBad) {
+		t.Fatalf("expected compare error, got %v", err)
+	}
+}
+
+func TestIsSortedChan(t *testing.T) {
+	tests := []struct {
+		data     []int
+		sorted   bool
+		consumed int
+	}{
+		{nil, true, 0},
+		{[]int{1}, true, 1},
+		{[]int{1, 1, 2, 3, 3}, true, 5},
+		{[]int{1, 3, 2, 4, 5}, false, 3},
+	}
+	for _, tt := range tests {
+		inputChan := make(chan int, len(tt.data))
+		for _, v := range tt.data {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		sorted, err := extsort.IsSorted(inputChan, cmp.Compare[int])
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.data, err)
+		}
+		if sorted != tt.sorted {
+			t.Errorf("%v: expected sorted=%v, got %v", tt.data, tt.sorted, sorted)
+		}
+		// reading stops at the first violation
+		if remaining := len(tt.data) - tt.consumed; len(inputChan) != remaining {
+			t.Errorf("%v: expected %d unread records, got %d", tt.data, remaining, len(inputChan))
+		}
+	}
+}
+
+func TestIsSortedCompareError(t *testing.T) {
+	errBad := errors.New("bad record")
+	inputChan := make(chan int, 3)
+	inputChan <- 1
+	inputChan <- -1
+	inputChan <- 2
+	close(inputChan)
+
+	compareFunc := extsort.FallibleCompare(func(a, b int) (int, error) {
+		if a < 0 || b < 0 {
+			return 0, errBad
+		}
+		return cmp.Compare(a, b), nil
+	})
+	if _, err := extsort.IsSorted(inputChan, compareFunc); !errors.Is(err, errBad) {
+		t.Fatalf("expected compare error, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package extsort_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// sortWithNil sorts a few values with a nil item in the middle using the given policy.
+func sortWithNil(t *testing.T, policy extsort.NilItemPolicy) ([]val, error) {
+	t.Helper()
+	inputChan := make(chan extsort.SortType, 10)
+	inputChan <- val{Key: 3}
+	inputChan <- val{Key: 1}
+	inputChan <- nil
+	inputChan <- val{Key: 2}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 2
+	config.OnNilItem = policy
+
+	sort, outChan, errChan := extsort.New(inputChan, fromBytesForTest, KeyLessThan, config)
+	sort.Sort(context.Background())
+	var result []val
+	for rec := range outChan {
+		result = append(result, rec.(val))
+	}
+	return result, <-errChan
+}
+
+func TestNilItemError(t *testing.T) {
+	_, err := sortWithNil(t, extsort.NilItemError)
+	if !errors.Is(err, extsort.ErrNilItem) {
+		t.Fatalf("expected ErrNilItem, got %v", err)
+	}
+}
+
+func TestNilItemSkip(t *testing.T) {
+	result, err := sortWithNil(t, extsort.NilItemSkip)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if len(result) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(result))
+	}
+	for i, v := range result {
+		if v.Key != i+1 {
+			t.Errorf("expected key %d at position %d, got %d", i+1, i, v.Key)
+		}
+	}
+}
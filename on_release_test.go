@@ -0,0 +1,120 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"sync"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// pooledRecord stands in for a record holding a pooled buffer.
+type pooledRecord struct {
+	v          int
+	serialized bool
+	released   int
+}
+
+func pooledRecordToBytes(r *pooledRecord) ([]byte, error) {
+	if r.released > 0 {
+		panic("serializing a released record")
+	}
+	r.serialized = true
+	return binary.AppendVarint(nil, int64(r.v)), nil
+}
+
+func pooledRecordFromBytes(b []byte) (*pooledRecord, error) {
+	v, _ := binary.Varint(b)
+	return &pooledRecord{v: int(v)}, nil
+}
+
+func comparePooledRecords(a, b *pooledRecord) int {
+	return cmp.Compare(a.v, b.v)
+}
+
+// sortPooledRecords sorts n records in reverse order and returns the input
+// records and the output values.
+func sortPooledRecords(t *testing.T, n int, config *extsort.Config, onRelease func(*pooledRecord)) ([]*pooledRecord, []int) {
+	t.Helper()
+	records := make([]*pooledRecord, n)
+	inputChan := make(chan *pooledRecord, n)
+	for i := range records {
+		records[i] = &pooledRecord{v: n - i}
+		inputChan <- records[i]
+	}
+	close(inputChan)
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, pooledRecordFromBytes, pooledRecordToBytes, comparePooledRecords, config)
+	sorter.SetOnRelease(onRelease)
+	sorter.Sort(context.Background())
+	var out []int
+	for r := range outChan {
+		out = append(out, r.v)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	return records, out
+}
+
+// TestOnRelease verifies that every spilled record is released exactly once,
+// after it has been serialized.
+func TestOnRelease(t *testing.T) {
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	records, out := sortPooledRecords(t, 1000, config, func(r *pooledRecord) {
+		if !r.serialized {
+			t.Errorf("record %d released before it was serialized", r.v)
+		}
+		r.released++
+	})
+	if len(out) != 1000 {
+		t.Fatalf("expected 1000 records, got %d", len(out))
+	}
+	for i, v := range out {
+		if v != i+1 {
+			t.Fatalf("output %d: expected %d, got %d", i, i+1, v)
+		}
+	}
+	for _, r := range records {
+		if r.released != 1 {
+			t.Errorf("record %d released %d times, want 1", r.v, r.released)
+		}
+	}
+}
+
+// TestOnReleaseSingleChunk verifies that records handed to the receiver
+// without being spilled are not released.
+func TestOnReleaseSingleChunk(t *testing.T) {
+	released := 0
+	_, out := sortPooledRecords(t, 10, nil, func(*pooledRecord) { released++ })
+	if len(out) != 10 {
+		t.Fatalf("expected 10 records, got %d", len(out))
+	}
+	if released != 0 {
+		t.Errorf("expected no records released, got %d", released)
+	}
+}
+
+// TestOnReleaseLimit verifies that records cut by Config.Limit are released too.
+func TestOnReleaseLimit(t *testing.T) {
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.Limit = 10
+	var mu sync.Mutex
+	records, out := sortPooledRecords(t, 1000, config, func(r *pooledRecord) {
+		mu.Lock()
+		r.released++
+		mu.Unlock()
+	})
+	if len(out) != 10 {
+		t.Fatalf("expected 10 records, got %d", len(out))
+	}
+	for _, r := range records {
+		if r.released != 1 {
+			t.Errorf("record %d released %d times, want 1", r.v, r.released)
+		}
+	}
+}
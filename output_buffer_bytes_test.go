@@ -0,0 +1,76 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestOutputBufferBytes(t *testing.T) {
+	for _, chunkSize := range []int{100, 10000} {
+		data := generateRandomInts(5000)
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+		config.OutputBufferBytes = 64
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+		result, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+		want := slices.Clone(data)
+		slices.Sort(want)
+		if !slices.Equal(result, want) {
+			t.Fatalf("chunk size %d: output does not match sorted input", chunkSize)
+		}
+	}
+
+	inputChan := make(chan int)
+	close(inputChan)
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], &extsort.Config{OutputBufferBytes: -1})
+	sorter.Sort(context.Background())
+	_, err := extsort.Collect(outChan, errChan, 0)
+	var configErr *extsort.ConfigError
+	if !errors.As(err, &configErr) || configErr.Field != "OutputBufferBytes" {
+		t.Errorf("expected an OutputBufferBytes ConfigError, got %v", err)
+	}
+}
+
+// TestOutputBufferBytesStall verifies that the byte bound applies before the record
+// count does: 100 records fit in SortedChanBuffSize, but only 4 fit in 32 bytes, so a
+// consumer that reads nothing stalls the sort.
+func TestOutputBufferBytesStall(t *testing.T) {
+	data := generateRandomInts(100)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.OutputBufferBytes = 32
+	config.ConsumerTimeout = 50 * time.Millisecond
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, extsort.ErrConsumerStalled) {
+			t.Fatalf("expected ErrConsumerStalled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sort did not stall on the output byte bound")
+	}
+	for range outChan {
+	}
+}
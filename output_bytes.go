@@ -0,0 +1,111 @@
+package extsort
+
+import (
+	"context"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// outputRecord is a record waiting to be delivered, with the bytes it is charged.
+type outputRecord[E any] struct {
+	rec  E
+	size int64
+}
+
+// outputBuffer holds the records waiting to be delivered when Config.OutputBufferBytes
+// is set. Records are charged their serialized size against the semaphore when they
+// are queued, and credited back once the consumer has received them from the
+// unbuffered output channel.
+type outputBuffer[E any] struct {
+	bytes   *semaphore.Weighted
+	limit   int64
+	pending chan outputRecord[E]
+	stop    chan struct{} // closed when the sort aborts
+}
+
+// newOutputBuffer returns an outputBuffer of limit bytes and at most n records.
+func newOutputBuffer[E any](limit int64, n int) *outputBuffer[E] {
+	return &outputBuffer[E]{
+		bytes:   semaphore.NewWeighted(limit),
+		limit:   limit,
+		pending: make(chan outputRecord[E], n),
+		stop:    make(chan struct{}),
+	}
+}
+
+// sendBuffered queues rec for delivery once its serialized size fits in the output
+// buffer. A record larger than the whole buffer is charged the whole buffer, so it
+// waits for the buffer to empty rather than forever.
+func (s *GenericSorter[E]) sendBuffered(ctx context.Context, rec E) error {
+	ob := s.outputBuf
+	raw, err := s.toBytes(rec)
+	if err != nil {
+		return NewSerializationError(err, "OutputBufferBytes")
+	}
+	size := min(int64(len(raw)), ob.limit)
+	if !ob.bytes.TryAcquire(size) {
+		acquireCtx := ctx
+		if s.config.ConsumerTimeout > 0 {
+			var cancel context.CancelFunc
+			acquireCtx, cancel = context.WithTimeout(ctx, s.config.ConsumerTimeout)
+			defer cancel()
+		}
+		if err := ob.bytes.Acquire(acquireCtx, size); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return ErrConsumerStalled
+		}
+	}
+	select {
+	case ob.pending <- outputRecord[E]{rec: rec, size: size}:
+		return nil
+	case <-ctx.Done():
+		ob.bytes.Release(size)
+		return ctx.Err()
+	}
+}
+
+// forwardOutput delivers queued records on the output channel, crediting their
+// bytes back as each one is received, and closes the output channel once the queue
+// is closed. When ctx is done or the sort aborts, the remaining records are dropped.
+func (s *GenericSorter[E]) forwardOutput(ctx context.Context) {
+	defer close(s.mergeChunkChan)
+	for r := range s.outputBuf.pending {
+		select {
+		case s.mergeChunkChan <- r.rec:
+			s.outputBuf.bytes.Release(r.size)
+		case <-ctx.Done():
+			s.dropOutput()
+			return
+		case <-s.outputBuf.stop:
+			s.dropOutput()
+			return
+		}
+	}
+}
+
+// dropOutput discards queued records until the queue is closed.
+func (s *GenericSorter[E]) dropOutput() {
+	for range s.outputBuf.pending {
+	}
+}
+
+// closeOutput closes the output channel, or when records are buffered by bytes, the
+// queue in front of it; forwardOutput then closes the channel once it has drained.
+func (s *GenericSorter[E]) closeOutput() {
+	if s.outputBuf != nil {
+		close(s.outputBuf.pending)
+		return
+	}
+	close(s.mergeChunkChan)
+}
+
+// abortOutput closes the output like closeOutput, but without delivering the
+// records still queued in front of it.
+func (s *GenericSorter[E]) abortOutput() {
+	if s.outputBuf != nil {
+		close(s.outputBuf.stop)
+	}
+	s.closeOutput()
+}
@@ -0,0 +1,85 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestPauseResume verifies that a paused sorter stops reading its input and
+// continues once resumed.
+func TestPauseResume(t *testing.T) {
+	inputChan := make(chan int)
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 10
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	go sorter.Sort(context.Background())
+
+	const n = 100
+	next := n
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 10; i++ {
+			next--
+			inputChan <- next
+		}
+
+		sorter.Pause()
+		// a single record may already be waiting on the input
+		sent := 0
+		for sent < 2 {
+			select {
+			case inputChan <- next - 1:
+				next--
+				sent++
+				continue
+			case <-time.After(50 * time.Millisecond):
+			}
+			break
+		}
+		if sent > 1 {
+			t.Fatalf("round %d: sorter read %d records while paused", round, sent)
+		}
+		sorter.Resume()
+	}
+
+	for next > 0 {
+		next--
+		inputChan <- next
+	}
+	close(inputChan)
+
+	expected := 0
+	for v := range outChan {
+		if v != expected {
+			t.Fatalf("expected %d, got %d", expected, v)
+		}
+		expected++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if expected != n {
+		t.Fatalf("expected %d records, got %d", n, expected)
+	}
+}
+
+// TestPauseCancel verifies that cancelling the context unblocks a paused sorter.
+func TestPauseCancel(t *testing.T) {
+	inputChan := make(chan int)
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], nil)
+	sorter.Pause()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go sorter.Sort(ctx)
+	cancel()
+
+	for range outChan {
+	}
+	if err := <-errChan; err == nil {
+		t.Fatal("expected a context error")
+	}
+}
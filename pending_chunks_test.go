@@ -0,0 +1,90 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestMaxPendingChunks(t *testing.T) {
+	data := generateRandomInts(5000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.NumWorkers = 4
+	config.MaxPendingChunks = 1
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	slices.Sort(data)
+	if !slices.Equal(result, data) {
+		t.Fatal("output does not match sorted input")
+	}
+
+	inputChan = make(chan int)
+	close(inputChan)
+	sorter, outChan, errChan = extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], &extsort.Config{MaxPendingChunks: -1})
+	sorter.Sort(context.Background())
+	_, err = extsort.Collect(outChan, errChan, 0)
+	var configErr *extsort.ConfigError
+	if !errors.As(err, &configErr) || configErr.Field != "MaxPendingChunks" {
+		t.Errorf("expected a MaxPendingChunks ConfigError, got %v", err)
+	}
+}
+
+// BenchmarkSlowSpill sorts an input that takes about 1ms to produce each chunk onto
+// a backend that takes about 1ms to write each chunk. Writing in the background
+// overlaps the two, so the reported ms/chunk stays below the 2ms that writing each
+// chunk before reading the next would take.
+func BenchmarkSlowSpill(b *testing.B) {
+	const chunkSize, chunks = 1000, 20
+	slowWrite := func(d []byte) ([]byte, error) {
+		time.Sleep(time.Millisecond)
+		return d, nil
+	}
+	identity := func(d []byte) ([]byte, error) { return d, nil }
+
+	for _, pending := range []int{1, 8} {
+		b.Run(fmt.Sprintf("pending=%d", pending), func(b *testing.B) {
+			start := time.Now()
+			for range b.N {
+				inputChan := make(chan int, chunkSize)
+				go func() {
+					for i := range chunkSize * chunks {
+						if i%chunkSize == 0 {
+							time.Sleep(time.Millisecond)
+						}
+						inputChan <- chunkSize*chunks - i
+					}
+					close(inputChan)
+				}()
+
+				config := extsort.DefaultConfig()
+				config.ChunkSize = chunkSize
+				config.MaxPendingChunks = pending
+				config.ChunkTransformWrite = slowWrite
+				config.ChunkTransformRead = identity
+				sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+				sorter.Sort(context.Background())
+				if _, err := extsort.Collect(outChan, errChan, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(time.Since(start).Milliseconds())/float64(b.N)/chunks, "ms/chunk")
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestPrioritized(t *testing.T) {
+	// records are key*10+source, compared by key only
+	priorities := []int{1, 3, 2}
+	const keys = 1000
+	var inputs []extsort.PrioritizedInput[int]
+	for source, priority := range priorities {
+		in := make(chan int, keys)
+		for k := keys - 1; k >= 0; k-- {
+			in <- k*10 + source
+		}
+		close(in)
+		inputs = append(inputs, extsort.PrioritizedInput[int]{Input: in, Priority: priority})
+	}
+
+	compareKey := func(a, b int) int { return cmp.Compare(a/10, b/10) }
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 128
+	sorter, outChan, errChan := extsort.Prioritized(inputs, intFromBytes, intToBytes, compareKey, config)
+	sorter.Sort(context.Background())
+
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if len(result) != keys*len(priorities) {
+		t.Fatalf("expected %d records, got %d", keys*len(priorities), len(result))
+	}
+	// within each key, sources come by descending priority
+	sourceOrder := []int{1, 2, 0}
+	for i, v := range result {
+		key, source := i/len(priorities), sourceOrder[i%len(priorities)]
+		if v != key*10+source {
+			t.Fatalf("position %d: expected key %d from source %d, got %d", i, key, source, v)
+		}
+	}
+}
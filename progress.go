@@ -0,0 +1,91 @@
+package extsort
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// defaultProgressInterval is used when Config.ProgressInterval is not set.
+const defaultProgressInterval = time.Second
+
+// Progress is a snapshot of the progress of a sort.
+type Progress struct {
+	// RecordsRead is the number of records read from the input channel.
+	RecordsRead int64
+	// ChunksSpilled is the number of sorted chunks written to temporary storage.
+	ChunksSpilled int64
+	// BytesSpilled is the serialized size of the records written to temporary storage.
+	BytesSpilled int64
+	// RecordsEmitted is the number of records sent on the output channel.
+	RecordsEmitted int64
+	// ExpectedRecords is the total number of records to sort: Config.ExpectedCount
+	// while the input is being read, or 0 if it was not set, and the number of
+	// records actually read once the input channel has been closed.
+	ExpectedRecords int64
+}
+
+// progressCounters tracks the progress of a sort. It is kept behind a pointer so
+// that it can be read concurrently by the progress reporter.
+type progressCounters struct {
+	recordsRead    atomic.Int64
+	chunksSpilled  atomic.Int64
+	recordsEmitted atomic.Int64
+	expected       atomic.Int64
+}
+
+// Progress returns a channel that receives a snapshot of the progress of the sort
+// every Config.ProgressInterval, and a final snapshot once the sort has finished,
+// whether it succeeded or not, after which the channel is closed. The channel holds
+// a single snapshot: when it is not read in time, the pending snapshot is replaced
+// by the newer one, so an unconsumed channel never blocks the sort. Every call
+// returns the same channel. It must be called before Sort.
+func (s *GenericSorter[E]) Progress() <-chan Progress {
+	if s.progressChan == nil {
+		s.progressChan = make(chan Progress, 1)
+	}
+	return s.progressChan
+}
+
+// snapshot returns the current progress of the sort.
+func (s *GenericSorter[E]) snapshot() Progress {
+	return Progress{
+		RecordsRead:     s.progress.recordsRead.Load(),
+		ChunksSpilled:   s.progress.chunksSpilled.Load(),
+		BytesSpilled:    s.memUsage.spilledBytes.Load(),
+		RecordsEmitted:  s.progress.recordsEmitted.Load(),
+		ExpectedRecords: s.progress.expected.Load(),
+	}
+}
+
+// sendProgress delivers p on the progress channel, replacing the pending
+// snapshot if it has not been read. It must only be called by one goroutine at a time.
+func (s *GenericSorter[E]) sendProgress(p Progress) {
+	select {
+	case s.progressChan <- p:
+		return
+	default:
+	}
+	select {
+	case <-s.progressChan:
+	default:
+	}
+	s.progressChan <- p
+}
+
+// startProgress starts reporting progress if Progress was called. The returned
+// function stops reporting, sends the final snapshot and closes the channel.
+func (s *GenericSorter[E]) startProgress() (stop func()) {
+	if s.progressChan == nil {
+		return func() {}
+	}
+	interval := s.config.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	stopHeartbeat := startHeartbeat(func() { s.sendProgress(s.snapshot()) }, interval)
+	return func() {
+		stopHeartbeat()
+		s.sendProgress(s.snapshot())
+		close(s.progressChan)
+	}
+}
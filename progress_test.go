@@ -0,0 +1,90 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestProgress verifies that progress snapshots never go backwards and that the
+// final snapshot, sent before the channel closes, reflects the whole sort.
+func TestProgress(t *testing.T) {
+	inputChan := make(chan int)
+	go func() {
+		for _, v := range rand.Perm(1000) {
+			inputChan <- v
+			if v%100 == 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		close(inputChan)
+	}()
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.ProgressInterval = time.Millisecond
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	progress := sorter.Progress()
+
+	snapshots := make(chan []extsort.Progress)
+	go func() {
+		var all []extsort.Progress
+		for p := range progress {
+			all = append(all, p)
+		}
+		snapshots <- all
+	}()
+	sorter.Sort(context.Background())
+	if _, err := extsort.Collect(outChan, errChan, 0); err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+
+	all := <-snapshots
+	if len(all) < 2 {
+		t.Fatalf("expected several snapshots, got %d", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		prev, cur := all[i-1], all[i]
+		if cur.RecordsRead < prev.RecordsRead || cur.ChunksSpilled < prev.ChunksSpilled ||
+			cur.BytesSpilled < prev.BytesSpilled || cur.RecordsEmitted < prev.RecordsEmitted {
+			t.Fatalf("progress went backwards from %+v to %+v", prev, cur)
+		}
+	}
+	// every record is serialized as 8 bytes plus a 1 byte length prefix
+	want := extsort.Progress{RecordsRead: 1000, ChunksSpilled: 10, BytesSpilled: 9000, RecordsEmitted: 1000, ExpectedRecords: 1000}
+	if got := all[len(all)-1]; got != want {
+		t.Fatalf("expected final progress %+v, got %+v", want, got)
+	}
+}
+
+// TestProgressUnconsumed verifies that an unread progress channel does not block
+// the sort, and holds only the final snapshot once it is done.
+func TestProgressUnconsumed(t *testing.T) {
+	inputChan := make(chan int, 500)
+	for _, v := range rand.Perm(500) {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.ProgressInterval = time.Microsecond
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	progress := sorter.Progress()
+	sorter.Sort(context.Background())
+	if _, err := extsort.Collect(outChan, errChan, 0); err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+
+	want := extsort.Progress{RecordsRead: 500, ChunksSpilled: 5, BytesSpilled: 4500, RecordsEmitted: 500, ExpectedRecords: 500}
+	if got := <-progress; got != want {
+		t.Fatalf("expected final progress %+v, got %+v", want, got)
+	}
+	if _, ok := <-progress; ok {
+		t.Fatal("expected the progress channel to be closed")
+	}
+}
@@ -0,0 +1,13 @@
+module github.com/lanrat/extsort/proto
+
+go 1.23.0
+
+require (
+	github.com/lanrat/extsort v0.0.0
+	google.golang.org/protobuf v1.36.6
+)
+
+require golang.org/x/sync v0.16.0 // indirect
+
+// the sub-module is developed alongside the extsort module it adapts
+replace github.com/lanrat/extsort => ../
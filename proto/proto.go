@@ -0,0 +1,48 @@
+// Package proto provides external sorting for protocol buffer messages.
+// It adapts proto.Message values to the generic extsort API by using the
+// protobuf wire format for serialization, so callers only need to supply a
+// constructor for new messages and a comparison function.
+//
+// This package is a separate module from extsort so that users who do not sort
+// protobuf messages do not depend on the protobuf runtime.
+package proto
+
+import (
+	"github.com/lanrat/extsort"
+
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// marshalOptions uses deterministic marshaling so that equal messages always
+// produce identical bytes in temporary files.
+var marshalOptions = protobuf.MarshalOptions{Deterministic: true}
+
+// toBytes serializes a message using the protobuf wire format.
+func toBytes[T protobuf.Message](m T) ([]byte, error) {
+	return marshalOptions.Marshal(m)
+}
+
+// makeFromBytes returns a deserialization function that unmarshals into a
+// fresh message obtained from newT for every record.
+func makeFromBytes[T protobuf.Message](newT func() T) extsort.FromBytesGeneric[T] {
+	return func(d []byte) (T, error) {
+		m := newT()
+		err := protobuf.Unmarshal(d, m)
+		return m, err
+	}
+}
+
+// New performs external sorting on a channel of protobuf messages.
+// The newT function must return a new, empty message of type T each time it is
+// called; it is used to allocate messages when reading records back from disk.
+// Returns the sorter instance, output channel with sorted messages, and error channel.
+func New[T protobuf.Message](input <-chan T, newT func() T, compareFunc extsort.CompareGeneric[T], config *extsort.Config) (*extsort.GenericSorter[T], <-chan T, <-chan error) {
+	return extsort.Generic(input, makeFromBytes(newT), toBytes[T], compareFunc, config)
+}
+
+// Mock performs external sorting on protobuf messages using in-memory storage
+// instead of disk files. The parameter n specifies the initial capacity of the
+// in-memory buffer. All other behavior is identical to New().
+func Mock[T protobuf.Message](input <-chan T, newT func() T, compareFunc extsort.CompareGeneric[T], config *extsort.Config, n int) (*extsort.GenericSorter[T], <-chan T, <-chan error) {
+	return extsort.MockGeneric(input, makeFromBytes(newT), toBytes[T], compareFunc, config, n)
+}
@@ -0,0 +1,85 @@
+package proto_test
+
+import (
+	"cmp"
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/lanrat/extsort"
+	"github.com/lanrat/extsort/proto"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func compareInt64Value(a, b *wrapperspb.Int64Value) int {
+	return cmp.Compare(a.GetValue(), b.GetValue())
+}
+
+func newInt64Value() *wrapperspb.Int64Value {
+	return &wrapperspb.Int64Value{}
+}
+
+func TestProtoSort(t *testing.T) {
+	const n = 1000
+	inputChan := make(chan *wrapperspb.Int64Value, n)
+	for i := 0; i < n; i++ {
+		inputChan <- wrapperspb.Int64(rand.Int63n(n * 10))
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = n / 10
+
+	sorter, outChan, errChan := proto.New(inputChan, newInt64Value, compareInt64Value, config)
+	sorter.Sort(context.Background())
+
+	count := 0
+	var prev *wrapperspb.Int64Value
+	for m := range outChan {
+		if prev != nil && prev.GetValue() > m.GetValue() {
+			t.Fatalf("output not sorted: %d before %d", prev.GetValue(), m.GetValue())
+		}
+		prev = m
+		count++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if count != n {
+		t.Fatalf("expected %d messages, got %d", n, count)
+	}
+}
+
+func TestProtoSortMock(t *testing.T) {
+	values := []string{"pear", "apple", "fig", "banana", "cherry"}
+	inputChan := make(chan *wrapperspb.StringValue, len(values))
+	for _, v := range values {
+		inputChan <- wrapperspb.String(v)
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 2
+
+	sorter, outChan, errChan := proto.Mock(inputChan,
+		func() *wrapperspb.StringValue { return &wrapperspb.StringValue{} },
+		func(a, b *wrapperspb.StringValue) int { return cmp.Compare(a.GetValue(), b.GetValue()) },
+		config, 1024)
+	sorter.Sort(context.Background())
+
+	expected := []string{"apple", "banana", "cherry", "fig", "pear"}
+	i := 0
+	for m := range outChan {
+		if m.GetValue() != expected[i] {
+			t.Fatalf("expected %q at position %d, got %q", expected[i], i, m.GetValue())
+		}
+		i++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if i != len(expected) {
+		t.Fatalf("expected %d messages, got %d", len(expected), i)
+	}
+}
@@ -0,0 +1,33 @@
+package extsort
+
+import "math"
+
+// Quantiles returns the records at the quantiles listed in Config.Quantiles, in the
+// same order. It must only be called once the output channel has been closed after
+// a successful sort; it returns nil if no quantiles were requested or the input was
+// empty.
+func (s *GenericSorter[E]) Quantiles() []E {
+	if s.numRecords == 0 {
+		return nil
+	}
+	return s.quantiles
+}
+
+// observeQuantiles captures rec if its output position is the rank of a requested
+// quantile. The ranks are computed on the first call, when the input has been fully
+// read and the total number of records is known.
+func (s *GenericSorter[E]) observeQuantiles(rec E) {
+	if s.quantileRanks == nil {
+		s.quantileRanks = make([]int, len(s.config.Quantiles))
+		s.quantiles = make([]E, len(s.config.Quantiles))
+		for i, q := range s.config.Quantiles {
+			// nearest rank, counted from 1
+			s.quantileRanks[i] = max(int(math.Ceil(q*float64(s.numRecords))), 1) - 1
+		}
+	}
+	for i, rank := range s.quantileRanks {
+		if rank == s.numEmitted {
+			s.quantiles[i] = rec
+		}
+	}
+}
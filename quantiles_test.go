@@ -0,0 +1,72 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestQuantiles verifies the nearest-rank quantiles for both the single-chunk and
+// the multi-chunk merge paths.
+func TestQuantiles(t *testing.T) {
+	for _, chunkSize := range []int{100, 10000} {
+		inputChan := make(chan int, 1000)
+		for _, v := range rand.Perm(1000) {
+			inputChan <- v + 1
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+		config.Quantiles = []float64{0.5, 0, 0.25, 0.999, 0.99, 1}
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+		if _, err := extsort.Collect(outChan, errChan, 0); err != nil {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+		expected := []int{500, 1, 250, 999, 990, 1000}
+		if got := sorter.Quantiles(); !slices.Equal(got, expected) {
+			t.Errorf("chunk size %d: expected quantiles %v, got %v", chunkSize, expected, got)
+		}
+	}
+}
+
+// TestQuantilesEmpty verifies that an empty input has no quantiles.
+func TestQuantilesEmpty(t *testing.T) {
+	inputChan := make(chan int)
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.Quantiles = []float64{0.5}
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	if _, err := extsort.Collect(outChan, errChan, 0); err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if got := sorter.Quantiles(); got != nil {
+		t.Fatalf("expected no quantiles, got %v", got)
+	}
+}
+
+// TestQuantilesInvalid verifies that quantiles outside [0, 1] are rejected.
+func TestQuantilesInvalid(t *testing.T) {
+	inputChan := make(chan int)
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.Quantiles = []float64{0.5, 1.5}
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	var configErr *extsort.ConfigError
+	if _, err := extsort.Collect(outChan, errChan, 0); !errors.As(err, &configErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+}
@@ -15,7 +15,18 @@ import (
 type item[E any] struct {
 	value E
 	// The index is needed by update and is maintained by the heap.Interface methods.
-	index int // The index of the item in the heap.
+	index int    // The index of the item in the heap.
+	gen   uint64 // incremented each time the item leaves the queue, invalidating its handles
+}
+
+// Handle identifies an element pushed onto a PriorityQueue, so that its value can
+// be updated or the element removed while it is still queued. A handle is
+// invalidated once its element leaves the queue through Pop or Remove, and is
+// never revalidated, even if the queue reuses the element's storage. The zero
+// Handle is never valid.
+type Handle[E any] struct {
+	it  *item[E]
+	gen uint64
 }
 
 // innerPriorityQueue implements heap.Interface and holds Items
@@ -30,7 +41,8 @@ type innerPriorityQueue[E any] struct {
 // specifically optimized for the external sorting use case where elements need
 // to be efficiently merged from multiple sorted streams.
 type PriorityQueue[E any] struct {
-	ipq innerPriorityQueue[E]
+	ipq  innerPriorityQueue[E]
+	free []*item[E] // items released by Pop, reused by Push to avoid allocations
 }
 
 // NewPriorityQueue creates a new priority queue with the given comparison function.
@@ -46,6 +58,32 @@ func NewPriorityQueue[E any](cmpFunc func(E, E) int) *PriorityQueue[E] {
 	return &pq
 }
 
+// NewPriorityQueueLess creates a new priority queue ordered by a less function rather
+// than a comparison function returning an int, so that a comparator written in that
+// style, like extsort's CompareLessFunc, can be reused as is. The less function
+// reports whether its first argument should have higher priority (appear earlier)
+// than its second; for ascending order, use a < b.
+func NewPriorityQueueLess[E any](less func(a, b E) bool) *PriorityQueue[E] {
+	// the queue only asks whether one element comes before another, which less answers
+	return NewPriorityQueue(func(a, b E) int {
+		if less(a, b) {
+			return -1
+		}
+		return 1
+	})
+}
+
+// NewMaxPriorityQueue creates a new priority queue that returns the largest element
+// first according to cmpFunc. The cmpFunc has the same meaning as for NewPriorityQueue,
+// for example cmp.Compare(a, b), and is negated internally so that Peek() and Pop()
+// return elements in descending order. This avoids error-prone manual inversion of
+// the comparison function by callers.
+func NewMaxPriorityQueue[E any](cmpFunc func(E, E) int) *PriorityQueue[E] {
+	return NewPriorityQueue(func(a, b E) int {
+		return cmpFunc(b, a)
+	})
+}
+
 // Len returns the current number of elements in the priority queue.
 // This operation is O(1).
 func (pq *PriorityQueue[E]) Len() int {
@@ -54,20 +92,91 @@ func (pq *PriorityQueue[E]) Len() int {
 
 // Push adds a new element to the priority queue, maintaining heap properties.
 // The element will be positioned according to the comparison function provided
-// during queue creation. This operation is O(log n).
-func (pq *PriorityQueue[E]) Push(x E) {
-	var i item[E]
+// during queue creation. The returned handle may be used to update or remove the
+// element while it remains queued. This operation is O(log n).
+func (pq *PriorityQueue[E]) Push(x E) Handle[E] {
+	var i *item[E]
+	if n := len(pq.free); n > 0 {
+		i = pq.free[n-1]
+		pq.free = pq.free[:n-1]
+	} else {
+		i = &item[E]{}
+	}
 	i.value = x
 	heap.Push(&pq.ipq, i)
-	heap.Fix(&pq.ipq, i.index)
+	return Handle[E]{it: i, gen: i.gen}
 }
 
 // Pop removes and returns the highest priority element from the queue.
 // The returned element is the one that would be returned by Peek().
 // This operation is O(log n). Panics if the queue is empty.
 func (pq *PriorityQueue[E]) Pop() E {
-	item := heap.Pop(&pq.ipq).(*item[E])
-	return item.value
+	return pq.release(heap.Pop(&pq.ipq).(*item[E]))
+}
+
+// release invalidates the handles of an item that left the heap and keeps the
+// item for reuse by Push, returning its value.
+func (pq *PriorityQueue[E]) release(i *item[E]) E {
+	value := i.value
+	var zero E
+	i.value = zero // release the reference held by the reused item
+	i.gen++
+	pq.free = append(pq.free, i)
+	return value
+}
+
+// Contains reports whether the element identified by h is still in the queue.
+// This operation is O(1).
+func (pq *PriorityQueue[E]) Contains(h Handle[E]) bool {
+	return h.it != nil && h.it.gen == h.gen && h.it.index >= 0 &&
+		h.it.index < len(pq.ipq.items) && pq.ipq.items[h.it.index] == h.it
+}
+
+// Value returns the value of the element identified by h, and false if the
+// element is no longer in the queue. This operation is O(1).
+func (pq *PriorityQueue[E]) Value(h Handle[E]) (E, bool) {
+	if !pq.Contains(h) {
+		var zero E
+		return zero, false
+	}
+	return h.it.value, true
+}
+
+// DecreaseKey replaces the value of the element identified by h with v, which
+// should have a higher priority (compare lower) than the value it replaces, as
+// when a shorter path to a node is found. It returns false, leaving the queue
+// unchanged, if the element is no longer in the queue. This operation is O(log n).
+func (pq *PriorityQueue[E]) DecreaseKey(h Handle[E], v E) bool {
+	return pq.update(h, v)
+}
+
+// IncreaseKey replaces the value of the element identified by h with v, which
+// should have a lower priority (compare higher) than the value it replaces. It
+// returns false, leaving the queue unchanged, if the element is no longer in the
+// queue. This operation is O(log n).
+func (pq *PriorityQueue[E]) IncreaseKey(h Handle[E], v E) bool {
+	return pq.update(h, v)
+}
+
+// update replaces the value of a queued element and restores the heap property,
+// which holds whichever way the priority moved.
+func (pq *PriorityQueue[E]) update(h Handle[E], v E) bool {
+	if !pq.Contains(h) {
+		return false
+	}
+	h.it.value = v
+	heap.Fix(&pq.ipq, h.it.index)
+	return true
+}
+
+// Remove removes the element identified by h from the queue and returns its value,
+// or returns false if the element is no longer in the queue. This operation is O(log n).
+func (pq *PriorityQueue[E]) Remove(h Handle[E]) (E, bool) {
+	if !pq.Contains(h) {
+		var zero E
+		return zero, false
+	}
+	return pq.release(heap.Remove(&pq.ipq, h.it.index).(*item[E])), true
 }
 
 // Peek returns the highest priority element without removing it from the queue.
@@ -85,6 +194,15 @@ func (pq *PriorityQueue[E]) PeekUpdate() {
 	heap.Fix(&pq.ipq, 0)
 }
 
+// Reorder replaces the comparison function and re-establishes the heap property
+// for all elements under the new ordering, for example after the priorities of the
+// elements have been rescored. The cmpFunc has the same meaning as for
+// NewPriorityQueue. This operation is O(n), cheaper than rebuilding the queue.
+func (pq *PriorityQueue[E]) Reorder(cmpFunc func(E, E) int) {
+	pq.ipq.compareFunc = cmpFunc
+	heap.Init(&pq.ipq)
+}
+
 // Print outputs the current contents of the priority queue to stdout.
 // Note that elements are printed in heap order, not priority order.
 // This method is primarily intended for debugging purposes.
@@ -113,10 +231,9 @@ func (pq *innerPriorityQueue[E]) Swap(i, j int) {
 }
 
 func (pq *innerPriorityQueue[E]) Push(x any) {
-	n := len(pq.items)
-	i := x.(item[E])
-	i.index = n
-	pq.items = append(pq.items, &i)
+	i := x.(*item[E])
+	i.index = len(pq.items)
+	pq.items = append(pq.items, i)
 }
 
 func (pq *innerPriorityQueue[E]) Pop() any {
@@ -69,3 +69,159 @@ func Test(t *testing.T) {
 		}
 	}
 }
+
+func TestMaxPriorityQueue(t *testing.T) {
+	q := queue.NewMaxPriorityQueue(cmp.Compare[int])
+	for _, v := range []int{5, 1, 9, 3, 7, 9, 0} {
+		q.Push(v)
+	}
+	expected := []int{9, 9, 7, 5, 3, 1, 0}
+	for i, want := range expected {
+		if x := q.Peek(); x != want {
+			t.Fatalf("%d.th peek got %d; want %d", i, x, want)
+		}
+		if x := q.Pop(); x != want {
+			t.Fatalf("%d.th pop got %d; want %d", i, x, want)
+		}
+	}
+	if l := q.Len(); l != 0 {
+		t.Fatalf("queue len is %d, expected %d", l, 0)
+	}
+}
+
+func TestPriorityQueueLess(t *testing.T) {
+	q := queue.NewPriorityQueueLess(func(a, b int) bool { return a < b })
+	for _, v := range []int{5, 1, 9, 3, 7, 9, 0} {
+		q.Push(v)
+	}
+	expected := []int{0, 1, 3, 5, 7, 9, 9}
+	for i, want := range expected {
+		if x := q.Pop(); x != want {
+			t.Fatalf("%d.th pop got %d; want %d", i, x, want)
+		}
+	}
+	if l := q.Len(); l != 0 {
+		t.Fatalf("queue len is %d, expected %d", l, 0)
+	}
+}
+
+func TestMaxPriorityQueuePeekUpdate(t *testing.T) {
+	values := []int{4, 8, 2}
+	q := queue.NewMaxPriorityQueue(func(a, b *int) int { return cmp.Compare(*a, *b) })
+	for i := range values {
+		q.Push(&values[i])
+	}
+	top := q.Peek()
+	*top = 1 // the largest element becomes the smallest
+	q.PeekUpdate()
+	if x := *q.Pop(); x != 4 {
+		t.Fatalf("pop after update got %d; want %d", x, 4)
+	}
+}
+
+func TestReorder(t *testing.T) {
+	q := queue.NewPriorityQueue(cmp.Compare[int])
+	for _, v := range []int{5, 1, 9, 3, 7, 9, 0} {
+		q.Push(v)
+	}
+	if x := q.Pop(); x != 0 {
+		t.Fatalf("pop before reorder got %d; want %d", x, 0)
+	}
+	// rescore by distance from 6, breaking ties by value
+	q.Reorder(func(a, b int) int {
+		da, db := a-6, b-6
+		return cmp.Or(cmp.Compare(da*da, db*db), cmp.Compare(a, b))
+	})
+	q.Push(6)
+	expected := []int{6, 5, 7, 3, 9, 9, 1}
+	for i, want := range expected {
+		if x := q.Pop(); x != want {
+			t.Fatalf("%d.th pop got %d; want %d", i, x, want)
+		}
+	}
+	if l := q.Len(); l != 0 {
+		t.Fatalf("queue len is %d, expected %d", l, 0)
+	}
+}
+
+// BenchmarkMerge10 simulates a 10-stream merge where the head of each stream is
+// popped and replaced by the next value from the same stream.
+func BenchmarkMerge10(b *testing.B) {
+	const streams = 10
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		q := queue.NewPriorityQueue(cmp.Compare[int])
+		for s := 0; s < streams; s++ {
+			q.Push(s)
+		}
+		for n := 0; n < 1000; n++ {
+			q.Push(q.Pop() + streams)
+		}
+	}
+}
+
+func TestDecreaseIncreaseKey(t *testing.T) {
+	q := queue.NewPriorityQueue(cmp.Compare[int])
+	handles := make(map[int]queue.Handle[int])
+	for _, v := range []int{50, 10, 40, 20, 30} {
+		handles[v] = q.Push(v)
+	}
+	if !q.DecreaseKey(handles[40], 5) {
+		t.Fatal("DecreaseKey on a queued element failed")
+	}
+	if !q.IncreaseKey(handles[10], 45) {
+		t.Fatal("IncreaseKey on a queued element failed")
+	}
+	if v, ok := q.Value(handles[40]); !ok || v != 5 {
+		t.Fatalf("Value after DecreaseKey got %d, %t; want 5, true", v, ok)
+	}
+	expected := []int{5, 20, 30, 45, 50}
+	for i, want := range expected {
+		if x := q.Pop(); x != want {
+			t.Fatalf("%d.th pop got %d; want %d", i, x, want)
+		}
+	}
+}
+
+func TestHandleInvalidation(t *testing.T) {
+	q := queue.NewPriorityQueue(cmp.Compare[int])
+	a := q.Push(1)
+	b := q.Push(2)
+	c := q.Push(3)
+
+	if x := q.Pop(); x != 1 {
+		t.Fatalf("pop got %d; want %d", x, 1)
+	}
+	if v, ok := q.Remove(c); !ok || v != 3 {
+		t.Fatalf("Remove got %d, %t; want 3, true", v, ok)
+	}
+	// new elements reuse the storage of the popped and removed ones
+	d := q.Push(4)
+	e := q.Push(0)
+
+	for name, h := range map[string]queue.Handle[int]{"popped": a, "removed": c, "zero": {}} {
+		if q.Contains(h) {
+			t.Errorf("%s handle is still valid", name)
+		}
+		if q.DecreaseKey(h, -1) || q.IncreaseKey(h, 10) {
+			t.Errorf("%s handle updated the queue", name)
+		}
+		if _, ok := q.Remove(h); ok {
+			t.Errorf("%s handle removed an element", name)
+		}
+	}
+	for _, h := range []queue.Handle[int]{b, d, e} {
+		if !q.Contains(h) {
+			t.Error("handle of a queued element is not valid")
+		}
+	}
+	if l := q.Len(); l != 3 {
+		t.Fatalf("queue len is %d, expected %d", l, 3)
+	}
+	expected := []int{0, 2, 4}
+	for i, want := range expected {
+		if x := q.Pop(); x != want {
+			t.Fatalf("%d.th pop got %d; want %d", i, x, want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package extsort
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a minimal token bucket rate limiter used to throttle output.
+// Tokens are refilled continuously at rate per second up to burst.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64   // tokens added per second
+	burst  float64   // maximum number of tokens held
+	tokens float64   // tokens currently available
+	last   time.Time // time tokens was last refilled
+}
+
+// newTokenBucket returns a full token bucket allowing rate events per second.
+// The burst allows 100ms worth of events, but at least one.
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := max(1, rate/10)
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a token is available and consumes it.
+// It returns the context error if ctx is done first.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	// reserve the token now, possibly going into debt, so concurrent waiters queue up fairly
+	b.tokens--
+	deficit := -b.tokens
+	b.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(deficit / b.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// return the unused token
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,74 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestOutputRateLimit verifies that output is throttled to the configured rate.
+func TestOutputRateLimit(t *testing.T) {
+	for _, chunkSize := range []int{30, 1000} {
+		data := generateRandomInts(100)
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+		config.OutputRateLimit = 200 // burst of 20, so the remaining 80 take at least 400ms
+
+		start := time.Now()
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+		count := 0
+		for range outChan {
+			count++
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+		elapsed := time.Since(start)
+
+		if count != len(data) {
+			t.Fatalf("chunk size %d: expected %d records, got %d", chunkSize, len(data), count)
+		}
+		if elapsed < 350*time.Millisecond {
+			t.Errorf("chunk size %d: expected output to take at least 350ms, took %v", chunkSize, elapsed)
+		}
+	}
+}
+
+// TestOutputRateLimitCancel verifies that cancellation interrupts a throttled send.
+func TestOutputRateLimitCancel(t *testing.T) {
+	inputChan := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		inputChan <- i
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.OutputRateLimit = 0.1 // one record every 10 seconds
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(ctx)
+
+	<-outChan
+	start := time.Now()
+	cancel()
+	for range outChan {
+	}
+	if err := <-errChan; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected cancellation to interrupt throttling promptly, took %v", elapsed)
+	}
+}
@@ -0,0 +1,55 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// sortCountingTempFiles sorts data with intermediate merges after every two runs and
+// returns the number of temporary files created.
+func sortCountingTempFiles(t *testing.T, data []int, reuse bool) int64 {
+	t.Helper()
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	var created atomic.Int64
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.MaxRunsBeforeMerge = 2
+	config.ReuseTempFiles = reuse
+	config.TempFilesDir = t.TempDir()
+	config.TempFileID = func() string {
+		return fmt.Sprint(created.Add(1))
+	}
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	want := slices.Clone(data)
+	slices.Sort(want)
+	if !slices.Equal(result, want) {
+		t.Fatal("output does not match sorted input")
+	}
+	return created.Load()
+}
+
+func TestReuseTempFiles(t *testing.T) {
+	data := generateRandomInts(2000)
+	if n := sortCountingTempFiles(t, data, false); n <= 2 {
+		t.Fatalf("expected a temporary file per merge without reuse, got %d files", n)
+	}
+	if n := sortCountingTempFiles(t, data, true); n > 2 {
+		t.Errorf("expected at most 2 temporary files with reuse, got %d", n)
+	}
+}
@@ -0,0 +1,88 @@
+package extsort
+
+import (
+	"context"
+	"sync"
+)
+
+// Ring keeps the most recent records received from a channel in a buffer of fixed
+// size, overwriting the oldest record once the buffer is full. It is meant for
+// monitoring, where only a recent window of sorted output matters: the records it
+// overwrites are intentionally dropped, and Dropped reports how many were lost.
+// A Ring is safe for concurrent use.
+type Ring[E any] struct {
+	mu      sync.Mutex
+	buf     []E
+	start   int // index of the oldest record
+	n       int // number of records held
+	dropped int64
+	done    chan struct{}
+}
+
+// RingChan returns a Ring holding the last size records received from in. The
+// channel is read in a background goroutine, which never blocks the sender, until
+// it is closed. It panics if size is less than 1.
+func RingChan[E any](in <-chan E, size int) *Ring[E] {
+	if size < 1 {
+		panic("extsort: ring size must be at least 1")
+	}
+	r := &Ring[E]{buf: make([]E, size), done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		for rec := range in {
+			r.push(rec)
+		}
+	}()
+	return r
+}
+
+// push adds rec as the newest record, overwriting the oldest one if the ring is full.
+func (r *Ring[E]) push(rec E) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.n < len(r.buf) {
+		r.buf[(r.start+r.n)%len(r.buf)] = rec
+		r.n++
+		return
+	}
+	r.buf[r.start] = rec
+	r.start = (r.start + 1) % len(r.buf)
+	r.dropped++
+}
+
+// Snapshot returns a copy of the records currently held, oldest first, so records
+// from sorted output are in sorted order.
+func (r *Ring[E]) Snapshot() []E {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]E, r.n)
+	for i := range out {
+		out[i] = r.buf[(r.start+i)%len(r.buf)]
+	}
+	return out
+}
+
+// Dropped returns the number of records overwritten so far.
+func (r *Ring[E]) Dropped() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// Done returns a channel that is closed once the input channel has been closed and
+// every record has been received.
+func (r *Ring[E]) Done() <-chan struct{} {
+	return r.done
+}
+
+// SortRing sorts like Sort, and delivers the sorted output into a Ring of the given
+// size instead of the record channel, as by RingChan. Only the last size records of
+// the output are kept; the rest are dropped. Because the ring never blocks, the sort
+// is never slowed by its consumer. The returned error channel is the one returned
+// when the sorter was created. Once SortRing is called, the record channel returned
+// when the sorter was created must not be read.
+func (s *GenericSorter[E]) SortRing(ctx context.Context, size int) (*Ring[E], <-chan error) {
+	r := RingChan(s.mergeChunkChan, size)
+	s.Sort(ctx)
+	return r, s.mergeErrChan
+}
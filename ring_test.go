@@ -0,0 +1,66 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestRingChan(t *testing.T) {
+	in := make(chan int)
+	ring := extsort.RingChan(in, 3)
+	for i := 1; i <= 5; i++ {
+		in <- i
+	}
+	close(in)
+	<-ring.Done()
+	if got := ring.Snapshot(); !slices.Equal(got, []int{3, 4, 5}) {
+		t.Errorf("expected [3 4 5], got %v", got)
+	}
+	if got := ring.Dropped(); got != 2 {
+		t.Errorf("expected 2 dropped records, got %d", got)
+	}
+}
+
+func TestRingChanNotFull(t *testing.T) {
+	in := make(chan int, 2)
+	in <- 1
+	in <- 2
+	close(in)
+	ring := extsort.RingChan(in, 5)
+	<-ring.Done()
+	if got := ring.Snapshot(); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("expected [1 2], got %v", got)
+	}
+	if got := ring.Dropped(); got != 0 {
+		t.Errorf("expected no dropped records, got %d", got)
+	}
+}
+
+func TestSortRing(t *testing.T) {
+	data := generateRandomInts(5000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 500
+	sorter, _, _ := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	ring, errChan := sorter.SortRing(context.Background(), 10)
+	if err := <-errChan; err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	<-ring.Done()
+	slices.Sort(data)
+	if got, want := ring.Snapshot(), data[len(data)-10:]; !slices.Equal(got, want) {
+		t.Errorf("expected the last sorted records %v, got %v", want, got)
+	}
+	if got := ring.Dropped(); got != int64(len(data)-10) {
+		t.Errorf("expected %d dropped records, got %d", len(data)-10, got)
+	}
+}
@@ -0,0 +1,98 @@
+package extsort
+
+import (
+	"context"
+	"os"
+	"slices"
+
+	"github.com/lanrat/extsort/tempfile"
+)
+
+// RunHandle describes a sorted run written by ProduceRuns.
+type RunHandle struct {
+	// ID is the position of the run in input order, starting at 0.
+	ID int
+	// Path is the run file, in the format written by SortToFile.
+	Path string
+	// Count is the number of records in the run.
+	Count int
+}
+
+// ProduceRuns performs only the first phase of an external sort: it reads input in
+// chunks of Config.ChunkSize records, sorts each chunk, and writes it to its own run
+// file, skipping the built-in merge. onRun is called with every run as soon as it is
+// written, in input order, so the runs can be merged elsewhere, for example by a
+// distributed merge. Runs use the SortToFile format and can be read back with
+// OpenSortedFile, OpenSortedFileReader, or MergeFiles.
+//
+// Run files are created in Config.TempFilesDir, or spread across Config.TempFilesDirs
+// in round-robin order. Ownership of a run file passes to the caller when onRun is
+// called: ProduceRuns never removes it, even if onRun or a later run fails, so the
+// caller must remove every file it was handed. A run that fails while being written
+// is removed before ProduceRuns returns.
+//
+// Chunks are sorted in the calling goroutine one at a time, so Config.NumWorkers and
+// Config.ChunkSortParallelism do not apply. ProduceRuns returns the first error from reading, sorting, or writing a
+// run, or from onRun, and stops reading input when it does.
+func ProduceRuns[E any](ctx context.Context, input <-chan E, toBytes ToBytesGeneric[E], compareFunc CompareGeneric[E], config *Config, onRun func(RunHandle) error) error {
+	s := newSorter(input, nil, toBytes, compareFunc, config)
+	c := &genericChunk[E]{}
+	for id := 0; ; id++ {
+		c.data = c.data[:0]
+		closed, err := s.fillChunk(ctx, c)
+		if err != nil {
+			return err
+		}
+		if len(c.data) > 0 {
+			if err := s.sortChunkSafe(c.data); err != nil {
+				return err
+			}
+			run, err := s.writeRun(id, c.data)
+			if err != nil {
+				return err
+			}
+			if err := onRun(run); err != nil {
+				return err
+			}
+		}
+		if closed {
+			return nil
+		}
+	}
+}
+
+// writeRun writes the sorted records of run id to a new run file.
+func (s *GenericSorter[E]) writeRun(id int, data []E) (RunHandle, error) {
+	dir := s.config.TempFilesDir
+	if len(s.config.TempFilesDirs) > 0 {
+		dir = s.config.TempFilesDirs[id%len(s.config.TempFilesDirs)]
+	}
+	f, err := tempfile.Create(tempfile.GetTempDir(dir, true), "extsort-run-", s.config.TempFileID)
+	if err != nil {
+		return RunHandle{}, NewResourceError(err, "run file", "ProduceRuns")
+	}
+	run := RunHandle{ID: id, Path: f.Name(), Count: len(data)}
+
+	index, err := tempfile.New(dir, true, s.config.tempFileOptions(s.retryCtx)...)
+	if err == nil {
+		err = writeSortedFile(f, slices.Values(data), s.toBytes, index, nil)
+	} else {
+		err = NewResourceError(err, "temp file", "ProduceRuns")
+	}
+	if err == nil {
+		if err = f.Sync(); err != nil {
+			err = NewDiskError(err, "sync run file", run.Path)
+		}
+	}
+	if closeErr := f.Close(); closeErr != nil && err == nil {
+		err = NewDiskError(closeErr, "close run file", run.Path)
+	}
+	if err != nil {
+		_ = os.Remove(run.Path)
+		return RunHandle{}, err
+	}
+	if s.logger != nil {
+		s.logger.Debug("extsort: run written", "run", id, "records", len(data), "path", run.Path)
+	}
+	return run, nil
+}
@@ -0,0 +1,120 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestProduceRuns verifies that every run is sorted and that merging the runs
+// yields the sorted input.
+func TestProduceRuns(t *testing.T) {
+	data := generateRandomInts(1050)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.TempFilesDir = t.TempDir()
+
+	var runs []extsort.RunHandle
+	err := extsort.ProduceRuns(context.Background(), inputChan, intToBytes, cmp.Compare[int], config, func(run extsort.RunHandle) error {
+		runs = append(runs, run)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProduceRuns error: %v", err)
+	}
+	if len(runs) != 11 {
+		t.Fatalf("expected 11 runs, got %d", len(runs))
+	}
+
+	paths := make([]string, len(runs))
+	for i, run := range runs {
+		if run.ID != i {
+			t.Errorf("expected run %d to have ID %d, got %d", i, i, run.ID)
+		}
+		expectedCount := 100
+		if i == len(runs)-1 {
+			expectedCount = 50
+		}
+		if run.Count != expectedCount {
+			t.Errorf("run %d: expected %d records, got %d", i, expectedCount, run.Count)
+		}
+		outChan, errChan := extsort.OpenSortedFile(context.Background(), run.Path, intFromBytes, false)
+		got, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("run %d: read error: %v", i, err)
+		}
+		if len(got) != run.Count || !slices.IsSorted(got) {
+			t.Errorf("run %d: expected %d sorted records, got %d", i, run.Count, len(got))
+		}
+		paths[i] = run.Path
+	}
+
+	outChan, errChan := extsort.MergeFiles(context.Background(), paths, intFromBytes, cmp.Compare[int], false, nil)
+	got, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("merge error: %v", err)
+	}
+	slices.Sort(data)
+	if !slices.Equal(got, data) {
+		t.Fatal("merged runs do not match sorted input")
+	}
+
+	// run files are owned by the caller
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil {
+			t.Errorf("failed to remove run file: %v", err)
+		}
+	}
+}
+
+// TestProduceRunsCallbackError verifies that an error from onRun stops ProduceRuns.
+func TestProduceRunsCallbackError(t *testing.T) {
+	inputChan := make(chan int, 1000)
+	for _, v := range generateRandomInts(1000) {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.TempFilesDir = t.TempDir()
+
+	errStop := errors.New("stop")
+	calls := 0
+	err := extsort.ProduceRuns(context.Background(), inputChan, intToBytes, cmp.Compare[int], config, func(run extsort.RunHandle) error {
+		calls++
+		_ = os.Remove(run.Path)
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 callback, got %d", calls)
+	}
+}
+
+// TestProduceRunsEmpty verifies that an empty input produces no runs.
+func TestProduceRunsEmpty(t *testing.T) {
+	inputChan := make(chan int)
+	close(inputChan)
+
+	err := extsort.ProduceRuns(context.Background(), inputChan, intToBytes, cmp.Compare[int], nil, func(extsort.RunHandle) error {
+		t.Error("unexpected run")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProduceRuns error: %v", err)
+	}
+}
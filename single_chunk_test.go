@@ -1,6 +1,7 @@
 package extsort_test
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"testing"
@@ -237,3 +238,23 @@ func TestSingleChunkWithGenericAPI(t *testing.T) {
 
 	t.Log("Single chunk optimization works with Generic API")
 }
+
+// BenchmarkSmallInput measures the latency of sorting inputs much smaller than ChunkSize.
+func BenchmarkSmallInput(b *testing.B) {
+	data := generateRandomInts(100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], nil)
+		sorter.Sort(context.Background())
+		for range outChan {
+		}
+		if err := <-errChan; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
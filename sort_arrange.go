@@ -0,0 +1,166 @@
+package extsort
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// rankedRecord pairs a record with the rank computed for it before the sort.
+type rankedRecord[E any] struct {
+	rank int64
+	rec  E
+}
+
+// errRankedFrame is returned when a serialized ranked record is truncated.
+var errRankedFrame = errors.New("invalid ranked record frame")
+
+// ArrangeSorter places records in the order given by a rank assigned to each of them
+// in advance, without calling a comparison function on the records themselves.
+type ArrangeSorter[E any] struct {
+	sorter  *GenericSorter[rankedRecord[E]]
+	input   <-chan E
+	ranked  chan rankedRecord[E]
+	sorted  <-chan rankedRecord[E]
+	sortErr <-chan error
+	output  chan E
+	errChan chan error
+	rank    func(E) int64
+
+	errOnce sync.Once
+	err     error // first invalid rank found, reported in place of the sorter's error
+}
+
+// Arrange performs external sorting on a channel of records whose final positions
+// were already computed upstream as ranks: each record is placed by the int64
+// returned by rank, smallest first, and records are only ever compared by their
+// ranks. The rank is computed exactly once per record and stored next to it in
+// temporary files. Ranks must be non-negative and unique; a negative rank, or two
+// records with the same rank, stop the sort with ErrInvalidRank. Ranks need not
+// be contiguous.
+// Returns the sorter instance, output channel with arranged records, and error channel.
+func Arrange[E any](input <-chan E, fromBytes FromBytesGeneric[E], toBytes ToBytesGeneric[E], rank func(E) int64, config *Config) (*ArrangeSorter[E], <-chan E, <-chan error) {
+	config = mergeConfig(config)
+	s := &ArrangeSorter[E]{
+		input:   input,
+		ranked:  make(chan rankedRecord[E], config.ChanBuffSize),
+		output:  make(chan E, config.SortedChanBuffSize),
+		errChan: make(chan error, 1),
+		rank:    rank,
+	}
+	s.sorter, s.sorted, s.sortErr = Generic(s.ranked, makeFromBytesRanked(fromBytes), makeToBytesRanked(toBytes), compareRanked[E], config)
+	if s.sorter == nil {
+		close(s.output)
+		return nil, s.output, s.sortErr
+	}
+	return s, s.output, s.errChan
+}
+
+// makeToBytesRanked serializes a ranked record as its 8 byte rank followed by the
+// record serialized with toBytes.
+func makeToBytesRanked[E any](toBytes ToBytesGeneric[E]) ToBytesGeneric[rankedRecord[E]] {
+	return func(r rankedRecord[E]) ([]byte, error) {
+		raw, err := toBytes(r.rec)
+		if err != nil {
+			return nil, err
+		}
+		b := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(raw)), uint64(r.rank))
+		return append(b, raw...), nil
+	}
+}
+
+// makeFromBytesRanked deserializes a ranked record written by makeToBytesRanked.
+func makeFromBytesRanked[E any](fromBytes FromBytesGeneric[E]) FromBytesGeneric[rankedRecord[E]] {
+	return func(d []byte) (rankedRecord[E], error) {
+		if len(d) < 8 {
+			return rankedRecord[E]{}, errRankedFrame
+		}
+		rec, err := fromBytes(d[8:])
+		if err != nil {
+			return rankedRecord[E]{}, err
+		}
+		return rankedRecord[E]{rank: int64(binary.BigEndian.Uint64(d)), rec: rec}, nil
+	}
+}
+
+// compareRanked orders ranked records by their ranks.
+func compareRanked[E any](a, b rankedRecord[E]) int {
+	return cmp.Compare(a.rank, b.rank)
+}
+
+// Sort arranges the input channel by rank, with the same semantics as
+// GenericSorter.Sort. Ranks are computed and results are unwrapped by goroutines
+// that stop when ctx is done or an invalid rank is found.
+func (s *ArrangeSorter[E]) Sort(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	// the sorter has stopped reading input once Sort returns
+	rankCtx, cancelRank := context.WithCancel(ctx)
+	go s.computeRanks(rankCtx, cancel)
+	s.sorter.Sort(ctx)
+	cancelRank()
+	go s.unwrap(ctx, cancel)
+}
+
+// fail records err as the reason the sort stopped, and stops it.
+func (s *ArrangeSorter[E]) fail(err error, cancel context.CancelFunc) {
+	s.errOnce.Do(func() { s.err = err })
+	cancel()
+}
+
+// computeRanks pairs every input record with its rank and passes it to the sorter.
+func (s *ArrangeSorter[E]) computeRanks(ctx context.Context, cancel context.CancelFunc) {
+	defer close(s.ranked)
+	for rec := range s.input {
+		r := s.rank(rec)
+		if r < 0 {
+			s.fail(fmt.Errorf("%w: negative rank %d", ErrInvalidRank, r), cancel)
+			break
+		}
+		select {
+		case s.ranked <- rankedRecord[E]{rank: r, rec: rec}:
+			continue
+		case <-ctx.Done():
+		}
+		break
+	}
+	if !s.sorter.config.NoDrainOnAbort {
+		drainInput(s.input)
+	}
+}
+
+// unwrap delivers the records from the sorter output without their ranks,
+// checking that no rank is repeated, then reports the outcome of the sort.
+func (s *ArrangeSorter[E]) unwrap(ctx context.Context, cancel context.CancelFunc) {
+	defer close(s.errChan)
+	defer cancel()
+	prev := int64(-1)
+	for r := range s.sorted {
+		if r.rank == prev {
+			s.fail(fmt.Errorf("%w: duplicate rank %d", ErrInvalidRank, r.rank), cancel)
+			break
+		}
+		prev = r.rank
+		select {
+		case s.output <- r.rec:
+			continue
+		case <-ctx.Done():
+		}
+		break
+	}
+	// drain so the sorter can shut down
+	for range s.sorted {
+	}
+	close(s.output)
+
+	err := <-s.sortErr
+	s.errOnce.Do(func() {}) // no invalid rank can be recorded from here on
+	if s.err != nil {
+		err = s.err
+	}
+	if err != nil {
+		s.errChan <- err
+	}
+}
@@ -0,0 +1,163 @@
+package extsort
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// compactRecord pairs a record with its key and version, extracted once when the
+// record is read.
+type compactRecord[E any] struct {
+	key     []byte
+	version uint64
+	rec     E
+}
+
+// errCompactFrame is returned when a serialized compact record is truncated or corrupt.
+var errCompactFrame = errors.New("invalid compact record frame")
+
+// CompactSorter merges records from several sources the way an LSM tree compacts
+// its runs: only the latest version of each key is kept, and keys whose latest
+// version is a tombstone are dropped.
+type CompactSorter[E any] struct {
+	sorter    *GenericSorter[compactRecord[E]]
+	inputs    []<-chan E
+	tagged    chan compactRecord[E]
+	sorted    <-chan compactRecord[E]
+	output    chan E
+	key       KeyFunc[E]
+	version   func(E) uint64
+	tombstone func(E) bool
+}
+
+// Compact performs external sorting on the records of all inputs together, ordered
+// by the byte key returned by key, and delivers only the record with the highest
+// version, as returned by version, for each key. When that record is a tombstone,
+// as reported by tombstone, nothing is delivered for its key. The inputs need not be
+// sorted. The key and version are extracted exactly once per record and stored in
+// temporary files next to it. If several records share the highest version of a
+// key, which one is kept is unspecified.
+// Returns the sorter instance, output channel with compacted records, and error channel.
+func Compact[E any](inputs []<-chan E, fromBytes FromBytesGeneric[E], toBytes ToBytesGeneric[E], key KeyFunc[E], version func(E) uint64, tombstone func(E) bool, config *Config) (*CompactSorter[E], <-chan E, <-chan error) {
+	config = mergeConfig(config)
+	s := &CompactSorter[E]{
+		inputs:    inputs,
+		tagged:    make(chan compactRecord[E], config.ChanBuffSize),
+		output:    make(chan E, config.SortedChanBuffSize),
+		key:       key,
+		version:   version,
+		tombstone: tombstone,
+	}
+	var errChan <-chan error
+	s.sorter, s.sorted, errChan = Generic(s.tagged, makeFromBytesCompact(fromBytes), makeToBytesCompact(toBytes), compareCompact[E], config)
+	if s.sorter == nil {
+		close(s.output)
+		return nil, s.output, errChan
+	}
+	return s, s.output, errChan
+}
+
+// makeToBytesCompact serializes a compact record as a uvarint key length, followed
+// by the key bytes, the 8 byte version and the record serialized with toBytes.
+func makeToBytesCompact[E any](toBytes ToBytesGeneric[E]) ToBytesGeneric[compactRecord[E]] {
+	return func(c compactRecord[E]) ([]byte, error) {
+		raw, err := toBytes(c.rec)
+		if err != nil {
+			return nil, err
+		}
+		b := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(c.key)+8+len(raw)), uint64(len(c.key)))
+		b = append(b, c.key...)
+		b = binary.BigEndian.AppendUint64(b, c.version)
+		return append(b, raw...), nil
+	}
+}
+
+// makeFromBytesCompact deserializes a compact record written by makeToBytesCompact.
+// The key shares the backing array of d rather than being copied.
+func makeFromBytesCompact[E any](fromBytes FromBytesGeneric[E]) FromBytesGeneric[compactRecord[E]] {
+	return func(d []byte) (compactRecord[E], error) {
+		keyLen, n := binary.Uvarint(d)
+		if n <= 0 || uint64(len(d)-n) < keyLen+8 {
+			return compactRecord[E]{}, errCompactFrame
+		}
+		end := n + int(keyLen)
+		rec, err := fromBytes(d[end+8:])
+		if err != nil {
+			return compactRecord[E]{}, err
+		}
+		return compactRecord[E]{key: d[n:end:end], version: binary.BigEndian.Uint64(d[end:]), rec: rec}, nil
+	}
+}
+
+// compareCompact orders compact records by key, then by descending version.
+func compareCompact[E any](a, b compactRecord[E]) int {
+	if c := bytes.Compare(a.key, b.key); c != 0 {
+		return c
+	}
+	return cmp.Compare(b.version, a.version)
+}
+
+// Sort sorts and compacts the records of all inputs, with the same semantics as
+// GenericSorter.Sort. Inputs are read and results are compacted by goroutines that
+// stop when ctx is done.
+func (s *CompactSorter[E]) Sort(ctx context.Context) {
+	// the sorter has stopped reading input once Sort returns
+	readCtx, cancel := context.WithCancel(ctx)
+	go s.readInputs(readCtx)
+	s.sorter.Sort(ctx)
+	cancel()
+	go s.compact(ctx)
+}
+
+// readInputs extracts the key and version of the records of every input and passes
+// them to the sorter, reading all inputs concurrently.
+func (s *CompactSorter[E]) readInputs(ctx context.Context) {
+	defer close(s.tagged)
+	var wg sync.WaitGroup
+	for _, in := range s.inputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rec := range in {
+				select {
+				case s.tagged <- compactRecord[E]{key: s.key(rec), version: s.version(rec), rec: rec}:
+				case <-ctx.Done():
+					if !s.sorter.config.NoDrainOnAbort {
+						drainInput(in)
+					}
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// compact delivers the first, highest versioned, record of every key from the
+// sorter output, unless it is a tombstone.
+func (s *CompactSorter[E]) compact(ctx context.Context) {
+	defer close(s.output)
+	var lastKey []byte
+	first := true
+	for c := range s.sorted {
+		if !first && bytes.Equal(c.key, lastKey) {
+			continue
+		}
+		first, lastKey = false, c.key
+		if s.tombstone(c.rec) {
+			continue
+		}
+		select {
+		case s.output <- c.rec:
+		case <-ctx.Done():
+			// drain so the sorter can shut down
+			for range s.sorted {
+			}
+			return
+		}
+	}
+}
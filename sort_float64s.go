@@ -0,0 +1,75 @@
+package extsort
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// NaNOrder defines where Float64s places NaN values in the sorted output.
+type NaNOrder int
+
+const (
+	// NaNFirst sorts NaN values before all other values, including -Inf.
+	NaNFirst NaNOrder = iota
+	// NaNLast sorts NaN values after all other values, including +Inf.
+	NaNLast
+)
+
+// Float64Sorter provides external sorting for float64 values with a defined
+// ordering of NaN. It embeds GenericSorter[float64] and serializes each value
+// as its 8 byte IEEE 754 representation.
+type Float64Sorter struct {
+	GenericSorter[float64]
+}
+
+// fromBytesFloat64 decodes a float64 from its 8 byte IEEE 754 representation.
+func fromBytesFloat64(d []byte) (float64, error) {
+	if len(d) != 8 {
+		return 0, errors.New("float64 record is not 8 bytes")
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(d)), nil
+}
+
+// toBytesFloat64 encodes a float64 as its 8 byte IEEE 754 representation,
+// preserving NaN payloads and the sign of zero exactly.
+func toBytesFloat64(f float64) ([]byte, error) {
+	return binary.LittleEndian.AppendUint64(make([]byte, 0, 8), math.Float64bits(f)), nil
+}
+
+// compareFloat64NaNLast orders like cmp.Compare, except that NaN values sort
+// after all other values.
+func compareFloat64NaNLast(a, b float64) int {
+	aNaN, bNaN := math.IsNaN(a), math.IsNaN(b)
+	switch {
+	case aNaN && bNaN:
+		return 0
+	case aNaN:
+		return 1
+	case bNaN:
+		return -1
+	}
+	return cmp.Compare(a, b)
+}
+
+// Float64s performs external sorting on a channel of float64 values in ascending
+// order, with NaN values placed according to nanOrder. Sorting floats with the <
+// operator alone is not deterministic when NaN is present, since NaN compares
+// false with every value; Float64s instead defines a total order:
+// -Inf < finite values < +Inf, with all NaNs first or last. NaN values compare
+// equal to each other, as do -0 and +0, so their relative order follows the input
+// order only when Config.Stable is set.
+// Returns the sorter instance, output channel with sorted values, and error channel.
+func Float64s(input <-chan float64, nanOrder NaNOrder, config *Config) (*Float64Sorter, <-chan float64, <-chan error) {
+	compareFunc := cmp.Compare[float64]
+	if nanOrder == NaNLast {
+		compareFunc = compareFloat64NaNLast
+	}
+	genericSorter, output, errChan := Generic(input, fromBytesFloat64, toBytesFloat64, compareFunc, config)
+	if genericSorter == nil {
+		return nil, output, errChan
+	}
+	s := &Float64Sorter{GenericSorter: *genericSorter}
+	return s, output, errChan
+}
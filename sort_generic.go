@@ -1,13 +1,22 @@
-// Package extsort implements an unstable external sort for all the records in a chan or iterator
+// Package extsort implements an external sort for all the records in a chan or iterator.
+// The sort is unstable by default; set Config.Stable for a stable sort.
 package extsort
 
 import (
 	"bufio"
+	"bytes"
+	"cmp"
 	"context"
 	"encoding/binary"
+	"errors"
 	"io"
+	"log/slog"
+	"reflect"
 	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
 
 	"github.com/lanrat/extsort/queue"
 	"github.com/lanrat/extsort/tempfile"
@@ -19,6 +28,7 @@ import (
 // It holds data in memory before being sorted using slices.SortFunc.
 type genericChunk[E any] struct {
 	data []E
+	seq  int // position of the chunk in the input, used to save chunks in input order
 }
 
 // getChunk retrieves a chunk from the pool and initializes it
@@ -36,6 +46,7 @@ func (s *GenericSorter[E]) getChunk() *genericChunk[E] {
 // putChunk returns a chunk to the pool for reuse
 func (s *GenericSorter[E]) putChunk(c *genericChunk[E]) {
 	if c != nil && c.data != nil {
+		s.memUsage.records.Add(-int64(len(c.data)))
 		// Return the slice to the pool
 		data := c.data
 		c.data = nil // Clear reference before putting
@@ -64,17 +75,82 @@ type GenericSorter[E any] struct {
 	buildSortCtx   context.Context
 	saveCtx        context.Context
 	mergeErrChan   chan error
-	tempWriter     tempfile.TempWriter
+	tempWriter     tempfile.TempWriter // nil if creation failed under AllowMemoryFallback
+	tempErr        error               // why tempWriter is nil
+	newTempWriter  func() (tempfile.TempWriter, error)
+	spareWriter    tempfile.TempWriter // a recycled temporary file, with Config.ReuseTempFiles
 	tempReader     tempfile.TempReader
 	input          <-chan E
 	chunkChan      chan *genericChunk[E]
 	saveChunkChan  chan *genericChunk[E]
 	mergeChunkChan chan E
 	compareFunc    CompareGeneric[E]
+	sortCompare    CompareGeneric[E] // compareFunc, timed when Config.MaxCompareTime is set
 	fromBytes      FromBytesGeneric[E]
 	toBytes        ToBytesGeneric[E]
 	pools          *memoryPools
-	singleChunk    *genericChunk[E] // Holds the single chunk for optimization
+	singleChunk    *genericChunk[E]         // Holds the single chunk for optimization
+	pendingChunks  map[int]*genericChunk[E] // sorted chunks waiting for earlier chunks to be saved
+	saveWindow     chan struct{}            // one slot per chunk read but not yet saved
+	nextSaveSeq    int                      // seq of the next chunk to save
+	mapOutput      func(E) E
+	onChunkSpilled func(id int, min, max E, count int)
+	onRelease      func(E)
+	aggregator     Aggregator[E]
+	keyRange       *keyRange[E]     // nil unless SetKeyRange was called
+	chunkBounds    []chunkBounds[E] // bounds of each spilled chunk, kept only with keyRange
+	runs           []runInfo        // runs in the current temporary file
+	nextRun        int              // id of the next run created
+	mergeOps       []MergeOp
+	numRecords     int   // records read from input
+	numEmitted     int   // records sent on the output channel
+	quantileRanks  []int // output positions of Config.Quantiles, computed on first emit
+	quantiles      []E   // values captured at quantileRanks
+	logger         *slog.Logger
+	memUsage       *memUsage
+	progress       *progressCounters
+	progressChan   chan Progress // nil unless Progress was called
+	stopProgress   func()        // stops progress reporting once the sort finishes
+	nilable        bool          // true if E is an interface type that can hold nil
+	pause          *pauseGate
+	chunkSorts     *sync.WaitGroup    // chunk sorts still running, which an aborted sort does not wait for
+	outputLimiter  *tokenBucket       // nil when output is not rate limited
+	outputBuf      *outputBuffer[E]   // nil unless output is buffered by bytes
+	timeoutCtx     context.Context    // context bounded by MaxDuration, if set
+	stopTimeout    context.CancelFunc // releases the MaxDuration timer
+	retryCtx       context.Context    // done once the sort stops, ending temporary file retries
+	cancelRetries  context.CancelFunc // cancels retryCtx
+	stopRetries    func() bool        // unlinks cancelRetries from the context of Sort
+}
+
+// pauseGate blocks the input reader while the sorter is paused.
+// It is kept behind a pointer so the state is shared by sorter wrappers.
+type pauseGate struct {
+	mu      sync.Mutex
+	resumed atomic.Pointer[chan struct{}] // non-nil while paused, closed on resume
+}
+
+// wait blocks while the gate is paused or until ctx is done.
+func (g *pauseGate) wait(ctx context.Context) error {
+	ch := g.resumed.Load()
+	if ch == nil {
+		return nil
+	}
+	select {
+	case <-*ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// memUsage tracks the counters used to estimate the memory held by a sorter.
+// It is kept behind a pointer so the atomics are shared by sorter wrappers.
+type memUsage struct {
+	records         atomic.Int64 // records currently held in in-memory chunks
+	spilledRecords  atomic.Int64 // records serialized to temporary storage so far
+	spilledBytes    atomic.Int64 // serialized bytes written to temporary storage so far
+	mergeBufferSize atomic.Int64 // bytes held by the read buffers of the merge
 }
 
 // newSorter creates a new GenericSorter instance with the given configuration.
@@ -89,9 +165,35 @@ func newSorter[E any](input <-chan E, fromBytes FromBytesGeneric[E], toBytes ToB
 		toBytes:        toBytes,
 		config:         *config,
 		chunkChan:      make(chan *genericChunk[E], config.ChanBuffSize),
-		saveChunkChan:  make(chan *genericChunk[E], config.NumWorkers*2), // Buffer for workers to avoid deadlock
+		saveChunkChan:  make(chan *genericChunk[E], cmp.Or(max(config.MaxPendingChunks, 0), config.NumWorkers*2)), // sorted chunks waiting to be written
 		mergeChunkChan: make(chan E, config.SortedChanBuffSize),
 		mergeErrChan:   make(chan error, 1),
+		logger:         config.Logger,
+		memUsage:       &memUsage{},
+		progress:       &progressCounters{},
+		nilable:        reflect.TypeFor[E]().Kind() == reflect.Interface,
+		pause:          &pauseGate{},
+		chunkSorts:     &sync.WaitGroup{},
+	}
+	s.retryCtx, s.cancelRetries = context.WithCancel(context.Background())
+	s.progress.expected.Store(int64(max(config.ExpectedCount, 0)))
+	if s.config.ChunkSortParallelism < 1 {
+		s.config.ChunkSortParallelism = config.NumWorkers
+	}
+	s.sortCompare = compareFunc
+	if config.MaxCompareTime > 0 {
+		s.sortCompare = timedCompare(compareFunc, config.MaxCompareTime)
+	}
+	if config.OutputBufferBytes > 0 {
+		// records are handed over unbuffered, so the consumer's receipts are seen
+		s.outputBuf = newOutputBuffer[E](int64(config.OutputBufferBytes), config.SortedChanBuffSize)
+		s.mergeChunkChan = make(chan E)
+	}
+	if config.OutputRateLimit > 0 {
+		s.outputLimiter = newTokenBucket(config.OutputRateLimit)
+	}
+	if s.logger != nil && config.JobID != "" {
+		s.logger = s.logger.With("job", config.JobID)
 	}
 	s.pools = s.initMemoryPools()
 	return s
@@ -153,15 +255,34 @@ func (s *GenericSorter[E]) initMemoryPools() *memoryPools {
 //  3. Saves sorted chunks to temporary files using toBytes serialization
 //  4. Merges all chunks back into sorted order using fromBytes deserialization
 //
+// All records are retained, including records that compare equal. When merging, equal
+// records from different chunks are emitted in input order: records from a chunk read
+// earlier always come before equal records from a chunk read later. The order of equal
+// records within a single chunk is determined by the chunk sort, which is only stable
+// when Config.Stable is set.
+//
 // Call Sort() on the returned sorter to begin the sorting process.
 // Results are delivered via the output channel, errors via the error channel.
-// On error or interruption, temporary files may remain in config.TempFilesDir.
+// On error or interruption, temporary files may remain in config.TempFilesDir
+// or config.TempFilesDirs.
 func Generic[E any](input <-chan E, fromBytes FromBytesGeneric[E], toBytes ToBytesGeneric[E], compareFunc CompareGeneric[E], config *Config) (*GenericSorter[E], <-chan E, <-chan error) {
 	var err error
 	s := newSorter(input, fromBytes, toBytes, compareFunc, config)
-	s.tempWriter, err = tempfile.New(s.config.TempFilesDir, true)
+	s.newTempWriter = func() (tempfile.TempWriter, error) {
+		return createTempWriter(s.retryCtx, &s.config)
+	}
+	s.tempWriter, err = s.newTempWriter()
+	if err != nil && s.config.AllowMemoryFallback {
+		// inputs that fit in the first chunk never need the temporary file
+		if s.logger != nil {
+			s.logger.Warn("extsort: temporary file unavailable, sorting in memory only", "error", err)
+		}
+		s.tempWriter = nil
+		s.tempErr = err
+		return s, s.mergeChunkChan, s.mergeErrChan
+	}
 	if err != nil {
-		s.mergeErrChan <- err
+		s.sendErr(err)
 		close(s.mergeErrChan)
 		close(s.mergeChunkChan)
 		return nil, s.mergeChunkChan, s.mergeErrChan
@@ -169,16 +290,234 @@ func Generic[E any](input <-chan E, fromBytes FromBytesGeneric[E], toBytes ToByt
 	return s, s.mergeChunkChan, s.mergeErrChan
 }
 
+// createTempWriter creates temporary storage in the directories selected by config,
+// spread across Config.TempFilesDirs when it is set. Retries stop once ctx is done.
+func createTempWriter(ctx context.Context, config *Config) (tempfile.TempWriter, error) {
+	if len(config.TempFilesDirs) > 0 {
+		return tempfile.NewMulti(config.TempFilesDirs, true, config.tempFileOptions(ctx)...)
+	}
+	return tempfile.New(config.TempFilesDir, true, config.tempFileOptions(ctx)...)
+}
+
 // MockGeneric creates an external sorter that uses in-memory storage instead of disk files.
 // This is primarily useful for testing and benchmarking without filesystem I/O overhead.
 // The parameter n specifies the initial capacity of the in-memory buffer.
 // All other behavior is identical to Generic().
 func MockGeneric[E any](input <-chan E, fromBytes FromBytesGeneric[E], toBytes ToBytesGeneric[E], compareFunc CompareGeneric[E], config *Config, n int) (*GenericSorter[E], <-chan E, <-chan error) {
 	s := newSorter(input, fromBytes, toBytes, compareFunc, config)
+	s.newTempWriter = func() (tempfile.TempWriter, error) {
+		return tempfile.Mock(n), nil
+	}
 	s.tempWriter = tempfile.Mock(n)
 	return s, s.mergeChunkChan, s.mergeErrChan
 }
 
+// SetMapOutput registers a function that is applied to every record just before
+// it is sent on the output channel, fusing a map step into the final stage of the sort.
+// The function must not change the relative order of records; the output is only
+// guaranteed to be sorted if fn preserves the ordering defined by the compare function.
+// It must be called before Sort.
+func (s *GenericSorter[E]) SetMapOutput(fn func(E) E) {
+	s.mapOutput = fn
+}
+
+// SetOnChunkSpilled registers a callback invoked after each sorted chunk has been
+// written to temporary storage, with the chunk id, its smallest and largest records,
+// and its number of records. This allows building a sparse index or zone map alongside
+// the sort. The callback is always invoked from a single goroutine, in the order chunks
+// are spilled, and blocks further spilling while it runs. It is not invoked when the
+// input fits in a single chunk, since that chunk is sorted in memory and never spilled.
+// It must be called before Sort.
+func (s *GenericSorter[E]) SetOnChunkSpilled(fn func(id int, min, max E, count int)) {
+	s.onChunkSpilled = fn
+}
+
+// SetOnRelease registers a callback invoked with each input record the sorter has
+// stopped holding, so that records owning resources, such as pooled buffers, can
+// release them. A record is released after the chunk holding it has been serialized
+// to temporary storage and any SetOnChunkSpilled callback for that chunk has returned;
+// the records later sent on the output channel are new values read back from storage.
+// Records cut from a chunk because they cannot be among the first Config.Limit records
+// are released once the chunk has been sorted. Records that reach the output channel
+// without being spilled, as when the input fits in a single chunk, belong to the
+// receiver and are not released, nor are records still in memory when the sort fails.
+// With SetKeyRange, the smallest and largest records of each chunk are kept to bound
+// the merge and are not released either. The callback blocks further spilling while
+// it runs. With Config.Limit it is also invoked by the sort workers, so it must then
+// be safe for concurrent use. It must be called before Sort.
+func (s *GenericSorter[E]) SetOnRelease(fn func(E)) {
+	s.onRelease = fn
+}
+
+// releaseRecords passes the records of a spilled chunk to the SetOnRelease callback.
+func (s *GenericSorter[E]) releaseRecords(data []E) {
+	if s.onRelease == nil {
+		return
+	}
+	for i, d := range data {
+		if s.keyRange != nil && (i == 0 || i == len(data)-1) {
+			continue
+		}
+		s.onRelease(d)
+	}
+}
+
+// MemUsageBytes returns an estimate of the memory currently held by the sorter:
+// records buffered in in-memory chunks plus the read buffers used while merging.
+// The size of a buffered record is approximated by the average serialized size of
+// the records spilled so far, or by the in-memory size of E if nothing has been spilled.
+// This is an estimate intended for coarse decisions such as autoscaling,
+// not a precise measurement of heap usage. It is safe to call concurrently with Sort.
+func (s *GenericSorter[E]) MemUsageBytes() int64 {
+	var zero E
+	recordSize := int64(unsafe.Sizeof(zero))
+	if spilled := s.memUsage.spilledRecords.Load(); spilled > 0 {
+		if avg := s.memUsage.spilledBytes.Load() / spilled; avg > recordSize {
+			recordSize = avg
+		}
+	}
+	return s.memUsage.records.Load()*recordSize + s.memUsage.mergeBufferSize.Load()
+}
+
+// Pause stops the sorter from reading the input channel until Resume is called.
+// Records already read continue through the pipeline, so chunks in flight are still
+// sorted and saved while paused. This gives callers flow control beyond channel
+// buffering, for example to let a downstream consumer flush. It may be called
+// before or during Sort and is safe for concurrent use.
+func (s *GenericSorter[E]) Pause() {
+	s.pause.mu.Lock()
+	defer s.pause.mu.Unlock()
+	if s.pause.resumed.Load() == nil {
+		ch := make(chan struct{})
+		s.pause.resumed.Store(&ch)
+	}
+}
+
+// Resume restarts reading the input channel after a call to Pause.
+// Calling Resume when the sorter is not paused has no effect.
+func (s *GenericSorter[E]) Resume() {
+	s.pause.mu.Lock()
+	defer s.pause.mu.Unlock()
+	if ch := s.pause.resumed.Swap(nil); ch != nil {
+		close(*ch)
+	}
+}
+
+// emit delivers a single record to the output channel, applying any configured
+// output hooks. It returns the context error if ctx is cancelled before delivery,
+// or ErrConsumerStalled if the send blocks for longer than Config.ConsumerTimeout.
+func (s *GenericSorter[E]) emit(ctx context.Context, rec E) error {
+	if !s.inKeyRange(rec) || (s.config.Limit > 0 && s.numEmitted >= s.config.Limit) {
+		return nil
+	}
+	if s.mapOutput != nil {
+		rec = s.mapOutput(rec)
+	}
+	if s.outputLimiter != nil {
+		if err := s.outputLimiter.wait(ctx); err != nil {
+			return err
+		}
+	}
+	if err := s.send(ctx, rec); err != nil {
+		return err
+	}
+	if s.aggregator != nil {
+		s.aggregator.Observe(rec)
+	}
+	if len(s.config.Quantiles) > 0 {
+		s.observeQuantiles(rec)
+	}
+	s.numEmitted++
+	s.progress.recordsEmitted.Add(1)
+	return nil
+}
+
+// send delivers rec on the output channel, giving up after Config.ConsumerTimeout.
+func (s *GenericSorter[E]) send(ctx context.Context, rec E) error {
+	if s.outputBuf != nil {
+		return s.sendBuffered(ctx, rec)
+	}
+	if s.config.ConsumerTimeout <= 0 {
+		select {
+		case s.mergeChunkChan <- rec:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// avoid starting a timer when the consumer is keeping up
+	select {
+	case s.mergeChunkChan <- rec:
+		return nil
+	default:
+	}
+	timer := time.NewTimer(s.config.ConsumerTimeout)
+	defer timer.Stop()
+	select {
+	case s.mergeChunkChan <- rec:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return ErrConsumerStalled
+	}
+}
+
+// sendErr logs err and delivers it on the error channel.
+func (s *GenericSorter[E]) sendErr(err error) {
+	if s.timeoutCtx != nil && errors.Is(err, context.DeadlineExceeded) && context.Cause(s.timeoutCtx) == ErrTimeout {
+		err = ErrTimeout
+	}
+	if s.logger != nil {
+		s.logger.Error("extsort: sort failed", "error", err)
+	}
+	s.mergeErrChan <- err
+}
+
+// abort reports err and releases the output channels and temporary file
+// when the sort fails before its output stage starts.
+func (s *GenericSorter[E]) abort(err error) {
+	s.sendErr(err)
+	if s.tempWriter != nil {
+		_ = s.tempWriter.Close()
+	}
+	s.closeSpareWriter()
+	s.finish()
+	close(s.mergeErrChan)
+	s.abortOutput()
+	if !s.config.NoDrainOnAbort {
+		drainInput(s.input)
+	}
+}
+
+// drainInput discards the records remaining on input in the background until it is
+// closed, so that producers blocked sending to it can finish.
+func drainInput[E any](input <-chan E) {
+	if input == nil {
+		return
+	}
+	go func() {
+		for range input {
+		}
+	}()
+}
+
+// finish releases the MaxDuration timer and completes progress reporting once
+// the sort has completed.
+func (s *GenericSorter[E]) finish() {
+	if s.stopTimeout != nil {
+		s.stopTimeout()
+	}
+	if s.stopRetries != nil {
+		s.stopRetries()
+	}
+	s.cancelRetries()
+	if s.stopProgress != nil {
+		s.stopProgress()
+	}
+}
+
 // Sort sorts the Sorter's input chan and returns a new sorted chan, and error Chan
 // Sort is a chunking operation that runs multiple workers asynchronously
 // this blocks while sorting chunks and unblocks when merging
@@ -186,26 +525,144 @@ func MockGeneric[E any](input <-chan E, fromBytes FromBytesGeneric[E], toBytes T
 // Merge uses the same context and runs in a goroutine after Sort returns().
 // for example, if calling sort in an errGroup, you must pass the group's parent context into sort.
 func (s *GenericSorter[E]) Sort(ctx context.Context) {
+	s.stopProgress = s.startProgress()
+	if s.config.MaxDuration > 0 {
+		ctx, s.stopTimeout = context.WithTimeoutCause(ctx, s.config.MaxDuration, ErrTimeout)
+		s.timeoutCtx = ctx
+	}
+	// the temporary files were created before ctx was known
+	s.stopRetries = context.AfterFunc(ctx, s.cancelRetries)
+	if s.outputBuf != nil {
+		go s.forwardOutput(ctx)
+	}
+	for _, q := range s.config.Quantiles {
+		if !(q >= 0 && q <= 1) {
+			s.abort(&ConfigError{Field: "Quantiles", Value: q, Reason: "must be in [0, 1]"})
+			return
+		}
+	}
+	if (s.config.ChunkTransformWrite == nil) != (s.config.ChunkTransformRead == nil) {
+		s.abort(&ConfigError{Field: "ChunkTransformRead", Value: s.config.ChunkTransformRead != nil, Reason: "ChunkTransformWrite and ChunkTransformRead must be set together"})
+		return
+	}
+	if s.config.MaxRunsBeforeMerge < 0 || s.config.MaxRunsBeforeMerge == 1 {
+		s.abort(&ConfigError{Field: "MaxRunsBeforeMerge", Value: s.config.MaxRunsBeforeMerge, Reason: "must be 0 or >= 2"})
+		return
+	}
+	if s.config.MaxPendingChunks < 0 {
+		s.abort(&ConfigError{Field: "MaxPendingChunks", Value: s.config.MaxPendingChunks, Reason: "must be >= 0"})
+		return
+	}
+	if s.config.WriteRetries < 0 {
+		s.abort(&ConfigError{Field: "WriteRetries", Value: s.config.WriteRetries, Reason: "must be >= 0"})
+		return
+	}
+	if s.config.OutputBufferBytes < 0 {
+		s.abort(&ConfigError{Field: "OutputBufferBytes", Value: s.config.OutputBufferBytes, Reason: "must be >= 0"})
+		return
+	}
+	if s.config.ExpectedCount < 0 {
+		s.abort(&ConfigError{Field: "ExpectedCount", Value: s.config.ExpectedCount, Reason: "must be >= 0"})
+		return
+	}
+	if s.config.Limit < 0 {
+		s.abort(&ConfigError{Field: "Limit", Value: s.config.Limit, Reason: "must be >= 0"})
+		return
+	}
+	if s.config.Limit > 0 && (s.keyRange != nil || len(s.config.Quantiles) > 0) {
+		s.abort(&ConfigError{Field: "Limit", Value: s.config.Limit, Reason: "cannot be combined with Quantiles or SetKeyRange"})
+		return
+	}
+	if s.keyRange != nil && len(s.config.Quantiles) > 0 {
+		s.abort(&ConfigError{Field: "Quantiles", Value: s.config.Quantiles, Reason: "cannot be combined with SetKeyRange"})
+		return
+	}
+	if s.config.MaxRunsBeforeMerge > 0 && s.config.ChunkTransformWrite != nil {
+		s.abort(&ConfigError{Field: "MaxRunsBeforeMerge", Value: s.config.MaxRunsBeforeMerge, Reason: "cannot be combined with ChunkTransformWrite"})
+		return
+	}
+
+	// Read the first chunk in the calling goroutine. Inputs that fit in it are sorted
+	// right here without starting any workers. The chunk grows as needed rather than
+	// coming from the pool, so small inputs don't allocate a full ChunkSize slice,
+	// starting from the expected size of the input when it is known.
+	first := &genericChunk[E]{}
+	if s.config.ExpectedCount > 0 {
+		first.data = make([]E, 0, min(s.config.ExpectedCount, s.config.ChunkSize))
+	}
+	closed, err := s.fillChunk(ctx, first)
+	if err != nil {
+		s.putChunk(first)
+		s.abort(err)
+		return
+	}
+	if closed {
+		if sem := s.config.ChunkSortSemaphore; sem != nil {
+			if err := sem.acquire(ctx); err != nil {
+				s.putChunk(first)
+				s.abort(err)
+				return
+			}
+		}
+		err := s.sortChunkSafe(first.data)
+		if sem := s.config.ChunkSortSemaphore; sem != nil {
+			sem.release()
+		}
+		if err != nil {
+			s.putChunk(first)
+			s.abort(err)
+			return
+		}
+		s.singleChunk = first
+		go s.outputSingleChunk(ctx)
+		return
+	}
+	if s.tempWriter == nil {
+		s.putChunk(first)
+		s.abort(s.tempErr)
+		return
+	}
+
 	var buildSortErrGroup, saveErrGroup *errgroup.Group
-	buildSortErrGroup, s.buildSortCtx = errgroup.WithContext(ctx)
-	saveErrGroup, s.saveCtx = errgroup.WithContext(ctx)
+	buildSortCtx, cancelBuildSort := context.WithCancel(ctx)
+	defer cancelBuildSort()
+	buildSortErrGroup, s.buildSortCtx = errgroup.WithContext(buildSortCtx)
+	saveCtx, cancelSave := context.WithCancel(ctx)
+	defer cancelSave()
+	saveErrGroup, s.saveCtx = errgroup.WithContext(saveCtx)
+
+	// bound how far reading may run ahead of the chunk the save worker waits for, so
+	// that a chunk whose sort stalls cannot leave the later ones piling up in memory
+	s.saveWindow = make(chan struct{}, s.config.ChunkSortParallelism+cap(s.chunkChan)+cap(s.saveChunkChan)+1)
 
 	//start creating chunks
-	buildSortErrGroup.Go(s.buildChunks)
+	buildSortErrGroup.Go(func() error {
+		return s.buildChunks(first)
+	})
 
 	// sort chunks
-	for i := 0; i < s.config.NumWorkers; i++ {
+	for i := 0; i < s.config.ChunkSortParallelism; i++ {
 		buildSortErrGroup.Go(s.sortChunks)
 	}
 
 	// Start the save worker that will handle single-chunk optimization
-	saveErrGroup.Go(s.saveChunksOptimized)
+	// a failed save stops the workers, which would otherwise block handing it chunks
+	saveErrGroup.Go(func() error {
+		err := s.saveChunksOptimized()
+		if err != nil {
+			cancelBuildSort()
+		}
+		return err
+	})
 
-	err := buildSortErrGroup.Wait()
+	err = buildSortErrGroup.Wait()
 	if err != nil {
-		s.mergeErrChan <- err
-		close(s.mergeErrChan)
-		close(s.mergeChunkChan)
+		// stop the save worker before releasing the temporary file it writes to
+		cancelSave()
+		if saveErr := saveErrGroup.Wait(); saveErr != nil && !errors.Is(saveErr, context.Canceled) {
+			err = saveErr
+		}
+		s.abort(err)
 		return
 	}
 
@@ -215,9 +672,7 @@ func (s *GenericSorter[E]) Sort(ctx context.Context) {
 	// Wait for save worker to complete
 	err = saveErrGroup.Wait()
 	if err != nil {
-		s.mergeErrChan <- err
-		close(s.mergeErrChan)
-		close(s.mergeChunkChan)
+		s.abort(err)
 		return
 	}
 
@@ -233,27 +688,31 @@ func (s *GenericSorter[E]) Sort(ctx context.Context) {
 	go s.mergeNChunks(ctx)
 }
 
-// buildChunks reads data from the input chan to builds chunks and pushes them to chunkChan
-func (s *GenericSorter[E]) buildChunks() error {
+// buildChunks reads data from the input chan to builds chunks and pushes them to chunkChan.
+// The first chunk has already been read by Sort and is pushed before any other.
+func (s *GenericSorter[E]) buildChunks(first *genericChunk[E]) error {
 	defer close(s.chunkChan) // if this is not called on error, causes a deadlock
 
-	for {
-		c := s.getChunk()
-		for i := 0; i < s.config.ChunkSize; i++ {
-			select {
-			case rec, ok := <-s.input:
-				if !ok {
-					break
-				}
-				c.data = append(c.data, rec)
-			case <-s.buildSortCtx.Done():
-				s.putChunk(c) // Return unused chunk to pool
-				return s.buildSortCtx.Err()
+	c := first
+	for seq := 0; ; seq++ {
+		select {
+		case s.saveWindow <- struct{}{}:
+		case <-s.buildSortCtx.Done():
+			s.putChunk(c)
+			return s.buildSortCtx.Err()
+		}
+		if c == nil {
+			c = s.getChunk()
+			if _, err := s.fillChunk(s.buildSortCtx, c); err != nil {
+				s.putChunk(c)
+				return err
 			}
 		}
+		c.seq = seq
 		if len(c.data) == 0 {
 			// the chunk is empty, return it to pool
 			s.putChunk(c)
+			<-s.saveWindow
 			break
 		}
 
@@ -264,31 +723,71 @@ func (s *GenericSorter[E]) buildChunks() error {
 			s.putChunk(c) // Return unused chunk to pool
 			return s.buildSortCtx.Err()
 		}
+		c = nil
 	}
 
 	return nil
 }
 
+// fillChunk reads records from the input into c until it holds ChunkSize records
+// or the input is closed, in which case it reports true.
+func (s *GenericSorter[E]) fillChunk(ctx context.Context, c *genericChunk[E]) (bool, error) {
+	for len(c.data) < s.config.ChunkSize {
+		if err := s.pause.wait(ctx); err != nil {
+			return false, err
+		}
+		select {
+		case rec, ok := <-s.input:
+			if !ok {
+				s.progress.expected.Store(int64(s.numRecords))
+				return true, nil
+			}
+			if s.nilable && any(rec) == nil {
+				switch s.config.OnNilItem {
+				case NilItemSkip:
+					continue
+				case NilItemPanic:
+					panic(ErrNilItem)
+				default:
+					return false, ErrNilItem
+				}
+			}
+			c.data = append(c.data, rec)
+			s.numRecords++
+			s.progress.recordsRead.Add(1)
+			s.memUsage.records.Add(1)
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+	return false, nil
+}
+
 // sortChunks is a worker for sorting the data stored in a chunk prior to save
 func (s *GenericSorter[E]) sortChunks() error {
 	for {
 		select {
 		case b, more := <-s.chunkChan:
 			if more {
+				// Wait for a slot when the CPU is shared with other sorts
+				if sem := s.config.ChunkSortSemaphore; sem != nil {
+					if err := sem.acquire(s.buildSortCtx); err != nil {
+						s.putChunk(b)
+						return err
+					}
+				}
+
 				// Create channels to communicate completion and errors
 				sortDone := make(chan error, 1)
 
 				// Run sort in a separate goroutine
+				s.chunkSorts.Add(1)
 				go func() {
-					defer func() {
-						// Recover from panics in comparison function
-						if r := recover(); r != nil {
-							sortDone <- NewComparisonError(r, "sortChunks")
-						} else {
-							sortDone <- nil // Success
-						}
-					}()
-					slices.SortFunc(b.data, s.compareFunc)
+					defer s.chunkSorts.Done()
+					if sem := s.config.ChunkSortSemaphore; sem != nil {
+						defer sem.release()
+					}
+					sortDone <- s.sortChunkSafe(b.data)
 				}()
 
 				// Wait for either sort completion or context cancellation
@@ -300,6 +799,7 @@ func (s *GenericSorter[E]) sortChunks() error {
 						return sortErr
 					}
 					// Sort completed successfully, proceed to save
+					s.truncateChunk(b)
 					select {
 					case s.saveChunkChan <- b:
 					case <-s.buildSortCtx.Done():
@@ -318,12 +818,112 @@ func (s *GenericSorter[E]) sortChunks() error {
 	}
 }
 
+// sortChunkSafe sorts data with sortChunkData, converting panics raised by the
+// comparison function into errors.
+func (s *GenericSorter[E]) sortChunkSafe(data []E) (err error) {
+	defer func() {
+		// Recover from panics in comparison function
+		r := recover()
+		if failure, ok := r.(compareFailure); ok {
+			err = failure.err
+		} else if r == ErrComparatorBudgetExceeded {
+			err = ErrComparatorBudgetExceeded
+		} else if r != nil {
+			err = NewComparisonError(r, "sortChunks")
+		}
+	}()
+	s.sortChunkData(data)
+	return nil
+}
+
+// sortChunkData sorts the records of a single chunk in place.
+// With Config.Descending, chunks that arrive in strictly descending order are
+// detected with a single linear pass and reversed instead of sorted. Only strictly
+// descending runs are reversed so that equal records are never reordered.
+func (s *GenericSorter[E]) sortChunkData(data []E) {
+	compareFunc := s.sortCompare
+	if s.config.MaxComparisons > 0 {
+		compareFunc = budgetCompare(compareFunc, s.config.MaxComparisons)
+	}
+	if s.config.Descending && isStrictlyDescending(data, compareFunc) {
+		// a strictly descending chunk has no equal records, so reversing it is stable
+		slices.Reverse(data)
+		return
+	}
+	if s.config.Stable {
+		slices.SortStableFunc(data, compareFunc)
+		return
+	}
+	slices.SortFunc(data, compareFunc)
+}
+
+// budgetCompare wraps compareFunc so that it panics with ErrComparatorBudgetExceeded
+// once it has been called more than maxComparisons times.
+// The returned function is not safe for concurrent use.
+func budgetCompare[E any](compareFunc CompareGeneric[E], maxComparisons int) CompareGeneric[E] {
+	comparisons := 0
+	return func(a, b E) int {
+		comparisons++
+		if comparisons > maxComparisons {
+			panic(ErrComparatorBudgetExceeded)
+		}
+		return compareFunc(a, b)
+	}
+}
+
+// compareTimeSampleRate is how many comparisons timedCompare counts for each one it
+// times, keeping the cost of reading the clock low.
+const compareTimeSampleRate = 32
+
+// timedCompare wraps compareFunc so that it fails the sort with ErrCompareTimeExceeded
+// once the time spent in it exceeds maxTime. Only one comparison in
+// compareTimeSampleRate is timed, and its duration counts for all of them, so the
+// total is an estimate. The returned function is safe for concurrent use.
+func timedCompare[E any](compareFunc CompareGeneric[E], maxTime time.Duration) CompareGeneric[E] {
+	var calls, spent atomic.Int64
+	return func(a, b E) int {
+		if (calls.Add(1)-1)%compareTimeSampleRate != 0 {
+			return compareFunc(a, b)
+		}
+		start := time.Now()
+		c := compareFunc(a, b)
+		if spent.Add(int64(time.Since(start))*compareTimeSampleRate) > int64(maxTime) {
+			panic(compareFailure{ErrCompareTimeExceeded})
+		}
+		return c
+	}
+}
+
+// isStrictlyDescending reports whether every record in data is strictly less
+// than the record preceding it. It stops at the first record that is not. Asking
+// whether each record is less than its predecessor, rather than whether the
+// predecessor is greater, keeps a compare function that never returns 0 from
+// making a run of equal records look descending.
+func isStrictlyDescending[E any](data []E, compareFunc CompareGeneric[E]) bool {
+	if len(data) < 2 {
+		return false
+	}
+	for i := 1; i < len(data); i++ {
+		if compareFunc(data[i], data[i-1]) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // outputSingleChunk handles the single-chunk optimization by directly outputting
 // the sorted chunk without any disk I/O. This provides significant performance
 // benefits for small datasets that fit entirely in memory.
 func (s *GenericSorter[E]) outputSingleChunk(ctx context.Context) {
-	defer close(s.mergeChunkChan)
+	defer s.closeOutput()
 	defer close(s.mergeErrChan)
+	// runs first, so progress is complete by the time the output closes
+	defer s.finish()
+
+	// nothing was spilled, so the temporary file is not needed
+	if s.tempWriter != nil {
+		_ = s.tempWriter.Close()
+	}
 
 	// Use the chunk collected by collectSingleChunk
 	chunk := s.singleChunk
@@ -332,12 +932,14 @@ func (s *GenericSorter[E]) outputSingleChunk(ctx context.Context) {
 		return
 	}
 
+	if s.logger != nil {
+		s.logger.Debug("extsort: sorted in memory", "records", len(chunk.data))
+	}
+
 	// Output each item in the sorted chunk directly
 	for _, item := range chunk.data {
-		select {
-		case s.mergeChunkChan <- item:
-		case <-ctx.Done():
-			s.mergeErrChan <- ctx.Err()
+		if err := s.emit(ctx, item); err != nil {
+			s.sendErr(err)
 			return
 		}
 	}
@@ -380,13 +982,13 @@ func (s *GenericSorter[E]) saveChunksOptimized() error {
 
 	// We have at least 2 chunks - use multi-chunk path
 	// Save the first chunk
-	if err := s.saveChunk(firstChunk); err != nil {
+	if err := s.saveChunkInOrder(firstChunk); err != nil {
 		s.putChunk(secondChunk) // Return to pool
 		return err
 	}
 
 	// Save the second chunk
-	if err := s.saveChunk(secondChunk); err != nil {
+	if err := s.saveChunkInOrder(secondChunk); err != nil {
 		return err
 	}
 
@@ -399,9 +1001,10 @@ func (s *GenericSorter[E]) saveChunksOptimized() error {
 				// Finalize the temp writer and save it for reading
 				var err error
 				s.tempReader, err = s.tempWriter.Save()
+				s.closeSpareWriter()
 				return err
 			}
-			if err := s.saveChunk(chunk); err != nil {
+			if err := s.saveChunkInOrder(chunk); err != nil {
 				return err
 			}
 		case <-s.saveCtx.Done():
@@ -410,46 +1013,266 @@ func (s *GenericSorter[E]) saveChunksOptimized() error {
 	}
 }
 
+// saveChunkInOrder saves chunks in the order they were read from the input, so that
+// the index of each chunk in temporary storage matches its position in the input.
+// Chunks finished early by the sort workers are held until all earlier chunks are saved;
+// buildChunks stops reading once saveWindow is full, which bounds how many can be waiting.
+func (s *GenericSorter[E]) saveChunkInOrder(b *genericChunk[E]) error {
+	if b.seq != s.nextSaveSeq {
+		if s.pendingChunks == nil {
+			s.pendingChunks = make(map[int]*genericChunk[E])
+		}
+		s.pendingChunks[b.seq] = b
+		return nil
+	}
+	for b != nil {
+		if err := s.saveChunk(b); err != nil {
+			return err
+		}
+		<-s.saveWindow
+		s.nextSaveSeq++
+		b = s.pendingChunks[s.nextSaveSeq]
+		delete(s.pendingChunks, s.nextSaveSeq)
+	}
+	return nil
+}
+
 // saveChunk processes a single chunk
 func (s *GenericSorter[E]) saveChunk(b *genericChunk[E]) error {
 	scratchPtr := s.pools.scratchPool.Get().(*[]byte)
 	scratch := *scratchPtr
 	defer s.pools.scratchPool.Put(scratchPtr)
 
-	for _, d := range b.data {
+	// with a transform, the chunk is serialized in memory and transformed in blocks
+	var w io.Writer = s.tempWriter
+	var buf *bytes.Buffer
+	if s.config.ChunkTransformWrite != nil {
+		buf = &bytes.Buffer{}
+		w = buf
+	}
+
+	var written int64
+	for i, d := range b.data {
 		// binary encoding for size
 		raw, err := s.toBytes(d)
 		if err != nil {
 			s.putChunk(b) // Return chunk to pool on error
 			return NewSerializationError(err, "saveChunk")
 		}
+		if s.config.VerifyDeterministicSerialization && i%verifySerializationInterval == 0 {
+			if err := s.verifySerialization(d, raw); err != nil {
+				s.putChunk(b) // Return chunk to pool on error
+				return err
+			}
+		}
 		n := binary.PutUvarint(scratch, uint64(len(raw)))
-		_, err = s.tempWriter.Write(scratch[:n])
+		_, err = w.Write(scratch[:n])
 		if err != nil {
 			s.putChunk(b) // Return chunk to pool on error
 			return NewDiskError(err, "write size header", "")
 		}
 		// add data
-		_, err = s.tempWriter.Write(raw)
+		_, err = w.Write(raw)
 		if err != nil {
 			s.putChunk(b) // Return chunk to pool on error
 			return NewDiskError(err, "write data", "")
 		}
+		written += int64(n + len(raw))
+		if buf != nil && buf.Len() >= transformBlockSize {
+			if err := s.writeTransformBlock(buf, scratch); err != nil {
+				s.putChunk(b) // Return chunk to pool on error
+				return err
+			}
+		}
 	}
+	if buf != nil {
+		if err := s.writeTransformBlock(buf, scratch); err != nil {
+			s.putChunk(b) // Return chunk to pool on error
+			return err
+		}
+	}
+	s.memUsage.spilledRecords.Add(int64(len(b.data)))
+	s.memUsage.spilledBytes.Add(written)
+	s.progress.chunksSpilled.Add(1)
+	chunkID := s.tempWriter.Size() - 1
 	_, err := s.tempWriter.Next()
 	if err != nil {
 		s.putChunk(b) // Return chunk to pool on error
 		return NewDiskError(err, "next chunk", "")
 	}
+	if s.logger != nil {
+		s.logger.Debug("extsort: chunk spilled", "chunk", chunkID, "records", len(b.data), "bytes", written)
+	}
+	if s.onChunkSpilled != nil && len(b.data) > 0 {
+		s.onChunkSpilled(chunkID, b.data[0], b.data[len(b.data)-1], len(b.data))
+	}
+	s.recordChunkBounds(b.data)
+	s.releaseRecords(b.data)
+	s.runs = append(s.runs, runInfo{id: s.newRunID(), records: int64(len(b.data)), bytes: written})
 	// Successfully processed chunk, return to pool
 	s.putChunk(b)
+	if n := s.runsBeforeMerge(); n > 0 && s.tempWriter.Size()-1 >= n {
+		return s.mergeRuns()
+	}
+	return nil
+}
+
+// releaseRuns releases the temporary file holding runs that have been merged. With
+// Config.ReuseTempFiles, the file is kept as the spare to write the next merge to.
+func (s *GenericSorter[E]) releaseRuns(runs tempfile.TempReader) {
+	if !s.config.ReuseTempFiles {
+		_ = runs.Close()
+		return
+	}
+	w, err := tempfile.Recycle(runs)
+	if errors.Is(err, tempfile.ErrNotRecyclable) {
+		_ = runs.Close()
+		return
+	}
+	if err == nil {
+		s.spareWriter = w
+	}
+}
+
+// closeSpareWriter releases the spare temporary file kept with Config.ReuseTempFiles.
+func (s *GenericSorter[E]) closeSpareWriter() {
+	if s.spareWriter != nil {
+		_ = s.spareWriter.Close()
+		s.spareWriter = nil
+	}
+}
+
+// runsBeforeMerge returns the number of spilled runs that triggers an intermediate
+// merge, or 0 if runs are only merged once the input has been read.
+func (s *GenericSorter[E]) runsBeforeMerge() int {
+	if s.config.MaxRunsBeforeMerge == 0 && s.config.Limit > 0 && s.config.ChunkTransformWrite == nil {
+		// runs of at most Limit records are cheap to merge
+		return 2
+	}
+	return s.config.MaxRunsBeforeMerge
+}
+
+// truncateChunk cuts a sorted chunk down to the records that can be among the
+// first Config.Limit records of the output.
+func (s *GenericSorter[E]) truncateChunk(b *genericChunk[E]) {
+	if s.config.Limit > 0 && len(b.data) > s.config.Limit {
+		if s.onRelease != nil {
+			for _, d := range b.data[s.config.Limit:] {
+				s.onRelease(d)
+			}
+		}
+		clear(b.data[s.config.Limit:]) // release the records for garbage collection
+		b.data = b.data[:s.config.Limit]
+	}
+}
+
+// mergeRuns merges all runs saved so far into a single run at the start of a new
+// temporary file, releasing the old one. It is called by the save worker while the
+// input is still being read, to enforce Config.MaxRunsBeforeMerge or Config.Limit.
+func (s *GenericSorter[E]) mergeRuns() error {
+	numRuns := s.tempWriter.Size() - 1
+	runs, err := s.tempWriter.Save()
+	if err != nil {
+		return NewDiskError(err, "save runs", "")
+	}
+	defer func() { s.releaseRuns(runs) }()
+	if s.spareWriter != nil {
+		s.tempWriter, s.spareWriter = s.spareWriter, nil
+	} else {
+		s.tempWriter, err = s.newTempWriter()
+	}
+	if err != nil {
+		// the saved writer is released with runs, so it must not be closed again
+		s.tempWriter = nil
+		return NewResourceError(err, "temp file", "mergeRuns")
+	}
+
+	readers := make([]*bufio.Reader, runs.Size())
+	for i := range readers {
+		if s.skipChunk(i) {
+			readers[i] = bufio.NewReader(bytes.NewReader(nil))
+			continue
+		}
+		readers[i] = tempfile.ReadSize(runs, i, s.mergeReadSize(len(readers)))
+	}
+	scratchPtr := s.pools.scratchPool.Get().(*[]byte)
+	scratch := *scratchPtr
+	defer s.pools.scratchPool.Put(scratchPtr)
+	var records int
+	var written int64
+	var first, last E
+	err = mergeSorted(readers, s.fromBytes, s.sortCompare, s.config.MergeStrategy, func(rec E) error {
+		select {
+		case <-s.saveCtx.Done():
+			return s.saveCtx.Err()
+		default:
+		}
+		// records outside the key range or past the limit would never be emitted
+		if !s.inKeyRange(rec) || (s.config.Limit > 0 && records >= s.config.Limit) {
+			return nil
+		}
+		raw, err := s.toBytes(rec)
+		if err != nil {
+			return NewSerializationError(err, "mergeRuns")
+		}
+		n := binary.PutUvarint(scratch, uint64(len(raw)))
+		if _, err := s.tempWriter.Write(scratch[:n]); err != nil {
+			return NewDiskError(err, "write size header", "")
+		}
+		if _, err := s.tempWriter.Write(raw); err != nil {
+			return NewDiskError(err, "write data", "")
+		}
+		if records == 0 {
+			first = rec
+		}
+		last = rec
+		records++
+		written += int64(n + len(raw))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := s.tempWriter.Next(); err != nil {
+		return NewDiskError(err, "next chunk", "")
+	}
+	merged := runInfo{id: s.newRunID(), records: int64(records), bytes: written}
+	s.recordMerge(s.chunkRuns(0, numRuns), merged.id)
+	s.runs = append(s.runs[:0], merged)
+	s.chunkBounds = s.chunkBounds[:0]
+	if records > 0 {
+		s.recordChunkBounds([]E{first, last})
+	} else {
+		s.recordChunkBounds(nil)
+	}
+	if s.logger != nil {
+		s.logger.Debug("extsort: runs merged", "runs", numRuns, "records", records)
+	}
+	return nil
+}
+
+// verifySerializationInterval is the interval between the records of a chunk checked
+// by Config.VerifyDeterministicSerialization.
+const verifySerializationInterval = 1024
+
+// verifySerialization serializes d again and checks that the result matches raw.
+func (s *GenericSorter[E]) verifySerialization(d E, raw []byte) error {
+	// raw may share its buffer with the next result of toBytes
+	raw = bytes.Clone(raw)
+	again, err := s.toBytes(d)
+	if err != nil {
+		return NewSerializationError(err, "saveChunk")
+	}
+	if !bytes.Equal(raw, again) {
+		return NewSerializationError(ErrNondeterministicSerialization, "saveChunk")
+	}
 	return nil
 }
 
 // mergeNChunks runs asynchronously in the background feeding data to getNext
 // sends errors to s.mergeErrorChan. Uses parallel merging for better performance.
 func (s *GenericSorter[E]) mergeNChunks(ctx context.Context) {
-	defer close(s.mergeChunkChan)
+	defer s.closeOutput()
 	defer func() {
 		if s.tempReader != nil {
 			err := s.tempReader.Close()
@@ -464,6 +1287,8 @@ func (s *GenericSorter[E]) mergeNChunks(ctx context.Context) {
 	}()
 	// Always ensure error channel is closed
 	defer close(s.mergeErrChan)
+	// runs first, so progress is complete by the time the output closes
+	defer s.finish()
 
 	if s.tempReader == nil {
 		return
@@ -474,6 +1299,17 @@ func (s *GenericSorter[E]) mergeNChunks(ctx context.Context) {
 		return
 	}
 
+	s.memUsage.mergeBufferSize.Store(int64(numChunks) * int64(s.mergeReadSize(numChunks)))
+	defer s.memUsage.mergeBufferSize.Store(0)
+
+	if s.logger != nil {
+		s.logger.Info("extsort: merge started", "chunks", numChunks)
+		defer s.logger.Info("extsort: merge finished", "chunks", numChunks)
+	}
+	if s.config.Heartbeat != nil {
+		defer startHeartbeat(s.config.Heartbeat, s.config.HeartbeatInterval)()
+	}
+
 	// For small number of chunks, use single-threaded merge
 	if numChunks <= s.config.NumWorkers {
 		s.mergeNChunksSingleThreaded(ctx)
@@ -484,47 +1320,23 @@ func (s *GenericSorter[E]) mergeNChunks(ctx context.Context) {
 	s.mergeNChunksParallel(ctx)
 }
 
-// mergeNChunksSingleThreaded is the original single-threaded implementation
+// mergeNChunksSingleThreaded merges all chunks in the calling goroutine
 func (s *GenericSorter[E]) mergeNChunksSingleThreaded(ctx context.Context) {
-	pq := queue.NewPriorityQueue(func(a, b *mergeFile[E]) int {
-		return s.compareFunc(a.nextRec, b.nextRec)
-	})
-
-	for i := 0; i < s.tempReader.Size(); i++ {
-		merge := &mergeFile[E]{
-			fromBytes: s.fromBytes,
-			reader:    s.tempReader.Read(i),
-		}
-		_, ok, err := merge.getNext() // start the merge by preloading the values
-		if err == io.EOF || !ok {
-			continue
-		}
+	s.recordMerge(s.chunkRuns(0, s.tempReader.Size()), -1)
+	readers := make([]*bufio.Reader, s.tempReader.Size())
+	for i := range readers {
+		r, err := s.chunkReader(i)
 		if err != nil {
-			s.mergeErrChan <- err
+			s.sendErr(err)
 			return
 		}
-		pq.Push(merge)
+		readers[i] = r
 	}
-
-	for pq.Len() > 0 {
-		merge := pq.Peek()
-		rec, more, err := merge.getNext()
-		if err != nil {
-			s.mergeErrChan <- err
-			return
-		}
-		if more {
-			pq.PeekUpdate()
-		} else {
-			pq.Pop()
-		}
-		// check for err in context just in case
-		select {
-		case s.mergeChunkChan <- rec:
-		case <-ctx.Done():
-			s.mergeErrChan <- ctx.Err()
-			return
-		}
+	err := mergeSorted(readers, s.fromBytes, s.sortCompare, s.config.MergeStrategy, func(rec E) error {
+		return s.emit(ctx, rec)
+	})
+	if err != nil {
+		s.sendErr(err)
 	}
 }
 
@@ -553,6 +1365,7 @@ func (s *GenericSorter[E]) mergeNChunksParallel(ctx context.Context) {
 	var wg sync.WaitGroup
 	chunksPerWorker := (numChunks + numWorkers - 1) / numWorkers
 	workersStarted := 0
+	var workerRuns []runInfo
 
 	for i := 0; i < numWorkers; i++ {
 		startChunk := i * chunksPerWorker
@@ -563,6 +1376,7 @@ func (s *GenericSorter[E]) mergeNChunksParallel(ctx context.Context) {
 		if startChunk >= numChunks {
 			break
 		}
+		workerRuns = append(workerRuns, s.recordMerge(s.chunkRuns(startChunk, endChunk), s.newRunID()))
 		workersStarted++
 		wg.Add(1)
 
@@ -576,6 +1390,7 @@ func (s *GenericSorter[E]) mergeNChunksParallel(ctx context.Context) {
 			}
 		}(i, startChunk, endChunk)
 	}
+	s.recordMerge(workerRuns, -1)
 
 	// Start error collector with wait group for synchronization
 	var errorCollectorWg sync.WaitGroup
@@ -597,7 +1412,9 @@ func (s *GenericSorter[E]) mergeNChunksParallel(ctx context.Context) {
 	finalMergeWg.Add(1)
 	go func() {
 		defer finalMergeWg.Done()
-		s.finalMergeSimple(mergeCtx, intermediateChans[:workersStarted])
+		if err := s.finalMergeSimple(mergeCtx, intermediateChans[:workersStarted]); err != nil {
+			errChan <- err
+		}
 	}()
 
 	// Wait for all workers to complete
@@ -613,43 +1430,102 @@ func (s *GenericSorter[E]) mergeNChunksParallel(ctx context.Context) {
 
 	// Send any collected error (now safe to read mergeErr)
 	if mergeErr != nil {
-		s.mergeErrChan <- mergeErr
+		s.sendErr(mergeErr)
 	} else if ctx.Err() != nil {
-		s.mergeErrChan <- ctx.Err()
+		s.sendErr(ctx.Err())
 	}
 }
 
 // mergeWorkerSimple merges a subset of chunks with proper context handling
 func (s *GenericSorter[E]) mergeWorkerSimple(ctx context.Context, startChunk, endChunk int, output chan<- E) error {
-	pq := queue.NewPriorityQueue(func(a, b *mergeFile[E]) int {
-		return s.compareFunc(a.nextRec, b.nextRec)
-	})
-
-	// Initialize merge files for this worker's chunk range
+	readers := make([]*bufio.Reader, 0, endChunk-startChunk)
 	for i := startChunk; i < endChunk; i++ {
-		merge := &mergeFile[E]{
-			fromBytes: s.fromBytes,
-			reader:    s.tempReader.Read(i),
-		}
-		_, ok, err := merge.getNext()
-		if err == io.EOF || !ok {
-			continue
-		}
+		r, err := s.chunkReader(i)
 		if err != nil {
 			return err
 		}
-		pq.Push(merge)
+		readers = append(readers, r)
 	}
-
-	// Merge this worker's chunks
-	for pq.Len() > 0 {
-		// Check context before processing
+	return mergeSorted(readers, s.fromBytes, s.sortCompare, s.config.MergeStrategy, func(rec E) error {
+		// Check context before sending
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		select {
+		case output <- rec:
+			return nil
 		case <-ctx.Done():
 			return ctx.Err()
-		default:
 		}
+	})
+}
+
+// chunkReader returns a reader for the records of chunk i, reversing
+// Config.ChunkTransformWrite if it was applied when the chunk was saved.
+// Chunks outside the range set by SetKeyRange are not read.
+func (s *GenericSorter[E]) chunkReader(i int) (*bufio.Reader, error) {
+	if s.skipChunk(i) {
+		return bufio.NewReader(bytes.NewReader(nil)), nil
+	}
+	var r *bufio.Reader
+	if s.config.MergeBufferBytes > 0 {
+		r = tempfile.ReadSize(s.tempReader, i, s.mergeReadSize(s.tempReader.Size()))
+	} else {
+		r = s.tempReader.Read(i)
+	}
+	if s.config.ChunkTransformRead == nil {
+		return r, nil
+	}
+	// the decoded block is the read-ahead, so the reader over it only needs
+	// the smallest buffer bufio allows
+	return bufio.NewReaderSize(&transformReader{r: r, transform: s.config.ChunkTransformRead}, 16), nil
+}
+
+// mergeReadSize returns the size of the read buffer of each of numChunks chunks
+// being merged, dividing Config.MergeBufferBytes among them when it is set.
+func (s *GenericSorter[E]) mergeReadSize(numChunks int) int {
+	if s.config.MergeBufferBytes <= 0 {
+		return tempfile.BufferSize
+	}
+	// bufio.Reader rounds smaller buffers up to 16 bytes
+	return max(s.config.MergeBufferBytes/numChunks, 16)
+}
 
+// mergeSorted performs a k-way merge of sorted streams of framed records,
+// calling emit for every record in sorted order. Records that compare equal are
+// taken from the stream that comes first in readers. It stops at the first error
+// returned by a reader, by deserialization, or by emit.
+func mergeSorted[E any](readers []*bufio.Reader, fromBytes FromBytesGeneric[E], compareFunc CompareGeneric[E], strategy MergeStrategy, emit func(E) error) (err error) {
+	defer recoverCompareFailure(&err)
+	streams := make([]*mergeFile[E], 0, len(readers))
+	for i, reader := range readers {
+		merge := &mergeFile[E]{
+			fromBytes: fromBytes,
+			reader:    reader,
+			index:     i,
+		}
+		_, ok, err := merge.getNext() // start the merge by preloading the values
+		if err != nil {
+			return err
+		}
+		if ok {
+			streams = append(streams, merge)
+		}
+	}
+	if strategy == MergeLoserTree && len(streams) > 1 {
+		return newLoserTree(streams, compareFunc).merge(emit)
+	}
+
+	pq := queue.NewPriorityQueue(func(a, b *mergeFile[E]) int {
+		if mergesBefore(compareFunc, a.nextRec, a.index, b.nextRec, b.index) {
+			return -1
+		}
+		return 1
+	})
+	for _, merge := range streams {
+		pq.Push(merge)
+	}
+	for pq.Len() > 1 {
 		merge := pq.Peek()
 		rec, more, err := merge.getNext()
 		if err != nil {
@@ -660,26 +1536,56 @@ func (s *GenericSorter[E]) mergeWorkerSimple(ctx context.Context, startChunk, en
 		} else {
 			pq.Pop()
 		}
+		if err := emit(rec); err != nil {
+			return err
+		}
+	}
+	if pq.Len() == 0 {
+		return nil
+	}
 
-		select {
-		case output <- rec:
-		case <-ctx.Done():
-			return ctx.Err()
+	// the last stream, or a single run, is copied through without the heap
+	merge := pq.Pop()
+	for {
+		rec, more, err := merge.getNext()
+		if err != nil {
+			return err
+		}
+		if err := emit(rec); err != nil {
+			return err
+		}
+		if !more {
+			return nil
 		}
 	}
+}
 
-	return nil
+// mergesBefore reports whether record a, from the stream at index ia, is merged
+// before record b, from the stream at index ib. Equal records are taken from the
+// stream with the lower index first. Only whether one record is less than the other
+// is asked, so a single call to compareFunc is enough even when it cannot report
+// equality, as with the adapter of a legacy CompareLessFunc.
+func mergesBefore[E any](compareFunc CompareGeneric[E], a E, ia int, b E, ib int) bool {
+	if ia < ib {
+		return compareFunc(b, a) >= 0
+	}
+	return compareFunc(a, b) < 0
 }
 
 // finalMergeSimple performs streaming merge with simpler synchronization
-func (s *GenericSorter[E]) finalMergeSimple(ctx context.Context, intermediateChans []chan E) {
+func (s *GenericSorter[E]) finalMergeSimple(ctx context.Context, intermediateChans []chan E) (err error) {
+	defer recoverCompareFailure(&err)
+	// equal records are taken from the worker merging the earlier chunks
 	pq := queue.NewPriorityQueue(func(a, b *channelMergeSource[E]) int {
-		return s.compareFunc(a.nextRec, b.nextRec)
+		if mergesBefore(s.sortCompare, a.nextRec, a.index, b.nextRec, b.index) {
+			return -1
+		}
+		return 1
 	})
 
 	// Initialize sources
-	for _, ch := range intermediateChans {
-		source := &channelMergeSource[E]{ch: ch}
+	for i, ch := range intermediateChans {
+		source := &channelMergeSource[E]{ch: ch, index: i}
 		if source.getNextSimple() {
 			pq.Push(source)
 		}
@@ -689,25 +1595,23 @@ func (s *GenericSorter[E]) finalMergeSimple(ctx context.Context, intermediateCha
 	for pq.Len() > 0 {
 		// Check if context is cancelled before each iteration
 		if ctx.Err() != nil {
-			return
+			return ctx.Err()
 		}
 
 		source := pq.Peek()
 
 		// Try to send with context cancellation support
-		select {
-		case s.mergeChunkChan <- source.nextRec:
-			// Successfully sent, try to get next from this source
-			if source.getNextSimple() {
-				pq.PeekUpdate()
-			} else {
-				pq.Pop()
-			}
-		case <-ctx.Done():
-			// Context cancelled, exit immediately
-			return
+		if err := s.emit(ctx, source.nextRec); err != nil {
+			return err
+		}
+		// Successfully sent, try to get next from this source
+		if source.getNextSimple() {
+			pq.PeekUpdate()
+		} else {
+			pq.Pop()
 		}
 	}
+	return nil
 }
 
 // channelMergeSource represents a source of sorted data from a channel
@@ -715,6 +1619,7 @@ type channelMergeSource[E any] struct {
 	ch      <-chan E
 	nextRec E
 	hasNext bool
+	index   int // position of the source, used to order equal records
 }
 
 // getNextSimple reads from channel without context (channel close handles cancellation)
@@ -734,6 +1639,7 @@ type mergeFile[E any] struct {
 	nextRec   E
 	fromBytes FromBytesGeneric[E]
 	reader    *bufio.Reader
+	index     int // position of the stream, used to order equal records
 }
 
 // getNext returns the next value from the sorted chunk on disk.
@@ -0,0 +1,147 @@
+package extsort
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+)
+
+// KeyFunc extracts the sort key of a record. Records are ordered by comparing
+// their keys with bytes.Compare.
+type KeyFunc[E any] func(E) []byte
+
+// keyedRecord pairs a record with its sort key, extracted once when the record is read.
+type keyedRecord[E any] struct {
+	key []byte
+	rec E
+}
+
+// KeyedSorter provides external sorting of records ordered by a byte key that is
+// extracted once per record. The key is stored alongside each record in temporary
+// files, so neither sorting nor merging needs to recompute it.
+type KeyedSorter[E any] struct {
+	sorter *GenericSorter[keyedRecord[E]]
+	input  <-chan E
+	keyed  chan keyedRecord[E]
+	sorted <-chan keyedRecord[E]
+	output chan E
+	key    KeyFunc[E]
+}
+
+// errKeyedFrame is returned when a serialized keyed record is truncated or corrupt.
+var errKeyedFrame = errors.New("invalid keyed record frame")
+
+// makeToBytesKeyed serializes a keyed record as a uvarint key length, followed by
+// the key bytes, followed by the record serialized with toBytes.
+func makeToBytesKeyed[E any](toBytes ToBytesGeneric[E]) ToBytesGeneric[keyedRecord[E]] {
+	return func(k keyedRecord[E]) ([]byte, error) {
+		raw, err := toBytes(k.rec)
+		if err != nil {
+			return nil, err
+		}
+		b := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(k.key)+len(raw)), uint64(len(k.key)))
+		b = append(b, k.key...)
+		return append(b, raw...), nil
+	}
+}
+
+// makeFromBytesKeyed deserializes a keyed record written by makeToBytesKeyed.
+// The key shares the backing array of d rather than being copied.
+func makeFromBytesKeyed[E any](fromBytes FromBytesGeneric[E]) FromBytesGeneric[keyedRecord[E]] {
+	return func(d []byte) (keyedRecord[E], error) {
+		keyLen, n := binary.Uvarint(d)
+		if n <= 0 || uint64(len(d)-n) < keyLen {
+			return keyedRecord[E]{}, errKeyedFrame
+		}
+		end := n + int(keyLen)
+		rec, err := fromBytes(d[end:])
+		if err != nil {
+			return keyedRecord[E]{}, err
+		}
+		return keyedRecord[E]{key: d[n:end:end], rec: rec}, nil
+	}
+}
+
+// compareKeyed orders keyed records by their keys.
+func compareKeyed[E any](a, b keyedRecord[E]) int {
+	return bytes.Compare(a.key, b.key)
+}
+
+// Keyed performs external sorting on a channel of records ordered by the byte key
+// returned by keyFunc. The key is extracted exactly once per record, when it is read
+// from input, and is stored in temporary files next to the record so that an
+// expensive key extraction is never repeated during comparisons or the merge.
+// Returns the sorter instance, output channel with sorted records, and error channel.
+func Keyed[E any](input <-chan E, fromBytes FromBytesGeneric[E], toBytes ToBytesGeneric[E], keyFunc KeyFunc[E], config *Config) (*KeyedSorter[E], <-chan E, <-chan error) {
+	s := newKeyedSorter(input, keyFunc, config)
+	var errChan <-chan error
+	s.sorter, s.sorted, errChan = Generic(s.keyed, makeFromBytesKeyed(fromBytes), makeToBytesKeyed(toBytes), compareKeyed[E], config)
+	if s.sorter == nil {
+		close(s.output)
+		return nil, s.output, errChan
+	}
+	return s, s.output, errChan
+}
+
+// KeyedMock performs external sorting on records ordered by a byte key with a mock
+// implementation that uses in-memory storage. The parameter n specifies the initial
+// capacity of the in-memory buffer.
+func KeyedMock[E any](input <-chan E, fromBytes FromBytesGeneric[E], toBytes ToBytesGeneric[E], keyFunc KeyFunc[E], config *Config, n int) (*KeyedSorter[E], <-chan E, <-chan error) {
+	s := newKeyedSorter(input, keyFunc, config)
+	var errChan <-chan error
+	s.sorter, s.sorted, errChan = MockGeneric(s.keyed, makeFromBytesKeyed(fromBytes), makeToBytesKeyed(toBytes), compareKeyed[E], config, n)
+	return s, s.output, errChan
+}
+
+// newKeyedSorter creates a KeyedSorter with its channels but without the underlying sorter.
+func newKeyedSorter[E any](input <-chan E, keyFunc KeyFunc[E], config *Config) *KeyedSorter[E] {
+	config = mergeConfig(config)
+	return &KeyedSorter[E]{
+		input:  input,
+		keyed:  make(chan keyedRecord[E], config.ChanBuffSize),
+		output: make(chan E, config.SortedChanBuffSize),
+		key:    keyFunc,
+	}
+}
+
+// Sort sorts the input channel by key, with the same semantics as GenericSorter.Sort.
+// Keys are extracted and results are unwrapped by goroutines that stop when ctx is done.
+func (s *KeyedSorter[E]) Sort(ctx context.Context) {
+	// the sorter has stopped reading input once Sort returns
+	extractCtx, cancel := context.WithCancel(ctx)
+	go s.extractKeys(extractCtx)
+	s.sorter.Sort(ctx)
+	cancel()
+	go s.unwrap(ctx)
+}
+
+// extractKeys pairs every input record with its key and passes it to the sorter.
+func (s *KeyedSorter[E]) extractKeys(ctx context.Context) {
+	defer close(s.keyed)
+	for rec := range s.input {
+		select {
+		case s.keyed <- keyedRecord[E]{key: s.key(rec), rec: rec}:
+		case <-ctx.Done():
+			if !s.sorter.config.NoDrainOnAbort {
+				drainInput(s.input)
+			}
+			return
+		}
+	}
+}
+
+// unwrap delivers the records from the sorter output without their keys.
+func (s *KeyedSorter[E]) unwrap(ctx context.Context) {
+	defer close(s.output)
+	for k := range s.sorted {
+		select {
+		case s.output <- k.rec:
+		case <-ctx.Done():
+			// drain so the sorter can shut down
+			for range s.sorted {
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package extsort
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// KV is a key-value record where only the key participates in ordering.
+// The value is an opaque payload that is carried alongside the key.
+type KV struct {
+	Key   []byte
+	Value []byte
+}
+
+// KVSorter provides external sorting for key-value records.
+// It embeds GenericSorter[KV] and stores keys and values as separate fields
+// in temporary files so that comparisons only ever touch the keys.
+type KVSorter struct {
+	GenericSorter[KV]
+}
+
+// errKVFrame is returned when a serialized KV record is truncated or corrupt.
+var errKVFrame = errors.New("invalid key-value frame")
+
+// toBytesKV serializes a KV record. The framing is a uvarint key length,
+// followed by the key bytes, followed by the value bytes. The value length is
+// implied by the record length, which the sorter already stores for every record.
+func toBytesKV(kv KV) ([]byte, error) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], uint64(len(kv.Key)))
+	b := make([]byte, n+len(kv.Key)+len(kv.Value))
+	copy(b, scratch[:n])
+	copy(b[n:], kv.Key)
+	copy(b[n+len(kv.Key):], kv.Value)
+	return b, nil
+}
+
+// fromBytesKV deserializes a KV record written by toBytesKV.
+// The returned key and value share the backing array of d rather than being copied.
+func fromBytesKV(d []byte) (KV, error) {
+	keyLen, n := binary.Uvarint(d)
+	if n <= 0 || uint64(len(d)-n) < keyLen {
+		return KV{}, errKVFrame
+	}
+	end := n + int(keyLen)
+	return KV{Key: d[n:end:end], Value: d[end:]}, nil
+}
+
+// makeCompareKV adapts a key comparison function to compare KV records by key.
+// A nil compareFunc orders keys lexicographically using bytes.Compare.
+func makeCompareKV(compareFunc CompareGeneric[[]byte]) CompareGeneric[KV] {
+	if compareFunc == nil {
+		compareFunc = bytes.Compare
+	}
+	return func(a, b KV) int {
+		return compareFunc(a.Key, b.Key)
+	}
+}
+
+// KeyValues performs external sorting on a channel of key-value records, ordering
+// them by key only. If compareFunc is nil, keys are ordered with bytes.Compare.
+// Returns the sorter instance, output channel with sorted records, and error channel.
+func KeyValues(input <-chan KV, compareFunc CompareGeneric[[]byte], config *Config) (*KVSorter, <-chan KV, <-chan error) {
+	genericSorter, output, errChan := Generic(input, fromBytesKV, toBytesKV, makeCompareKV(compareFunc), config)
+	if genericSorter == nil {
+		return nil, output, errChan
+	}
+	s := &KVSorter{GenericSorter: *genericSorter}
+	return s, output, errChan
+}
+
+// KeyValuesMock performs external sorting on key-value records with a mock implementation
+// that uses in-memory storage. The parameter n specifies the initial capacity of the in-memory buffer.
+func KeyValuesMock(input <-chan KV, compareFunc CompareGeneric[[]byte], config *Config, n int) (*KVSorter, <-chan KV, <-chan error) {
+	genericSorter, output, errChan := MockGeneric(input, fromBytesKV, toBytesKV, makeCompareKV(compareFunc), config, n)
+	if genericSorter == nil {
+		return nil, output, errChan
+	}
+	s := &KVSorter{GenericSorter: *genericSorter}
+	return s, output, errChan
+}
@@ -0,0 +1,187 @@
+package extsort
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+
+	"github.com/lanrat/extsort/tempfile"
+)
+
+// kvRef is a key together with the location of its value in the payload file.
+type kvRef struct {
+	key    []byte
+	offset uint64
+	length uint64
+}
+
+// KVSplitSorter provides external sorting for key-value records with large values.
+// Values are written once to a separate payload file as they are read, and only the
+// keys and the locations of their values pass through the sort. This keeps the chunks
+// that are sorted and merged small, so the comparison-heavy merge reads far less data,
+// at the cost of one random read per record when the values are fetched on output.
+type KVSplitSorter struct {
+	sorter  *GenericSorter[kvRef]
+	input   <-chan KV
+	refs    chan kvRef
+	sorted  <-chan kvRef
+	sortErr <-chan error
+	output  chan KV
+	errChan chan error
+	payload *os.File
+	err     error // first payload I/O error, reported in place of the sorter's error
+}
+
+// errKVRefFrame is returned when a serialized key reference is truncated or corrupt.
+var errKVRefFrame = errors.New("invalid key reference frame")
+
+// toBytesKVRef serializes a key reference as the uvarint value offset and length,
+// followed by the key bytes.
+func toBytesKVRef(r kvRef) ([]byte, error) {
+	b := make([]byte, 0, 2*binary.MaxVarintLen64+len(r.key))
+	b = binary.AppendUvarint(b, r.offset)
+	b = binary.AppendUvarint(b, r.length)
+	return append(b, r.key...), nil
+}
+
+// fromBytesKVRef deserializes a key reference written by toBytesKVRef.
+// The key shares the backing array of d rather than being copied.
+func fromBytesKVRef(d []byte) (kvRef, error) {
+	offset, n := binary.Uvarint(d)
+	if n <= 0 {
+		return kvRef{}, errKVRefFrame
+	}
+	length, m := binary.Uvarint(d[n:])
+	if m <= 0 {
+		return kvRef{}, errKVRefFrame
+	}
+	return kvRef{key: d[n+m:], offset: offset, length: length}, nil
+}
+
+// makeCompareKVRef adapts a key comparison function to compare key references.
+func makeCompareKVRef(compareFunc CompareGeneric[[]byte]) CompareGeneric[kvRef] {
+	compareKV := makeCompareKV(compareFunc)
+	return func(a, b kvRef) int {
+		return compareKV(KV{Key: a.key}, KV{Key: b.key})
+	}
+}
+
+// KeyValuesSplit performs external sorting on a channel of key-value records like
+// KeyValues, but stores values apart from keys. It suits records whose values are
+// much larger than their keys, such as small keys with kilobyte payloads. The payload
+// file is created in Config.TempFilesDir and removed once the output is complete.
+// If compareFunc is nil, keys are ordered with bytes.Compare.
+// Returns the sorter instance, output channel with sorted records, and error channel.
+func KeyValuesSplit(input <-chan KV, compareFunc CompareGeneric[[]byte], config *Config) (*KVSplitSorter, <-chan KV, <-chan error) {
+	config = mergeConfig(config)
+	s := &KVSplitSorter{
+		input:   input,
+		refs:    make(chan kvRef, config.ChanBuffSize),
+		output:  make(chan KV, config.SortedChanBuffSize),
+		errChan: make(chan error, 1),
+	}
+	var err error
+	s.payload, err = tempfile.Create(tempfile.GetTempDir(config.TempFilesDir, true), "extsort-payload-", config.TempFileID)
+	if err != nil {
+		s.errChan <- NewResourceError(err, "payload file", "KeyValuesSplit")
+		close(s.errChan)
+		close(s.output)
+		return nil, s.output, s.errChan
+	}
+	s.sorter, s.sorted, s.sortErr = Generic(s.refs, fromBytesKVRef, toBytesKVRef, makeCompareKVRef(compareFunc), config)
+	if s.sorter == nil {
+		s.removePayload()
+		close(s.output)
+		return nil, s.output, s.sortErr
+	}
+	return s, s.output, s.errChan
+}
+
+// Sort sorts the input channel by key, with the same semantics as GenericSorter.Sort.
+// Values are written to the payload file and fetched back by goroutines that stop
+// when ctx is done.
+func (s *KVSplitSorter) Sort(ctx context.Context) {
+	// payload I/O errors stop the sorter through sortCtx
+	sortCtx, cancel := context.WithCancel(ctx)
+	go s.split(sortCtx, cancel)
+	s.sorter.Sort(sortCtx)
+	go s.fetch(sortCtx, cancel)
+}
+
+// split writes the value of every input record to the payload file and passes its
+// key and value location to the sorter. The payload file is flushed before the sorter
+// sees the end of the input, so every value can be read once sorted output begins.
+func (s *KVSplitSorter) split(ctx context.Context, cancel context.CancelFunc) {
+	defer close(s.refs)
+	w := bufio.NewWriterSize(s.payload, tempfile.BufferSize)
+	var offset uint64
+	for kv := range s.input {
+		if _, err := w.Write(kv.Value); err != nil {
+			s.err = NewDiskError(err, "write payload", s.payload.Name())
+			cancel()
+			if !s.sorter.config.NoDrainOnAbort {
+				drainInput(s.input)
+			}
+			return
+		}
+		ref := kvRef{key: kv.Key, offset: offset, length: uint64(len(kv.Value))}
+		offset += ref.length
+		select {
+		case s.refs <- ref:
+		case <-ctx.Done():
+			if !s.sorter.config.NoDrainOnAbort {
+				drainInput(s.input)
+			}
+			return
+		}
+	}
+	if err := w.Flush(); err != nil {
+		s.err = NewDiskError(err, "flush payload", s.payload.Name())
+		cancel()
+	}
+}
+
+// fetch reads the value of every sorted key from the payload file and delivers the
+// complete records, then reports the outcome of the sort and removes the payload file.
+func (s *KVSplitSorter) fetch(ctx context.Context, cancel context.CancelFunc) {
+	defer close(s.errChan)
+	defer cancel()
+	for ref := range s.sorted {
+		if s.err != nil || ctx.Err() != nil {
+			continue // drain so the sorter can shut down
+		}
+		value := make([]byte, ref.length)
+		if _, err := s.payload.ReadAt(value, int64(ref.offset)); err != nil {
+			s.err = NewDiskError(err, "read payload", s.payload.Name())
+			cancel()
+			continue
+		}
+		select {
+		case s.output <- KV{Key: ref.key, Value: value}:
+		case <-ctx.Done():
+		}
+	}
+	close(s.output)
+
+	err := <-s.sortErr
+	if s.err != nil {
+		err = s.err
+	}
+	if removeErr := s.removePayload(); removeErr != nil && err == nil {
+		err = NewDiskError(removeErr, "remove payload", s.payload.Name())
+	}
+	if err != nil {
+		s.errChan <- err
+	}
+}
+
+// removePayload closes and deletes the payload file.
+func (s *KVSplitSorter) removePayload() error {
+	err := s.payload.Close()
+	if removeErr := os.Remove(s.payload.Name()); removeErr != nil && err == nil {
+		err = removeErr
+	}
+	return err
+}
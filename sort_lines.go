@@ -0,0 +1,64 @@
+package extsort
+
+import (
+	"bufio"
+	"context"
+	"io"
+)
+
+// SortLines sorts the lines read from r, a convenience over Strings for sorting
+// text files. Lines are split as by bufio.ScanLines: the line terminator, either
+// "\n" or "\r\n", is removed, and a final line without a trailing newline is
+// still sorted. Lines longer than Config.MaxLineSize abort the sort with
+// bufio.ErrTooLong. Any error reading r is delivered on the error channel in
+// place of the sorted output being completed.
+func SortLines(ctx context.Context, r io.Reader, compareFunc CompareGeneric[string], config *Config) (<-chan string, <-chan error) {
+	config = mergeConfig(config)
+	ctx, cancel := context.WithCancel(ctx)
+
+	input := make(chan string, config.ChanBuffSize)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(input)
+		maxLineSize := config.MaxLineSize
+		if maxLineSize <= 0 {
+			maxLineSize = bufio.MaxScanTokenSize
+		}
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, min(maxLineSize, 4096)), maxLineSize)
+		for scanner.Scan() {
+			select {
+			case input <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			// stop the sorter so the partial input is not mistaken for the whole file
+			scanErr <- err
+			cancel()
+		}
+	}()
+
+	sorter, output, sortErr := Generic(input, fromBytesString, toBytesString, compareFunc, config)
+	if sorter == nil {
+		cancel()
+		return output, sortErr
+	}
+	go sorter.Sort(ctx)
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(errChan)
+		defer cancel()
+		err := <-sortErr
+		select {
+		case err = <-scanErr:
+		default:
+		}
+		if err != nil {
+			errChan <- err
+		}
+	}()
+	return output, errChan
+}
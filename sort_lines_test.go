@@ -0,0 +1,62 @@
+package extsort_test
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestSortLines verifies that lines are sorted on both the single-chunk and the
+// merge path, including a final line without a trailing newline.
+func TestSortLines(t *testing.T) {
+	text := "pear\nbanana\r\napple\n\ncherry\nfig"
+	expected := []string{"", "apple", "banana", "cherry", "fig", "pear"}
+	for _, chunkSize := range []int{2, 1000} {
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+
+		outChan, errChan := extsort.SortLines(context.Background(), strings.NewReader(text), cmp.Compare[string], config)
+		got, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+		if !slices.Equal(got, expected) {
+			t.Errorf("chunk size %d: expected %q, got %q", chunkSize, expected, got)
+		}
+	}
+}
+
+// TestSortLinesTooLong verifies that a line longer than MaxLineSize aborts the sort.
+func TestSortLinesTooLong(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 100; i++ {
+		sb.WriteString("short line\n")
+	}
+	sb.WriteString(strings.Repeat("x", 200) + "\n")
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 10
+	config.MaxLineSize = 100
+
+	outChan, errChan := extsort.SortLines(context.Background(), strings.NewReader(sb.String()), cmp.Compare[string], config)
+	if _, err := extsort.Collect(outChan, errChan, 0); !errors.Is(err, bufio.ErrTooLong) {
+		t.Fatalf("expected bufio.ErrTooLong, got %v", err)
+	}
+
+	// the same line is accepted with a larger limit
+	config.MaxLineSize = 1000
+	outChan, errChan = extsort.SortLines(context.Background(), strings.NewReader(sb.String()), cmp.Compare[string], config)
+	got, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if len(got) != 101 {
+		t.Fatalf("expected 101 lines, got %d", len(got))
+	}
+}
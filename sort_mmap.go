@@ -0,0 +1,153 @@
+package extsort
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/lanrat/extsort/tempfile"
+)
+
+// SortMmap sorts a file of fixed-size records into a new file at outPath.
+// The input file is memory-mapped and only record positions flow through the sorter,
+// so records are never copied into channels or temporary files; the sorted positions
+// are spilled to disk as usual when the file holds more than config.ChunkSize records.
+// This makes SortMmap suitable for files larger than RAM, which is paged in by the OS
+// as needed. Once sorted, records are copied to outPath in order.
+//
+// The size of the file at path must be a multiple of recordSize, otherwise
+// ErrPartialRecord is returned. If compareFunc is nil, records are compared with
+// bytes.Compare. The slices passed to compareFunc point into the mapped file and
+// must not be modified or retained. outPath must not name the input file, which is
+// reported as a ConfigError.
+//
+// On platforms without memory-mapping support the input file is read into memory.
+func SortMmap(ctx context.Context, path, outPath string, recordSize int, compareFunc CompareGeneric[[]byte], config *Config) error {
+	if recordSize < 1 {
+		return &ConfigError{Field: "recordSize", Value: recordSize, Reason: "must be > 0"}
+	}
+	if compareFunc == nil {
+		compareFunc = bytes.Compare
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return NewDiskError(err, "open input file", path)
+	}
+	defer func() { _ = in.Close() }()
+	info, err := in.Stat()
+	if err != nil {
+		return NewDiskError(err, "stat input file", path)
+	}
+	if info.Size()%int64(recordSize) != 0 {
+		return fmt.Errorf("%s: %w: size %d, record size %d", path, ErrPartialRecord, info.Size(), recordSize)
+	}
+	count := info.Size() / int64(recordSize)
+
+	var data []byte
+	if count > 0 {
+		var unmap func() error
+		data, unmap, err = mapFile(in, info.Size())
+		if err != nil {
+			return NewResourceError(err, "mmap", path)
+		}
+		defer func() { _ = unmap() }()
+	}
+	record := func(i int64) []byte {
+		start := i * int64(recordSize)
+		end := start + int64(recordSize)
+		return data[start:end:end]
+	}
+
+	// creating the output truncates it, which must not happen to the mapped input
+	if outInfo, err := os.Stat(outPath); err == nil && os.SameFile(info, outInfo) {
+		return &ConfigError{Field: "outPath", Value: outPath, Reason: "must not be the input file"}
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return NewDiskError(err, "create output file", outPath)
+	}
+	defer func() { _ = out.Close() }()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	config = mergeConfig(config)
+	input := make(chan int64, config.ChanBuffSize)
+	compare := func(a, b int64) int {
+		return compareFunc(record(a), record(b))
+	}
+	sorter, output, errChan := Generic(input, fromBytesRecordIndex, toBytesRecordIndex, compare, config)
+	if sorter == nil {
+		return <-errChan
+	}
+	// an aborted sort leaves chunk sorts running, which compare records in the
+	// mapping, so they must finish before it is unmapped
+	defer sorter.chunkSorts.Wait()
+
+	go func() {
+		defer close(input)
+		for i := int64(0); i < count; i++ {
+			select {
+			case input <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	sorter.Sort(ctx)
+
+	writeErr := writeRecords(out, output, record)
+	if writeErr != nil {
+		// stop the merge and drain the output so it can shut down
+		cancel()
+		for range output {
+		}
+	}
+	if err := <-errChan; err != nil && writeErr == nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if err := out.Sync(); err != nil {
+		return NewDiskError(err, "sync output file", outPath)
+	}
+	if err := out.Close(); err != nil {
+		return NewDiskError(err, "close output file", outPath)
+	}
+	return nil
+}
+
+// writeRecords writes the record at every position received from output to f.
+func writeRecords(f *os.File, output <-chan int64, record func(int64) []byte) error {
+	w := bufio.NewWriterSize(f, tempfile.BufferSize)
+	for i := range output {
+		if _, err := w.Write(record(i)); err != nil {
+			return NewDiskError(err, "write record", f.Name())
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return NewDiskError(err, "flush output file", f.Name())
+	}
+	return nil
+}
+
+// toBytesRecordIndex serializes a record position for temporary storage.
+func toBytesRecordIndex(i int64) ([]byte, error) {
+	return binary.AppendUvarint(nil, uint64(i)), nil
+}
+
+// fromBytesRecordIndex deserializes a record position written by toBytesRecordIndex.
+func fromBytesRecordIndex(b []byte) (int64, error) {
+	i, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid record index encoding")
+	}
+	return int64(i), nil
+}
@@ -0,0 +1,174 @@
+package extsort_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lanrat/extsort"
+)
+
+// writeRecordFile writes n random records of recordSize bytes to a temporary file.
+func writeRecordFile(t *testing.T, n, recordSize int) string {
+	t.Helper()
+	data := make([]byte, n*recordSize)
+	rand.Read(data)
+	path := filepath.Join(t.TempDir(), "records.dat")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSortMmap(t *testing.T) {
+	const recordSize = 16
+	for _, n := range []int{0, 1, 10, 5000} {
+		path := writeRecordFile(t, n, recordSize)
+		outPath := filepath.Join(t.TempDir(), "sorted.dat")
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 500
+		if err := extsort.SortMmap(context.Background(), path, outPath, recordSize, nil, config); err != nil {
+			t.Fatalf("n %d: SortMmap error: %v", n, err)
+		}
+
+		in, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := os.ReadFile(outPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != len(in) {
+			t.Fatalf("n %d: expected %d bytes, got %d", n, len(in), len(out))
+		}
+
+		seen := make(map[string]int)
+		for i := 0; i < len(in); i += recordSize {
+			seen[string(in[i:i+recordSize])]++
+		}
+		for i := 0; i < len(out); i += recordSize {
+			rec := out[i : i+recordSize]
+			if i > 0 && bytes.Compare(out[i-recordSize:i], rec) > 0 {
+				t.Fatalf("n %d: output not sorted at record %d", n, i/recordSize)
+			}
+			seen[string(rec)]--
+		}
+		for rec, count := range seen {
+			if count != 0 {
+				t.Fatalf("n %d: record %x count mismatch %d", n, rec, count)
+			}
+		}
+	}
+}
+
+func TestSortMmapCustomCompare(t *testing.T) {
+	const recordSize = 4
+	path := writeRecordFile(t, 1000, recordSize)
+	outPath := filepath.Join(t.TempDir(), "sorted.dat")
+
+	descending := func(a, b []byte) int { return bytes.Compare(b, a) }
+	if err := extsort.SortMmap(context.Background(), path, outPath, recordSize, descending, nil); err != nil {
+		t.Fatalf("SortMmap error: %v", err)
+	}
+	out, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := recordSize; i < len(out); i += recordSize {
+		if bytes.Compare(out[i-recordSize:i], out[i:i+recordSize]) < 0 {
+			t.Fatalf("output not in descending order at record %d", i/recordSize)
+		}
+	}
+}
+
+func TestSortMmapInvalid(t *testing.T) {
+	path := writeRecordFile(t, 10, 3)
+	outPath := filepath.Join(t.TempDir(), "sorted.dat")
+
+	err := extsort.SortMmap(context.Background(), path, outPath, 4, nil, nil)
+	if !errors.Is(err, extsort.ErrPartialRecord) {
+		t.Fatalf("expected ErrPartialRecord, got %v", err)
+	}
+
+	var configErr *extsort.ConfigError
+	err = extsort.SortMmap(context.Background(), path, outPath, 0, nil, nil)
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+
+	err = extsort.SortMmap(context.Background(), filepath.Join(t.TempDir(), "missing"), outPath, 4, nil, nil)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
+// TestSortMmapSameFile verifies that sorting a file onto itself, directly or through
+// a hard link, is rejected before the mapped input is truncated.
+func TestSortMmapSameFile(t *testing.T) {
+	path := writeRecordFile(t, 100, 8)
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(filepath.Dir(path), "link.dat")
+	if err := os.Link(path, link); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, outPath := range []string{path, link} {
+		var configErr *extsort.ConfigError
+		err := extsort.SortMmap(context.Background(), path, outPath, 8, nil, nil)
+		if !errors.As(err, &configErr) || configErr.Field != "outPath" {
+			t.Fatalf("%s: expected ConfigError for outPath, got %v", outPath, err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: input file was modified", outPath)
+		}
+	}
+}
+
+// TestSortMmapCancel verifies that a cancelled SortMmap waits for the chunk sorts it
+// abandoned before the input is unmapped, as they still compare mapped records.
+func TestSortMmapCancel(t *testing.T) {
+	const recordSize = 8
+	path := writeRecordFile(t, 200000, recordSize)
+	outPath := filepath.Join(t.TempDir(), "sorted.dat")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls, slow atomic.Int64
+	var returned atomic.Bool
+	compare := func(a, b []byte) int {
+		if returned.Load() {
+			t.Error("compare called after SortMmap returned")
+		}
+		if calls.Add(1) == 1000 {
+			cancel()
+		}
+		if ctx.Err() != nil && slow.Add(1) < 200 {
+			// keep the abandoned chunk sort running past the abort
+			time.Sleep(10 * time.Microsecond)
+		}
+		return bytes.Compare(a, b)
+	}
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100000
+	err := extsort.SortMmap(ctx, path, outPath, recordSize, compare, config)
+	returned.Store(true)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
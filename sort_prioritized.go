@@ -0,0 +1,150 @@
+package extsort
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// PrioritizedInput is one of the input channels of Prioritized, with the priority
+// that decides the order of its records relative to equal records of other inputs.
+type PrioritizedInput[E any] struct {
+	Input    <-chan E
+	Priority int
+}
+
+// prioritizedRecord pairs a record with the priority of the input it came from.
+type prioritizedRecord[E any] struct {
+	priority int
+	rec      E
+}
+
+// errPrioritizedFrame is returned when a serialized prioritized record is truncated or corrupt.
+var errPrioritizedFrame = errors.New("invalid prioritized record frame")
+
+// PrioritizedSorter provides external sorting of the records of several input
+// channels together, breaking ties between equal records by the priority of the
+// input each came from.
+type PrioritizedSorter[E any] struct {
+	sorter *GenericSorter[prioritizedRecord[E]]
+	inputs []PrioritizedInput[E]
+	tagged chan prioritizedRecord[E]
+	sorted <-chan prioritizedRecord[E]
+	output chan E
+}
+
+// Prioritized performs external sorting on the records of all inputs together.
+// Records that compare equal under compareFunc are ordered by the priority of
+// their input, highest first, so the first record of every run of equal records
+// comes from the input with the highest priority. Combined with UniqKeyChan this
+// gives last-write-wins merges, where the newest source is given the highest
+// priority. Equal records from inputs of the same priority are in no particular
+// order unless Config.Stable is set, in which case they keep the order in which
+// they were received.
+// Returns the sorter instance, output channel with sorted records, and error channel.
+func Prioritized[E any](inputs []PrioritizedInput[E], fromBytes FromBytesGeneric[E], toBytes ToBytesGeneric[E], compareFunc CompareGeneric[E], config *Config) (*PrioritizedSorter[E], <-chan E, <-chan error) {
+	config = mergeConfig(config)
+	s := &PrioritizedSorter[E]{
+		inputs: inputs,
+		tagged: make(chan prioritizedRecord[E], config.ChanBuffSize),
+		output: make(chan E, config.SortedChanBuffSize),
+	}
+	var errChan <-chan error
+	s.sorter, s.sorted, errChan = Generic(s.tagged, makeFromBytesPrioritized(fromBytes), makeToBytesPrioritized(toBytes), makeComparePrioritized(compareFunc), config)
+	if s.sorter == nil {
+		close(s.output)
+		return nil, s.output, errChan
+	}
+	return s, s.output, errChan
+}
+
+// makeToBytesPrioritized serializes a prioritized record as a varint priority,
+// followed by the record serialized with toBytes.
+func makeToBytesPrioritized[E any](toBytes ToBytesGeneric[E]) ToBytesGeneric[prioritizedRecord[E]] {
+	return func(p prioritizedRecord[E]) ([]byte, error) {
+		raw, err := toBytes(p.rec)
+		if err != nil {
+			return nil, err
+		}
+		b := binary.AppendVarint(make([]byte, 0, binary.MaxVarintLen64+len(raw)), int64(p.priority))
+		return append(b, raw...), nil
+	}
+}
+
+// makeFromBytesPrioritized deserializes a prioritized record written by makeToBytesPrioritized.
+func makeFromBytesPrioritized[E any](fromBytes FromBytesGeneric[E]) FromBytesGeneric[prioritizedRecord[E]] {
+	return func(d []byte) (prioritizedRecord[E], error) {
+		priority, n := binary.Varint(d)
+		if n <= 0 {
+			return prioritizedRecord[E]{}, errPrioritizedFrame
+		}
+		rec, err := fromBytes(d[n:])
+		if err != nil {
+			return prioritizedRecord[E]{}, err
+		}
+		return prioritizedRecord[E]{priority: int(priority), rec: rec}, nil
+	}
+}
+
+// makeComparePrioritized orders prioritized records with compareFunc, then by
+// descending priority.
+func makeComparePrioritized[E any](compareFunc CompareGeneric[E]) CompareGeneric[prioritizedRecord[E]] {
+	return func(a, b prioritizedRecord[E]) int {
+		if c := compareFunc(a.rec, b.rec); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.priority, a.priority)
+	}
+}
+
+// Sort sorts the records of all inputs, with the same semantics as GenericSorter.Sort.
+// Inputs are read and results are unwrapped by goroutines that stop when ctx is done.
+func (s *PrioritizedSorter[E]) Sort(ctx context.Context) {
+	// the sorter has stopped reading input once Sort returns
+	readCtx, cancel := context.WithCancel(ctx)
+	go s.readInputs(readCtx)
+	s.sorter.Sort(ctx)
+	cancel()
+	go s.unwrap(ctx)
+}
+
+// readInputs tags the records of every input with its priority and passes them to
+// the sorter, reading all inputs concurrently.
+func (s *PrioritizedSorter[E]) readInputs(ctx context.Context) {
+	defer close(s.tagged)
+	var wg sync.WaitGroup
+	for _, in := range s.inputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rec := range in.Input {
+				select {
+				case s.tagged <- prioritizedRecord[E]{priority: in.Priority, rec: rec}:
+				case <-ctx.Done():
+					if !s.sorter.config.NoDrainOnAbort {
+						drainInput(in.Input)
+					}
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// unwrap delivers the records from the sorter output without their priorities.
+func (s *PrioritizedSorter[E]) unwrap(ctx context.Context) {
+	defer close(s.output)
+	for p := range s.sorted {
+		select {
+		case s.output <- p.rec:
+		case <-ctx.Done():
+			// drain so the sorter can shut down
+			for range s.sorted {
+			}
+			return
+		}
+	}
+}
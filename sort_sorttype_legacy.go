@@ -1,5 +1,7 @@
 package extsort
 
+import "context"
+
 // SortType defines the interface required by the extsort library to be able to sort the items
 //
 // Deprecated: Use Generic() with custom types instead for new code. This interface is maintained for backward compatibility.
@@ -17,6 +19,14 @@ type FromBytes func([]byte) SortType
 // Deprecated: Use CompareGeneric[T] instead for new code. This type is maintained for backward compatibility.
 type CompareLessFunc func(a, b SortType) bool
 
+// Comparator orders SortType items using state it carries, such as a lookup table
+// or a database handle, rather than a bare function. With Generic(), a method value
+// such as table.Compare serves the same purpose as the CompareGeneric[T].
+type Comparator interface {
+	// Less reports whether a should be ordered before b.
+	Less(a, b SortType) bool
+}
+
 // SortTypeSorter provides external sorting for types implementing the SortType interface,
 // maintaining backward compatibility with the legacy interface-based API.
 // It embeds GenericSorter[SortType] and adapts the interface methods to the generic implementation.
@@ -24,6 +34,7 @@ type CompareLessFunc func(a, b SortType) bool
 // Deprecated: Use GenericSorter[T] instead for new code. This type is maintained for backward compatibility.
 type SortTypeSorter struct {
 	GenericSorter[SortType]
+	lessFunc CompareLessFunc
 }
 
 // sortTypeToBytes converts a SortType to bytes by calling its ToBytes method.
@@ -56,6 +67,10 @@ func makeSortTypeFromBytes(fromBytes FromBytes) func([]byte) (SortType, error) {
 	}
 }
 
+// makeCompareSortType creates a generic-compatible compare function from a legacy CompareLessFunc.
+// It calls lessFunc once per comparison, so records that are equal compare as greater;
+// this is enough for sorting, merging and key ranges, which only ask whether one record
+// is less than another.
 func makeCompareSortType(lessFunc CompareLessFunc) func(a, b SortType) int {
 	return func(a, b SortType) int {
 		if lessFunc(a, b) {
@@ -65,6 +80,31 @@ func makeCompareSortType(lessFunc CompareLessFunc) func(a, b SortType) int {
 	}
 }
 
+// makeEqualCompareSortType is like makeCompareSortType, but records neither of which is
+// less than the other compare as equal, as they do with a native compare function.
+// This costs a second call to lessFunc for every pair that is not in order, so it is
+// only used where equality matters.
+func makeEqualCompareSortType(lessFunc CompareLessFunc) func(a, b SortType) int {
+	return func(a, b SortType) int {
+		if lessFunc(a, b) {
+			return -1
+		}
+		if lessFunc(b, a) {
+			return 1
+		}
+		return 0
+	}
+}
+
+// SortGroups is like GenericSorter.SortGroups. Records neither of which is less
+// than the other under the less function are grouped together, which costs a
+// second call to it for each record that is not less than the next one.
+func (s *SortTypeSorter) SortGroups(ctx context.Context) (<-chan []SortType, <-chan error) {
+	groups := GroupChan(s.mergeChunkChan, makeEqualCompareSortType(s.lessFunc))
+	s.Sort(ctx)
+	return groups, s.mergeErrChan
+}
+
 // New performs external sorting on a channel of SortType items using the legacy interface-based API.
 // It takes a FromBytes function for deserialization and a CompareLessFunc for comparison.
 // Returns the sorter instance, output channel with sorted items, and error channel.
@@ -80,7 +120,7 @@ func New(input <-chan SortType, fromBytes FromBytes, lessFunc CompareLessFunc, c
 	if genericSorter == nil {
 		return nil, output, errChan
 	}
-	s := &SortTypeSorter{GenericSorter: *genericSorter}
+	s := &SortTypeSorter{GenericSorter: *genericSorter, lessFunc: lessFunc}
 	return s, output, errChan
 }
 
@@ -99,6 +139,21 @@ func NewMock(input <-chan SortType, fromBytes FromBytes, lessFunc CompareLessFun
 	if genericSorter == nil {
 		return nil, output, errChan
 	}
-	s := &SortTypeSorter{GenericSorter: *genericSorter}
+	s := &SortTypeSorter{GenericSorter: *genericSorter, lessFunc: lessFunc}
 	return s, output, errChan
 }
+
+// NewWithComparator is like New but orders items using a Comparator instead of a
+// CompareLessFunc, so the comparison can carry its own dependencies. A nil comparator
+// is reported as a ConfigError on the error channel, with a nil sorter.
+func NewWithComparator(input <-chan SortType, fromBytes FromBytes, comparator Comparator, config *Config) (*SortTypeSorter, <-chan SortType, <-chan error) {
+	if comparator == nil {
+		output := make(chan SortType)
+		close(output)
+		errChan := make(chan error, 1)
+		errChan <- &ConfigError{Field: "comparator", Value: nil, Reason: "must not be nil"}
+		close(errChan)
+		return nil, output, errChan
+	}
+	return New(input, fromBytes, comparator.Less, config)
+}
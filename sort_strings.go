@@ -25,6 +25,8 @@ func toBytesString(s string) ([]byte, error) {
 
 // Strings performs external sorting on a channel of strings using lexicographic ordering.
 // Returns the sorter instance, output channel with sorted strings, and error channel.
+// Strings are length-prefixed when written to temporary storage, so they may contain
+// any bytes, including newlines and nulls.
 // This function provides backward compatibility with the legacy string-specific API.
 func Strings(input <-chan string, config *Config) (*StringSorter, <-chan string, <-chan error) {
 	genericSorter, output, errChan := Generic(input, fromBytesString, toBytesString, cmp.Compare, config)
@@ -35,6 +37,18 @@ func Strings(input <-chan string, config *Config) (*StringSorter, <-chan string,
 	return s, output, errChan
 }
 
+// StringsFunc performs external sorting on a channel of strings ordered by compareFunc,
+// such as VersionCompare, instead of lexicographically.
+// Returns the sorter instance, output channel with sorted strings, and error channel.
+func StringsFunc(input <-chan string, compareFunc CompareGeneric[string], config *Config) (*StringSorter, <-chan string, <-chan error) {
+	genericSorter, output, errChan := Generic(input, fromBytesString, toBytesString, compareFunc, config)
+	if genericSorter == nil {
+		return nil, output, errChan
+	}
+	s := &StringSorter{GenericSorter: *genericSorter}
+	return s, output, errChan
+}
+
 // StringsMock performs external sorting on strings with a mock implementation that limits
 // the number of strings to sort. Useful for testing with a controlled dataset size.
 // The parameter n specifies the maximum number of strings to process.
@@ -0,0 +1,65 @@
+package extsort
+
+// Sorted is a cursor over the results of a sort, combining the output and error
+// channels returned by a sorter into a single iterator:
+//
+//	sorted := extsort.NewSorted(outChan, errChan)
+//	for sorted.Next() {
+//		use(sorted.Value())
+//	}
+//	if err := sorted.Err(); err != nil {
+//		// handle error
+//	}
+//
+// A Sorted must only be used from one goroutine at a time, and the channels must
+// not be read by anything else once wrapped.
+type Sorted[T any] struct {
+	output  <-chan T
+	errChan <-chan error
+	value   T
+	err     error
+}
+
+// NewSorted returns a cursor over the output and error channels returned by a sorter.
+// It works with every sorter in the package, for example Generic, Strings, or KeyValues.
+func NewSorted[T any](output <-chan T, errChan <-chan error) *Sorted[T] {
+	return &Sorted[T]{output: output, errChan: errChan}
+}
+
+// Next advances the cursor to the next record, which is then available from Value.
+// It returns false when all records have been read or an error has occurred;
+// Err reports which. An error is detected as soon as it is delivered, even if
+// records are still buffered in the output channel.
+func (s *Sorted[T]) Next() bool {
+	for s.err == nil && (s.output != nil || s.errChan != nil) {
+		select {
+		case rec, ok := <-s.output:
+			if !ok {
+				s.output = nil
+				continue
+			}
+			s.value = rec
+			return true
+		case err, ok := <-s.errChan:
+			if !ok {
+				s.errChan = nil
+				continue
+			}
+			s.err = err
+		}
+	}
+	var zero T
+	s.value = zero
+	return false
+}
+
+// Value returns the record the cursor is positioned at by the last call to Next.
+func (s *Sorted[T]) Value() T {
+	return s.value
+}
+
+// Err returns the first error received from the sorter, if any.
+// It should be checked once Next returns false.
+func (s *Sorted[T]) Err() error {
+	return s.err
+}
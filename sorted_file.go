@@ -0,0 +1,725 @@
+package extsort
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+
+	"github.com/lanrat/extsort/tempfile"
+	"golang.org/x/sync/errgroup"
+)
+
+// sortedFileMagic identifies files written by SortToFile.
+const sortedFileMagic = "extsort\x00"
+
+// sortedFileVersion is the current version of the sorted file format.
+// Files are laid out as:
+//
+//	header:  the magic string and a big-endian uint32 version
+//	records: every record framed as a uvarint length followed by the serialized bytes
+//	index:   the big-endian uint64 file offset of every record, in order
+//	trailer: the big-endian uint64 offset of the index and uint64 record count
+//
+// The index and trailer cost 8 bytes per record plus 16 bytes per file, and allow
+// the records to be read backwards or located by position without a full scan.
+const sortedFileVersion uint32 = 1
+
+const (
+	// sortedFileHeaderSize is the size in bytes of the sorted file header.
+	sortedFileHeaderSize = len(sortedFileMagic) + 4
+	// sortedFileTrailerSize is the size in bytes of the sorted file trailer.
+	sortedFileTrailerSize = 16
+	// sortedFileIndexBlock is the number of index entries read at once.
+	sortedFileIndexBlock = 1024
+)
+
+// SortToFile sorts all records from input and writes them to a single sorted file
+// at path, which can later be streamed back any number of times with OpenSortedFile.
+// This allows the result of a sort to be cached and reused across process runs.
+// The file starts with a header containing a format version so that incompatible
+// files are rejected when opened, and ends with an index of record offsets that
+// costs 8 bytes per record. An existing file at path is truncated, or with
+// Config.AtomicOutput, replaced only once the new file is complete. With
+// Config.EmitBloom, a bloom filter of the record keys is also written next to it.
+func SortToFile[E any](ctx context.Context, input <-chan E, fromBytes FromBytesGeneric[E], toBytes ToBytesGeneric[E], compareFunc CompareGeneric[E], path string, config *Config) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	config = mergeConfig(config)
+	if p := config.BloomFalsePositiveRate; !(p >= 0 && p < 1) {
+		return &ConfigError{Field: "BloomFalsePositiveRate", Value: p, Reason: "must be in [0, 1)"}
+	}
+	f, err := createOutputFile(path, config.AtomicOutput)
+	if err != nil {
+		return NewDiskError(err, "create sorted file", path)
+	}
+	defer func() { _ = f.Close() }()
+	if config.AtomicOutput {
+		// removing fails harmlessly once the file has been renamed into place
+		defer func() { _ = os.Remove(f.Name()) }()
+	}
+
+	// record offsets are staged in a temporary file so memory use stays bounded
+	index, err := tempfile.New(config.TempFilesDir, true, config.tempFileOptions(ctx)...)
+	if err != nil {
+		return NewResourceError(err, "temp file", "SortToFile")
+	}
+
+	// created last, so that nothing can fail between creating the sorter and
+	// calling Sort, which releases its temporary file
+	sorter, output, errChan := Generic(input, fromBytes, toBytes, compareFunc, config)
+	if sorter == nil {
+		_ = index.Close()
+		return <-errChan
+	}
+
+	var bloom *BloomFilter
+	var onRecord func([]byte)
+	if config.EmitBloom {
+		onRecord = func(raw []byte) {
+			if bloom == nil {
+				// the input has been fully read before the first record is emitted
+				bloom = newBloomFilter(sorter.numRecords, config.bloomFalsePositiveRate())
+			}
+			bloom.add(config.bloomKey(raw))
+		}
+	}
+
+	sorter.Sort(ctx)
+
+	writeErr := writeSortedFile(f, chanValues(output), toBytes, index, onRecord)
+	if writeErr != nil {
+		// stop the merge and drain the output so it can shut down
+		cancel()
+		for range output {
+		}
+	}
+	if err := <-errChan; err != nil && writeErr == nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if err := f.Sync(); err != nil {
+		return NewDiskError(err, "sync sorted file", path)
+	}
+	if err := f.Close(); err != nil {
+		return NewDiskError(err, "close sorted file", path)
+	}
+	if config.EmitBloom {
+		if bloom == nil {
+			bloom = newBloomFilter(0, config.bloomFalsePositiveRate())
+		}
+		// written first, so a sorted file that is in place always has its filter
+		if err := writeBloomFile(path, bloom, config.AtomicOutput); err != nil {
+			return err
+		}
+	}
+	if config.AtomicOutput {
+		return commitOutputFile(f.Name(), path)
+	}
+	return nil
+}
+
+// createOutputFile creates the file an output at path is written to. With atomic, it
+// is created in the same directory under a temporary name, to be renamed into place
+// by commitOutputFile once complete. Either way the file is created with the same
+// permissions as by os.Create.
+func createOutputFile(path string, atomic bool) (*os.File, error) {
+	if !atomic {
+		return os.Create(path)
+	}
+	for {
+		name := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp-"+strconv.FormatUint(rand.Uint64(), 36))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+}
+
+// commitOutputFile renames the complete, closed file at name into place at path.
+// A file already at path keeps its permissions, as it would when truncated by
+// os.Create. The directory is synced afterwards so that the rename survives a crash.
+func commitOutputFile(name, path string) error {
+	if info, err := os.Stat(path); err == nil {
+		if err := os.Chmod(name, info.Mode().Perm()); err != nil {
+			return NewDiskError(err, "chmod output file", name)
+		}
+	}
+	if err := os.Rename(name, path); err != nil {
+		return NewDiskError(err, "rename output file", path)
+	}
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		return NewDiskError(err, "sync output directory", filepath.Dir(path))
+	}
+	return nil
+}
+
+// syncDir flushes the entries of the directory dir to disk. Windows cannot sync
+// directories, and does not need to for a rename to be durable.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// chanValues returns an iterator over the records received from ch.
+func chanValues[E any](ch <-chan E) iter.Seq[E] {
+	return func(yield func(E) bool) {
+		for rec := range ch {
+			if !yield(rec) {
+				return
+			}
+		}
+	}
+}
+
+// writeSortedFile writes the sorted file header, every record from records, and the
+// record offset index to w. The index is buffered in the index temp writer, which is
+// closed before returning. If onRecord is not nil, it is called with every serialized
+// record.
+func writeSortedFile[E any](w io.Writer, records iter.Seq[E], toBytes ToBytesGeneric[E], index tempfile.TempWriter, onRecord func([]byte)) error {
+	bw := bufio.NewWriterSize(w, tempfile.BufferSize)
+
+	var header [sortedFileHeaderSize]byte
+	copy(header[:], sortedFileMagic)
+	binary.BigEndian.PutUint32(header[len(sortedFileMagic):], sortedFileVersion)
+	if _, err := bw.Write(header[:]); err != nil {
+		_ = index.Close()
+		return NewDiskError(err, "write sorted file header", "")
+	}
+
+	offset := int64(sortedFileHeaderSize)
+	var count uint64
+	scratch := make([]byte, binary.MaxVarintLen64)
+	for rec := range records {
+		raw, err := toBytes(rec)
+		if err != nil {
+			_ = index.Close()
+			return NewSerializationError(err, "SortToFile")
+		}
+		if onRecord != nil {
+			onRecord(raw)
+		}
+		binary.BigEndian.PutUint64(scratch, uint64(offset))
+		if _, err := index.Write(scratch[:8]); err != nil {
+			_ = index.Close()
+			return NewDiskError(err, "write index", "")
+		}
+		n := binary.PutUvarint(scratch, uint64(len(raw)))
+		if _, err := bw.Write(scratch[:n]); err != nil {
+			_ = index.Close()
+			return NewDiskError(err, "write size header", "")
+		}
+		if _, err := bw.Write(raw); err != nil {
+			_ = index.Close()
+			return NewDiskError(err, "write data", "")
+		}
+		offset += int64(n + len(raw))
+		count++
+	}
+
+	indexReader, err := index.Save()
+	if err != nil {
+		_ = index.Close()
+		return NewDiskError(err, "save index", "")
+	}
+	defer func() { _ = indexReader.Close() }()
+	if _, err := io.Copy(bw, indexReader.Read(0)); err != nil {
+		return NewDiskError(err, "copy index", "")
+	}
+
+	var trailer [sortedFileTrailerSize]byte
+	binary.BigEndian.PutUint64(trailer[:8], uint64(offset))
+	binary.BigEndian.PutUint64(trailer[8:], count)
+	if _, err := bw.Write(trailer[:]); err != nil {
+		return NewDiskError(err, "write sorted file trailer", "")
+	}
+
+	if err := bw.Flush(); err != nil {
+		return NewDiskError(err, "flush sorted file", "")
+	}
+	return nil
+}
+
+// sortedFile is an open file written by SortToFile whose header and trailer have
+// been validated.
+type sortedFile struct {
+	path        string
+	file        *os.File
+	indexOffset int64 // offset of the record index, which is also the end of the records
+	count       int64 // number of records in the file
+}
+
+// openSortedFile opens the sorted file at path and validates its header and trailer.
+func openSortedFile(path string) (*sortedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, NewDiskError(err, "open sorted file", path)
+	}
+	sf := &sortedFile{path: path, file: f}
+	if err := sf.validate(); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sf, nil
+}
+
+// validate reads and checks the header and trailer of the sorted file.
+func (sf *sortedFile) validate() error {
+	info, err := sf.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	if size < int64(sortedFileHeaderSize+sortedFileTrailerSize) {
+		return fmt.Errorf("%w: file too short", ErrInvalidSortedFile)
+	}
+
+	var header [sortedFileHeaderSize]byte
+	if _, err := sf.file.ReadAt(header[:], 0); err != nil {
+		return err
+	}
+	if string(header[:len(sortedFileMagic)]) != sortedFileMagic {
+		return fmt.Errorf("%w: bad magic", ErrInvalidSortedFile)
+	}
+	if v := binary.BigEndian.Uint32(header[len(sortedFileMagic):]); v != sortedFileVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidSortedFile, v)
+	}
+
+	var trailer [sortedFileTrailerSize]byte
+	if _, err := sf.file.ReadAt(trailer[:], size-sortedFileTrailerSize); err != nil {
+		return err
+	}
+	indexOffset := binary.BigEndian.Uint64(trailer[:8])
+	count := binary.BigEndian.Uint64(trailer[8:])
+	if indexOffset < uint64(sortedFileHeaderSize) || indexOffset > uint64(size) ||
+		count > (uint64(size)-indexOffset)/8 ||
+		indexOffset+count*8+sortedFileTrailerSize != uint64(size) {
+		return fmt.Errorf("%w: corrupt trailer", ErrInvalidSortedFile)
+	}
+	sf.indexOffset = int64(indexOffset)
+	sf.count = int64(count)
+	return nil
+}
+
+// Close closes the underlying file.
+func (sf *sortedFile) Close() error {
+	return sf.file.Close()
+}
+
+// records returns a reader over the framed records of the file in sorted order.
+func (sf *sortedFile) records() *bufio.Reader {
+	section := io.NewSectionReader(sf.file, int64(sortedFileHeaderSize), sf.indexOffset-int64(sortedFileHeaderSize))
+	return bufio.NewReaderSize(section, tempfile.BufferSize)
+}
+
+// reverseRecords returns a reader over the framed records of the file in reverse order.
+func (sf *sortedFile) reverseRecords() *bufio.Reader {
+	return bufio.NewReaderSize(&reverseRecordReader{sf: sf, next: sf.count - 1}, tempfile.BufferSize)
+}
+
+// readIndex reads the offsets of the records in [start, start+len(offsets)) from the index.
+func (sf *sortedFile) readIndex(start int64, offsets []int64) error {
+	buf := make([]byte, 8*len(offsets))
+	if _, err := sf.file.ReadAt(buf, sf.indexOffset+start*8); err != nil {
+		return NewDiskError(err, "read index", sf.path)
+	}
+	for i := range offsets {
+		offsets[i] = int64(binary.BigEndian.Uint64(buf[i*8:]))
+	}
+	return nil
+}
+
+// reverseRecordReader yields the framed bytes of every record in a sorted file,
+// starting with the last record. Record boundaries are located using the index,
+// which is read backwards one block at a time.
+type reverseRecordReader struct {
+	sf         *sortedFile
+	next       int64   // position of the next record to read
+	blockStart int64   // position of the first record in block
+	block      []int64 // cached block of record offsets
+	pending    []byte  // unread bytes of the current record
+}
+
+// Read implements io.Reader.
+func (r *reverseRecordReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		if r.next < 0 {
+			return 0, io.EOF
+		}
+		if err := r.loadRecord(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// loadRecord reads the framed bytes of the record at position r.next into pending.
+func (r *reverseRecordReader) loadRecord() error {
+	if r.block == nil || r.next < r.blockStart {
+		r.blockStart = max(0, r.next-sortedFileIndexBlock+1)
+		r.block = make([]int64, r.next-r.blockStart+1)
+		if err := r.sf.readIndex(r.blockStart, r.block); err != nil {
+			return err
+		}
+	}
+
+	start := r.block[r.next-r.blockStart]
+	end := r.sf.indexOffset
+	if i := r.next + 1 - r.blockStart; i < int64(len(r.block)) {
+		end = r.block[i]
+	} else if r.next+1 < r.sf.count {
+		// the following record is at the start of the previously loaded block
+		var next [1]int64
+		if err := r.sf.readIndex(r.next+1, next[:]); err != nil {
+			return err
+		}
+		end = next[0]
+	}
+	if start < int64(sortedFileHeaderSize) || end < start || end > r.sf.indexOffset {
+		return fmt.Errorf("%s: %w: corrupt index", r.sf.path, ErrInvalidSortedFile)
+	}
+
+	r.pending = make([]byte, end-start)
+	if _, err := r.sf.file.ReadAt(r.pending, start); err != nil {
+		return NewDiskError(err, "read record", r.sf.path)
+	}
+	r.next--
+	return nil
+}
+
+// OpenSortedFile streams the records of a file written by SortToFile in sorted order,
+// or in reverse sorted order if reverse is set. Reading in reverse uses the record
+// index stored at the end of the file, so it does not require re-sorting.
+// The header is validated before any record is read; files that were not written by
+// SortToFile or that use an unsupported format version produce ErrInvalidSortedFile.
+// Errors are delivered on the error channel after the output channel is closed.
+func OpenSortedFile[E any](ctx context.Context, path string, fromBytes FromBytesGeneric[E], reverse bool) (<-chan E, <-chan error) {
+	// a single stream never needs to be compared
+	return MergeFiles(ctx, []string{path}, fromBytes, nil, reverse, nil)
+}
+
+// MergeFiles performs a k-way merge of several files written by SortToFile into a
+// single sorted stream. This supports workflows where shards are sorted separately,
+// possibly on different machines, and merged centrally. Every file must have been
+// sorted with an ordering consistent with compareFunc. Records that compare equal are
+// emitted in the order of their files in paths. If reverse is set, the files are read
+// backwards and the output is exactly the reverse of the forward output.
+//
+// All files are validated before any record is emitted, so a missing or invalid file
+// is reported without producing partial output. At most Config.MaxRunsBeforeMerge
+// files, or defaultMergeFilesFanIn when it is 0, are merged at once: larger sets are
+// first merged in batches into temporary runs, up to Config.NumWorkers batches at a
+// time, and the runs are merged in turn, so the number of open files stays bounded.
+// The temporary runs are created in Config.TempFilesDirs when set, like the chunks
+// of a sort, and in Config.TempFilesDir otherwise.
+// Errors are delivered on the error channel after the output channel is closed.
+func MergeFiles[E any](ctx context.Context, paths []string, fromBytes FromBytesGeneric[E], compareFunc CompareGeneric[E], reverse bool, config *Config) (<-chan E, <-chan error) {
+	config = mergeConfig(config)
+	output := make(chan E, config.SortedChanBuffSize)
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(errChan)
+		defer close(output)
+		if err := mergeSortedFiles(ctx, paths, fromBytes, compareFunc, reverse, config, output); err != nil {
+			errChan <- err
+		}
+	}()
+
+	return output, errChan
+}
+
+// defaultMergeFilesFanIn is the number of files MergeFiles merges at once when
+// Config.MaxRunsBeforeMerge is not set.
+const defaultMergeFilesFanIn = 64
+
+// mergeRun is an input of a MergeFiles pass: either a sorted file, or a run
+// written by an earlier pass, which already holds records in output order.
+type mergeRun struct {
+	path string
+	run  tempfile.TempReader
+}
+
+// open returns a reader for the records of r, and a function releasing it.
+func (r mergeRun) open(reverse bool) (*bufio.Reader, func(), error) {
+	if r.run != nil {
+		return r.run.Read(0), func() {}, nil
+	}
+	sf, err := openSortedFile(r.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	release := func() { _ = sf.Close() }
+	if reverse {
+		return sf.reverseRecords(), release, nil
+	}
+	return sf.records(), release, nil
+}
+
+// rawRecord carries a record along with its serialized bytes, so that an
+// intermediate pass of MergeFiles can write records without a ToBytes function.
+type rawRecord[E any] struct {
+	raw []byte
+	rec E
+}
+
+// mergeSortedFiles validates every sorted file in paths, then merges their records
+// onto output, in several passes when there are more files than the fan-in.
+func mergeSortedFiles[E any](ctx context.Context, paths []string, fromBytes FromBytesGeneric[E], compareFunc CompareGeneric[E], reverse bool, config *Config, output chan<- E) error {
+	fanIn := config.MaxRunsBeforeMerge
+	if fanIn == 0 {
+		fanIn = defaultMergeFilesFanIn
+	}
+	if fanIn < 2 {
+		return &ConfigError{Field: "MaxRunsBeforeMerge", Value: config.MaxRunsBeforeMerge, Reason: "must be 0 or >= 2"}
+	}
+
+	runs := make([]mergeRun, 0, len(paths))
+	for _, path := range paths {
+		sf, err := openSortedFile(path)
+		if err != nil {
+			return err
+		}
+		_ = sf.Close()
+		runs = append(runs, mergeRun{path: path})
+	}
+
+	if reverse {
+		// equal records are taken from the later file first, mirroring the forward order
+		slices.Reverse(runs)
+		if compareFunc != nil {
+			forward := compareFunc
+			compareFunc = func(a, b E) int {
+				return forward(b, a)
+			}
+		}
+	}
+
+	// the runs of the latest pass are released once they have been merged
+	defer func() { closeMergeRuns(runs) }()
+	for len(runs) > fanIn {
+		next, err := mergeFilesPass(ctx, runs, fanIn, fromBytes, compareFunc, reverse, config)
+		closeMergeRuns(runs)
+		runs = next
+		if err != nil {
+			return err
+		}
+	}
+
+	readers := make([]*bufio.Reader, len(runs))
+	for i, r := range runs {
+		reader, release, err := r.open(reverse)
+		if err != nil {
+			return err
+		}
+		defer release()
+		readers[i] = reader
+	}
+	return mergeSorted(readers, fromBytes, compareFunc, config.MergeStrategy, func(rec E) error {
+		select {
+		case output <- rec:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// mergeFilesPass merges consecutive batches of fanIn runs into one temporary run
+// each, running up to Config.NumWorkers batches concurrently. On error, the runs
+// written so far are returned along with it so that they can be released.
+func mergeFilesPass[E any](ctx context.Context, runs []mergeRun, fanIn int, fromBytes FromBytesGeneric[E], compareFunc CompareGeneric[E], reverse bool, config *Config) ([]mergeRun, error) {
+	next := make([]mergeRun, (len(runs)+fanIn-1)/fanIn)
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(max(config.NumWorkers, 1))
+	for i := range next {
+		batch := runs[i*fanIn : min((i+1)*fanIn, len(runs))]
+		group.Go(func() error {
+			run, err := mergeFilesBatch(ctx, batch, fromBytes, compareFunc, reverse, config)
+			next[i] = mergeRun{run: run}
+			return err
+		})
+	}
+	return next, group.Wait()
+}
+
+// mergeFilesBatch merges batch into a new temporary run, framed like a chunk.
+func mergeFilesBatch[E any](ctx context.Context, batch []mergeRun, fromBytes FromBytesGeneric[E], compareFunc CompareGeneric[E], reverse bool, config *Config) (tempfile.TempReader, error) {
+	readers := make([]*bufio.Reader, len(batch))
+	for i, r := range batch {
+		reader, release, err := r.open(reverse)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		readers[i] = reader
+	}
+	// intermediate runs are spread across directories like the chunks of a sort
+	w, err := createTempWriter(ctx, config)
+	if err != nil {
+		return nil, NewResourceError(err, "temp file", "MergeFiles")
+	}
+	fromRaw := func(raw []byte) (rawRecord[E], error) {
+		rec, err := fromBytes(raw)
+		return rawRecord[E]{raw: raw, rec: rec}, err
+	}
+	compareRaw := func(a, b rawRecord[E]) int {
+		return compareFunc(a.rec, b.rec)
+	}
+	scratch := make([]byte, binary.MaxVarintLen64)
+	err = mergeSorted(readers, fromRaw, compareRaw, config.MergeStrategy, func(r rawRecord[E]) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := binary.PutUvarint(scratch, uint64(len(r.raw)))
+		if _, err := w.Write(scratch[:n]); err != nil {
+			return NewDiskError(err, "write size header", "")
+		}
+		if _, err := w.Write(r.raw); err != nil {
+			return NewDiskError(err, "write data", "")
+		}
+		return nil
+	})
+	if err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	run, err := w.Save()
+	if err != nil {
+		_ = w.Close()
+		return nil, NewDiskError(err, "save merged run", "")
+	}
+	return run, nil
+}
+
+// closeMergeRuns releases the temporary runs among runs.
+func closeMergeRuns(runs []mergeRun) {
+	for _, r := range runs {
+		if r.run != nil {
+			_ = r.run.Close()
+		}
+	}
+}
+
+// SortedFile provides random access to the records of a file written by SortToFile.
+// Records are located through the offset index stored at the end of the file, so
+// any record can be read without scanning the records before it, and the file can
+// be binary searched for lookups. A SortedFile is safe for concurrent use.
+type SortedFile[E any] struct {
+	sf        *sortedFile
+	fromBytes FromBytesGeneric[E]
+}
+
+// OpenSortedFileReader opens a file written by SortToFile for random access.
+// The header and trailer are validated; files that were not written by SortToFile
+// or that use an unsupported format version produce ErrInvalidSortedFile.
+// The returned SortedFile must be closed when no longer needed.
+func OpenSortedFileReader[E any](path string, fromBytes FromBytesGeneric[E]) (*SortedFile[E], error) {
+	sf, err := openSortedFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &SortedFile[E]{sf: sf, fromBytes: fromBytes}, nil
+}
+
+// Len returns the number of records in the file.
+func (f *SortedFile[E]) Len() int64 {
+	return f.sf.count
+}
+
+// At returns the record at position i, where 0 is the smallest record.
+// It panics if i is out of range.
+func (f *SortedFile[E]) At(i int64) (E, error) {
+	var zero E
+	if i < 0 || i >= f.sf.count {
+		panic("extsort: sorted file record index out of range")
+	}
+	raw, err := f.sf.readRecord(i)
+	if err != nil {
+		return zero, err
+	}
+	rec, err := f.fromBytes(raw)
+	if err != nil {
+		return zero, NewDeserializationError(err, len(raw), "SortedFile.At")
+	}
+	return rec, nil
+}
+
+// Search uses binary search to find and return the smallest position i in [0, Len())
+// at which pred returns true, assuming that pred is false for some prefix of the
+// records and true for the rest, as with sort.Search. It returns Len() if there is
+// no such position. Only O(log n) records are read from the file.
+func (f *SortedFile[E]) Search(pred func(E) bool) (int64, error) {
+	lo, hi := int64(0), f.sf.count
+	for lo < hi {
+		mid := int64(uint64(lo+hi) >> 1)
+		rec, err := f.At(mid)
+		if err != nil {
+			return 0, err
+		}
+		if pred(rec) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo, nil
+}
+
+// Close closes the underlying file.
+func (f *SortedFile[E]) Close() error {
+	return f.sf.Close()
+}
+
+// readRecord returns the serialized bytes of the record at position i, without its framing.
+func (sf *sortedFile) readRecord(i int64) ([]byte, error) {
+	// the last record ends where the index starts
+	offsets := []int64{0, sf.indexOffset}
+	if i+1 == sf.count {
+		offsets = offsets[:1]
+	}
+	if err := sf.readIndex(i, offsets); err != nil {
+		return nil, err
+	}
+	offsets = offsets[:2]
+	start, end := offsets[0], offsets[1]
+	if start < int64(sortedFileHeaderSize) || end < start || end > sf.indexOffset {
+		return nil, fmt.Errorf("%s: %w: corrupt index", sf.path, ErrInvalidSortedFile)
+	}
+
+	framed := make([]byte, end-start)
+	if _, err := sf.file.ReadAt(framed, start); err != nil {
+		return nil, NewDiskError(err, "read record", sf.path)
+	}
+	size, n := binary.Uvarint(framed)
+	if n <= 0 || size != uint64(len(framed)-n) {
+		return nil, fmt.Errorf("%s: %w: corrupt record", sf.path, ErrInvalidSortedFile)
+	}
+	return framed[n:], nil
+}
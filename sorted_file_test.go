@@ -0,0 +1,629 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// sortIntsToFile sorts data into a sorted file in a temporary directory and returns its path.
+func sortIntsToFile(t testing.TB, data []int, config *extsort.Config) string {
+	t.Helper()
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	path := filepath.Join(t.TempDir(), "sorted.dat")
+	err := extsort.SortToFile(context.Background(), inputChan, intFromBytes, intToBytes, cmp.Compare[int], path, config)
+	if err != nil {
+		t.Fatalf("SortToFile error: %v", err)
+	}
+	return path
+}
+
+// readSortedInts streams every record of a sorted file into a slice.
+func readSortedInts(t *testing.T, path string) []int {
+	t.Helper()
+	outChan, errChan := extsort.OpenSortedFile(context.Background(), path, intFromBytes, false)
+	var result []int
+	for v := range outChan {
+		result = append(result, v)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("OpenSortedFile error: %v", err)
+	}
+	return result
+}
+
+func TestSortToFileRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, 10, 5000} {
+		data := generateRandomInts(size)
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 500
+
+		path := sortIntsToFile(t, data, config)
+
+		// the file can be streamed more than once
+		for pass := 0; pass < 2; pass++ {
+			result := readSortedInts(t, path)
+			if len(result) != size {
+				t.Fatalf("size %d: expected %d records, got %d", size, size, len(result))
+			}
+			for i := 1; i < len(result); i++ {
+				if result[i-1] > result[i] {
+					t.Fatalf("size %d: output not sorted at %d", size, i)
+				}
+			}
+		}
+	}
+}
+
+func TestOpenSortedFileInvalid(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string][]byte{
+		"empty":   {},
+		"magic":   []byte("not a sorted file at all"),
+		"version": append([]byte("extsort\x00"), 0, 0, 0, 99),
+	}
+	for name, contents := range cases {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, contents, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		outChan, errChan := extsort.OpenSortedFile(context.Background(), path, intFromBytes, false)
+		for range outChan {
+			t.Fatalf("%s: expected no records", name)
+		}
+		if err := <-errChan; !errors.Is(err, extsort.ErrInvalidSortedFile) {
+			t.Errorf("%s: expected ErrInvalidSortedFile, got %v", name, err)
+		}
+	}
+}
+
+func TestOpenSortedFileMissing(t *testing.T) {
+	outChan, errChan := extsort.OpenSortedFile(context.Background(), filepath.Join(t.TempDir(), "missing"), intFromBytes, false)
+	for range outChan {
+	}
+	if err := <-errChan; !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestMergeFiles(t *testing.T) {
+	const shards = 5
+	var paths []string
+	total := 0
+	for i := 0; i < shards; i++ {
+		data := generateRandomInts(1000 * (i + 1))
+		total += len(data)
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 300
+		paths = append(paths, sortIntsToFile(t, data, config))
+	}
+
+	outChan, errChan := extsort.MergeFiles(context.Background(), paths, intFromBytes, cmp.Compare[int], false, nil)
+	count := 0
+	prev := -1
+	for v := range outChan {
+		if v < prev {
+			t.Fatalf("output not sorted: %d before %d", prev, v)
+		}
+		prev = v
+		count++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("MergeFiles error: %v", err)
+	}
+	if count != total {
+		t.Fatalf("expected %d records, got %d", total, count)
+	}
+}
+
+func TestMergeFilesInvalid(t *testing.T) {
+	valid := sortIntsToFile(t, []int{3, 1, 2}, nil)
+	invalid := filepath.Join(t.TempDir(), "invalid")
+	if err := os.WriteFile(invalid, []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	outChan, errChan := extsort.MergeFiles(context.Background(), []string{valid, invalid}, intFromBytes, cmp.Compare[int], false, nil)
+	for range outChan {
+		t.Fatal("expected no records when a file is invalid")
+	}
+	if err := <-errChan; !errors.Is(err, extsort.ErrInvalidSortedFile) {
+		t.Fatalf("expected ErrInvalidSortedFile, got %v", err)
+	}
+}
+
+func TestOpenSortedFileReverse(t *testing.T) {
+	// sizes span several index blocks
+	for _, size := range []int{0, 1, 10, 5000} {
+		data := generateRandomInts(size)
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 500
+		path := sortIntsToFile(t, data, config)
+
+		forward := readSortedInts(t, path)
+
+		outChan, errChan := extsort.OpenSortedFile(context.Background(), path, intFromBytes, true)
+		var reversed []int
+		for v := range outChan {
+			reversed = append(reversed, v)
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("size %d: OpenSortedFile error: %v", size, err)
+		}
+		if len(reversed) != len(forward) {
+			t.Fatalf("size %d: expected %d records, got %d", size, len(forward), len(reversed))
+		}
+		for i, v := range reversed {
+			if want := forward[len(forward)-1-i]; v != want {
+				t.Fatalf("size %d: expected %d at position %d, got %d", size, want, i, v)
+			}
+		}
+	}
+}
+
+func TestMergeFilesReverse(t *testing.T) {
+	const shards = 3
+	var paths []string
+	total := 0
+	for i := 0; i < shards; i++ {
+		data := generateRandomInts(1500 * (i + 1))
+		total += len(data)
+		paths = append(paths, sortIntsToFile(t, data, nil))
+	}
+
+	outChan, errChan := extsort.MergeFiles(context.Background(), paths, intFromBytes, cmp.Compare[int], true, nil)
+	count := 0
+	prev := int(^uint(0) >> 1)
+	for v := range outChan {
+		if v > prev {
+			t.Fatalf("output not in reverse order: %d before %d", prev, v)
+		}
+		prev = v
+		count++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("MergeFiles error: %v", err)
+	}
+	if count != total {
+		t.Fatalf("expected %d records, got %d", total, count)
+	}
+}
+
+func TestOpenSortedFileTruncated(t *testing.T) {
+	path := sortIntsToFile(t, generateRandomInts(100), nil)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+
+	outChan, errChan := extsort.OpenSortedFile(context.Background(), path, intFromBytes, true)
+	for range outChan {
+		t.Fatal("expected no records from a truncated file")
+	}
+	if err := <-errChan; !errors.Is(err, extsort.ErrInvalidSortedFile) {
+		t.Fatalf("expected ErrInvalidSortedFile, got %v", err)
+	}
+}
+
+func TestMergeFilesEqualOrder(t *testing.T) {
+	// records are compared by their last digit; each file holds each digit once
+	compare := func(a, b int) int { return cmp.Compare(a%10, b%10) }
+	var paths []string
+	for f := 0; f < 3; f++ {
+		inputChan := make(chan int, 10)
+		for k := 0; k < 10; k++ {
+			inputChan <- f*10 + k
+		}
+		close(inputChan)
+		path := filepath.Join(t.TempDir(), "sorted.dat")
+		if err := extsort.SortToFile(context.Background(), inputChan, intFromBytes, intToBytes, compare, path, nil); err != nil {
+			t.Fatalf("SortToFile error: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	var forward []int
+	for _, reverse := range []bool{false, true} {
+		outChan, errChan := extsort.MergeFiles(context.Background(), paths, intFromBytes, compare, reverse, nil)
+		var result []int
+		for v := range outChan {
+			result = append(result, v)
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("MergeFiles error: %v", err)
+		}
+		if !reverse {
+			for i, v := range result {
+				if want := (i%3)*10 + i/3; v != want {
+					t.Fatalf("expected %d at position %d, got %d", want, i, v)
+				}
+			}
+			forward = result
+			continue
+		}
+		for i, v := range result {
+			if want := forward[len(forward)-1-i]; v != want {
+				t.Fatalf("reverse: expected %d at position %d, got %d", want, i, v)
+			}
+		}
+	}
+}
+
+// TestMergeFilesMultiPass verifies that merging more files than the fan-in, in
+// several passes, keeps the order of equal records both forwards and in reverse.
+func TestMergeFilesMultiPass(t *testing.T) {
+	const files = 20
+	compare := func(a, b int) int { return cmp.Compare(a%10, b%10) }
+	var paths []string
+	for f := 0; f < files; f++ {
+		inputChan := make(chan int, 10)
+		for k := 0; k < 10; k++ {
+			inputChan <- f*10 + k
+		}
+		close(inputChan)
+		path := filepath.Join(t.TempDir(), "sorted.dat")
+		if err := extsort.SortToFile(context.Background(), inputChan, intFromBytes, intToBytes, compare, path, nil); err != nil {
+			t.Fatalf("SortToFile error: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	config := extsort.DefaultConfig()
+	config.MaxRunsBeforeMerge = 3 // 20 files, then 7 runs, then 3 runs
+	config.NumWorkers = 2
+	for _, reverse := range []bool{false, true} {
+		outChan, errChan := extsort.MergeFiles(context.Background(), paths, intFromBytes, compare, reverse, config)
+		var result []int
+		for v := range outChan {
+			result = append(result, v)
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("reverse %v: MergeFiles error: %v", reverse, err)
+		}
+		if len(result) != files*10 {
+			t.Fatalf("reverse %v: expected %d records, got %d", reverse, files*10, len(result))
+		}
+		for i, v := range result {
+			pos := i
+			if reverse {
+				pos = len(result) - 1 - i
+			}
+			if want := (pos%files)*10 + pos/files; v != want {
+				t.Fatalf("reverse %v: expected %d at position %d, got %d", reverse, want, i, v)
+			}
+		}
+	}
+
+	// intermediate runs are spread over TempFilesDirs, with a file in each directory
+	var created atomic.Int64
+	config.TempFileID = func() string {
+		return fmt.Sprintf("merge-%d", created.Add(1))
+	}
+	var filesPerDirs []int64
+	for _, dirs := range [][]string{nil, {t.TempDir(), t.TempDir()}} {
+		config.TempFilesDir = t.TempDir()
+		config.TempFilesDirs = dirs
+		created.Store(0)
+		outChan, errChan := extsort.MergeFiles(context.Background(), paths, intFromBytes, compare, false, config)
+		count := 0
+		for range outChan {
+			count++
+		}
+		if err := <-errChan; err != nil || count != files*10 {
+			t.Fatalf("TempFilesDirs %v: expected %d records, got %d, %v", dirs, files*10, count, err)
+		}
+		filesPerDirs = append(filesPerDirs, created.Load())
+	}
+	if filesPerDirs[0] == 0 || filesPerDirs[1] != 2*filesPerDirs[0] {
+		t.Fatalf("expected twice as many temporary files with two TempFilesDirs, got %v", filesPerDirs)
+	}
+	config.TempFileID = nil
+	config.TempFilesDirs = nil
+
+	config.MaxRunsBeforeMerge = 1
+	outChan, errChan := extsort.MergeFiles(context.Background(), paths, intFromBytes, compare, false, config)
+	for range outChan {
+	}
+	var configErr *extsort.ConfigError
+	if err := <-errChan; !errors.As(err, &configErr) {
+		t.Fatalf("expected ConfigError for a fan-in of 1, got %v", err)
+	}
+}
+
+func TestSortedFileRandomAccess(t *testing.T) {
+	data := generateRandomInts(3000)
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 500
+	path := sortIntsToFile(t, data, config)
+	sorted := readSortedInts(t, path)
+
+	f, err := extsort.OpenSortedFileReader(path, intFromBytes)
+	if err != nil {
+		t.Fatalf("OpenSortedFileReader error: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if f.Len() != int64(len(sorted)) {
+		t.Fatalf("expected %d records, got %d", len(sorted), f.Len())
+	}
+	for _, i := range []int{0, 1, 1234, len(sorted) - 1} {
+		v, err := f.At(int64(i))
+		if err != nil {
+			t.Fatalf("At(%d) error: %v", i, err)
+		}
+		if v != sorted[i] {
+			t.Fatalf("At(%d): expected %d, got %d", i, sorted[i], v)
+		}
+	}
+
+	// search for present values and for a value larger than all records
+	for _, target := range []int{sorted[0], sorted[1500], sorted[len(sorted)-1], sorted[len(sorted)-1] + 1} {
+		i, err := f.Search(func(v int) bool { return v >= target })
+		if err != nil {
+			t.Fatalf("Search error: %v", err)
+		}
+		want := len(sorted)
+		for j, v := range sorted {
+			if v >= target {
+				want = j
+				break
+			}
+		}
+		if i != int64(want) {
+			t.Fatalf("Search(%d): expected %d, got %d", target, want, i)
+		}
+	}
+}
+
+func TestSortedFileEmpty(t *testing.T) {
+	path := sortIntsToFile(t, nil, nil)
+	f, err := extsort.OpenSortedFileReader(path, intFromBytes)
+	if err != nil {
+		t.Fatalf("OpenSortedFileReader error: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	if f.Len() != 0 {
+		t.Fatalf("expected 0 records, got %d", f.Len())
+	}
+	i, err := f.Search(func(int) bool { return true })
+	if err != nil || i != 0 {
+		t.Fatalf("expected Search to return 0, got %d, %v", i, err)
+	}
+}
+
+func TestSortToFileAtomicOutput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sorted.dat")
+	if err := os.WriteFile(path, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := extsort.DefaultConfig()
+	config.AtomicOutput = true
+
+	// fail part way through writing the output
+	errWrite := errors.New("write failed")
+	failingToBytes := func(i int) ([]byte, error) {
+		if i == 50 {
+			return nil, errWrite
+		}
+		return intToBytes(i)
+	}
+	inputChan := make(chan int, 100)
+	for i := 100; i > 0; i-- {
+		inputChan <- i
+	}
+	close(inputChan)
+	err := extsort.SortToFile(context.Background(), inputChan, intFromBytes, failingToBytes, cmp.Compare[int], path, config)
+	if !errors.Is(err, errWrite) {
+		t.Fatalf("expected %v, got %v", errWrite, err)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "previous" {
+		t.Fatalf("expected previous file to be left intact, got %q, %v", b, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the output file in %s, got %d entries", dir, len(entries))
+	}
+
+	// a successful run replaces the previous file
+	inputChan = make(chan int, 100)
+	for i := 100; i > 0; i-- {
+		inputChan <- i
+	}
+	close(inputChan)
+	err = extsort.SortToFile(context.Background(), inputChan, intFromBytes, intToBytes, cmp.Compare[int], path, config)
+	if err != nil {
+		t.Fatalf("SortToFile error: %v", err)
+	}
+	result := readSortedInts(t, path)
+	if len(result) != 100 || result[0] != 1 || result[99] != 100 {
+		t.Fatalf("unexpected sorted file contents: %v", result)
+	}
+	if entries, _ = os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expected only the output file in %s, got %d entries", dir, len(entries))
+	}
+}
+
+// sortIntsToPath sorts 1..n into a sorted file at path with the given config.
+func sortIntsToPath(t *testing.T, path string, n int, config *extsort.Config) error {
+	t.Helper()
+	inputChan := make(chan int, n)
+	for i := n; i > 0; i-- {
+		inputChan <- i
+	}
+	close(inputChan)
+	return extsort.SortToFile(context.Background(), inputChan, intFromBytes, intToBytes, cmp.Compare[int], path, config)
+}
+
+// TestSortToFileAtomicOutputMode verifies that AtomicOutput gives the output the
+// same permissions as writing it in place.
+func TestSortToFileAtomicOutputMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not applicable on windows")
+	}
+	dir := t.TempDir()
+	atomicConfig := extsort.DefaultConfig()
+	atomicConfig.AtomicOutput = true
+
+	// a new file gets the mode os.Create gives it
+	inPlace := filepath.Join(dir, "in-place.dat")
+	if err := sortIntsToPath(t, inPlace, 10, nil); err != nil {
+		t.Fatal(err)
+	}
+	atomic := filepath.Join(dir, "atomic.dat")
+	if err := sortIntsToPath(t, atomic, 10, atomicConfig); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.Stat(inPlace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.Stat(atomic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Mode() != want.Mode() {
+		t.Errorf("expected mode %v for a new file, got %v", want.Mode(), got.Mode())
+	}
+
+	// a replaced file keeps its mode
+	if err := os.Chmod(atomic, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := sortIntsToPath(t, atomic, 10, atomicConfig); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = os.Stat(atomic); err != nil {
+		t.Fatal(err)
+	}
+	if got.Mode().Perm() != 0o640 {
+		t.Errorf("expected mode 0640 to be kept, got %v", got.Mode())
+	}
+}
+
+// TestSortToFileCreateFailure verifies that the temporary file of the sort is
+// released when the output cannot be created.
+func TestSortToFileCreateFailure(t *testing.T) {
+	// temporary files may be unlinked as soon as they are opened, so count descriptors
+	fds := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Skip("open file descriptors cannot be counted on this platform")
+		}
+		return len(entries)
+	}
+	config := extsort.DefaultConfig()
+	config.TempFilesDir = t.TempDir()
+	path := filepath.Join(t.TempDir(), "missing", "sorted.dat")
+	for _, atomic := range []bool{false, true} {
+		config.AtomicOutput = atomic
+		before := fds()
+		if err := sortIntsToPath(t, path, 10, config); err == nil {
+			t.Fatalf("AtomicOutput %v: expected an error creating %s", atomic, path)
+		}
+		if after := fds(); after != before {
+			t.Fatalf("AtomicOutput %v: %d file descriptors open before, %d after", atomic, before, after)
+		}
+	}
+}
+
+func TestFileCursor(t *testing.T) {
+	// even values from 0 to 198, with 100 stored three times
+	var data []int
+	for i := 0; i < 100; i++ {
+		data = append(data, i*2)
+	}
+	data = append(data, 100, 100)
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 30
+	path := sortIntsToFile(t, data, config)
+
+	c, err := extsort.NewFileCursor(path, intFromBytes, cmp.Compare[int])
+	if err != nil {
+		t.Fatalf("NewFileCursor error: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	// scan reads up to count records from the cursor position
+	scan := func(count int) []int {
+		var result []int
+		for len(result) < count && c.Next() {
+			result = append(result, c.Value())
+		}
+		if err := c.Err(); err != nil {
+			t.Fatalf("Next error: %v", err)
+		}
+		return result
+	}
+
+	if got := scan(len(data) + 1); len(got) != len(data) || got[0] != 0 || got[len(got)-1] != 198 {
+		t.Fatalf("expected a full scan from 0 to 198, got %v", got)
+	}
+	for _, tc := range []struct {
+		key  int
+		want []int
+	}{
+		{50, []int{50, 52, 54}},     // present key
+		{51, []int{52, 54, 56}},     // absent key
+		{100, []int{100, 100, 100}}, // duplicated key
+		{-5, []int{0, 2, 4}},        // before the first record
+		{197, []int{198}},           // before the last record
+		{500, nil},                  // after the last record
+	} {
+		if err := c.Seek(tc.key); err != nil {
+			t.Fatalf("Seek(%d) error: %v", tc.key, err)
+		}
+		if got := scan(3); !slices.Equal(got, tc.want) {
+			t.Fatalf("Seek(%d): expected %v, got %v", tc.key, tc.want, got)
+		}
+	}
+}
+
+// TestFileCursorCorruptRecord verifies that a record header claiming more bytes
+// than the file holds is reported by Err instead of being allocated.
+func TestFileCursorCorruptRecord(t *testing.T) {
+	path := sortIntsToFile(t, []int{3, 1, 2}, nil)
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(binary.AppendUvarint(nil, 1<<62), int64(extsort.SortedFileHeaderSize)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := extsort.NewFileCursor(path, intFromBytes, cmp.Compare[int])
+	if err != nil {
+		t.Fatalf("NewFileCursor error: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	if c.Next() {
+		t.Fatalf("expected no record, got %d", c.Value())
+	}
+	if err := c.Err(); !errors.Is(err, extsort.ErrInvalidSortedFile) {
+		t.Fatalf("expected ErrInvalidSortedFile, got %v", err)
+	}
+}
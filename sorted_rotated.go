@@ -0,0 +1,270 @@
+package extsort
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+
+	"github.com/lanrat/extsort/tempfile"
+)
+
+const (
+	// rotatedFileName is the name pattern of the files written by SortToRotatedFiles.
+	rotatedFileName = "sorted-%06d.dat"
+	// rotatedIndexName is the name of the index written by SortToRotatedFiles.
+	rotatedIndexName = "index.dat"
+)
+
+// errRotatedIndexEntry is returned when an entry of a rotated file index is malformed.
+var errRotatedIndexEntry = errors.New("malformed rotated file index entry")
+
+// RotatedFile describes one of the files written by SortToRotatedFiles.
+type RotatedFile[E any] struct {
+	// Path is the path of the file, which is a sorted file as written by SortToFile
+	// and can be read with OpenSortedFile or OpenSortedFileReader
+	Path string
+	// Count is the number of records in the file
+	Count int64
+	// First and Last are the smallest and largest records in the file
+	First, Last E
+}
+
+// rotatedIndexEntry is a RotatedFile with serialized records, as stored in the index.
+type rotatedIndexEntry struct {
+	name        string
+	count       int64
+	first, last []byte
+}
+
+// toBytesRotatedIndexEntry serializes an index entry as the uvarint length of the
+// file name, the name, the uvarint record count, the uvarint length of the first
+// record, the first record, and the last record.
+func toBytesRotatedIndexEntry(e rotatedIndexEntry) ([]byte, error) {
+	b := binary.AppendUvarint(nil, uint64(len(e.name)))
+	b = append(b, e.name...)
+	b = binary.AppendUvarint(b, uint64(e.count))
+	b = binary.AppendUvarint(b, uint64(len(e.first)))
+	b = append(b, e.first...)
+	return append(b, e.last...), nil
+}
+
+func fromBytesRotatedIndexEntry(d []byte) (rotatedIndexEntry, error) {
+	var e rotatedIndexEntry
+	field := func() ([]byte, bool) {
+		l, n := binary.Uvarint(d)
+		if n <= 0 || uint64(len(d)-n) < l {
+			return nil, false
+		}
+		f := d[n : n+int(l)]
+		d = d[n+int(l):]
+		return f, true
+	}
+	name, ok := field()
+	if !ok {
+		return e, errRotatedIndexEntry
+	}
+	count, n := binary.Uvarint(d)
+	if n <= 0 {
+		return e, errRotatedIndexEntry
+	}
+	d = d[n:]
+	first, ok := field()
+	if !ok {
+		return e, errRotatedIndexEntry
+	}
+	return rotatedIndexEntry{name: string(name), count: int64(count), first: first, last: d}, nil
+}
+
+// identityBytes serializes records that are already serialized.
+func identityBytes(d []byte) ([]byte, error) {
+	return d, nil
+}
+
+// SortToRotatedFiles sorts all records from input and writes them to a set of sorted
+// files in dir, each at most maxBytes long, along with an index of the range of
+// records held by each file, much like the tables of an SSTable. Every file is
+// sorted, and every record of a file sorts before or equal to every record of the
+// files after it, so the files are globally ordered. Records are never split across
+// files; a record too large to fit in maxBytes on its own is written to a file of
+// its own, which is then larger than maxBytes. Records that compare equal may
+// continue from one file into the next.
+//
+// The files are sorted files as written by SortToFile, named in order, and the index
+// is read back with ReadRotatedIndex. The directory is created if needed. On
+// failure, the files written so far are removed.
+func SortToRotatedFiles[E any](ctx context.Context, input <-chan E, fromBytes FromBytesGeneric[E], toBytes ToBytesGeneric[E], compareFunc CompareGeneric[E], dir string, maxBytes int64, config *Config) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if maxBytes < 1 {
+		return &ConfigError{Field: "maxBytes", Value: maxBytes, Reason: "must be >= 1"}
+	}
+	config = mergeConfig(config)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return NewDiskError(err, "create rotated file directory", dir)
+	}
+	sorter, output, errChan := Generic(input, fromBytes, toBytes, compareFunc, config)
+	if sorter == nil {
+		return <-errChan
+	}
+
+	var written []string
+	defer func() {
+		if err != nil {
+			for _, path := range written {
+				_ = os.Remove(path)
+			}
+		}
+	}()
+
+	sorter.Sort(ctx)
+
+	var entries []rotatedIndexEntry
+	writeErr := func() error {
+		var pending []byte // the first record of the next file
+		for {
+			if pending == nil {
+				rec, ok := <-output
+				if !ok {
+					break
+				}
+				raw, err := toBytes(rec)
+				if err != nil {
+					return NewSerializationError(err, "SortToRotatedFiles")
+				}
+				pending = raw
+			}
+			path := filepath.Join(dir, fmt.Sprintf(rotatedFileName, len(entries)))
+			written = append(written, path)
+			entry := rotatedIndexEntry{name: filepath.Base(path)}
+			var serializeErr error
+			records := func(yield func([]byte) bool) {
+				size := int64(sortedFileHeaderSize + sortedFileTrailerSize)
+				for {
+					raw := pending
+					pending = nil
+					if raw == nil {
+						rec, ok := <-output
+						if !ok {
+							return
+						}
+						var err error
+						if raw, err = toBytes(rec); err != nil {
+							serializeErr = NewSerializationError(err, "SortToRotatedFiles")
+							return
+						}
+					}
+					// the framing, the record, and its index entry
+					recSize := int64(uvarintLen(uint64(len(raw))) + len(raw) + 8)
+					if entry.count > 0 && size+recSize > maxBytes {
+						pending = raw
+						return
+					}
+					size += recSize
+					if entry.count == 0 {
+						entry.first = raw
+					}
+					entry.last = raw
+					entry.count++
+					if !yield(raw) {
+						return
+					}
+				}
+			}
+			if err := writeSortedFileAt(ctx, path, records, identityBytes, config); err != nil {
+				return err
+			}
+			if serializeErr != nil {
+				return serializeErr
+			}
+			entries = append(entries, entry)
+		}
+
+		path := filepath.Join(dir, rotatedIndexName)
+		written = append(written, path)
+		return writeSortedFileAt(ctx, path, func(yield func(rotatedIndexEntry) bool) {
+			for _, e := range entries {
+				if !yield(e) {
+					return
+				}
+			}
+		}, toBytesRotatedIndexEntry, config)
+	}()
+	if writeErr != nil {
+		// stop the merge and drain the output so it can shut down
+		cancel()
+		for range output {
+		}
+	}
+	if err := <-errChan; err != nil && writeErr == nil {
+		return err
+	}
+	return writeErr
+}
+
+// uvarintLen returns the number of bytes needed to encode v as a uvarint.
+func uvarintLen(v uint64) int {
+	var scratch [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(scratch[:], v)
+}
+
+// writeSortedFileAt writes a sorted file holding records to path and syncs it.
+func writeSortedFileAt[E any](ctx context.Context, path string, records iter.Seq[E], toBytes ToBytesGeneric[E], config *Config) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return NewDiskError(err, "create sorted file", path)
+	}
+	defer func() { _ = f.Close() }()
+	index, err := tempfile.New(config.TempFilesDir, true, config.tempFileOptions(ctx)...)
+	if err != nil {
+		return NewResourceError(err, "temp file", "SortToRotatedFiles")
+	}
+	if err := writeSortedFile(f, records, toBytes, index, nil); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return NewDiskError(err, "sync sorted file", path)
+	}
+	if err := f.Close(); err != nil {
+		return NewDiskError(err, "close sorted file", path)
+	}
+	return nil
+}
+
+// ReadRotatedIndex reads the index of the files written to dir by
+// SortToRotatedFiles, in order. Files that do not hold an index written by
+// SortToRotatedFiles produce ErrInvalidSortedFile.
+func ReadRotatedIndex[E any](dir string, fromBytes FromBytesGeneric[E]) ([]RotatedFile[E], error) {
+	entries, errChan := OpenSortedFile(context.Background(), filepath.Join(dir, rotatedIndexName), fromBytesRotatedIndexEntry, false)
+	var files []RotatedFile[E]
+	var err error
+	for e := range entries {
+		if err != nil {
+			continue
+		}
+		file := RotatedFile[E]{Path: filepath.Join(dir, e.name), Count: e.count}
+		if file.First, err = fromBytes(e.first); err != nil {
+			err = NewDeserializationError(err, len(e.first), "ReadRotatedIndex")
+			continue
+		}
+		if file.Last, err = fromBytes(e.last); err != nil {
+			err = NewDeserializationError(err, len(e.last), "ReadRotatedIndex")
+			continue
+		}
+		files = append(files, file)
+	}
+	if readErr := <-errChan; readErr != nil {
+		if errors.Is(readErr, errRotatedIndexEntry) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSortedFile, readErr)
+		}
+		return nil, readErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,114 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// sortIntsToRotatedFiles sorts data into rotated files in a new directory and reads
+// back their index.
+func sortIntsToRotatedFiles(t *testing.T, data []int, maxBytes int64) []extsort.RotatedFile[int] {
+	t.Helper()
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 500
+	dir := filepath.Join(t.TempDir(), "out")
+	err := extsort.SortToRotatedFiles(context.Background(), inputChan, intFromBytes, intToBytes, cmp.Compare[int], dir, maxBytes, config)
+	if err != nil {
+		t.Fatalf("SortToRotatedFiles error: %v", err)
+	}
+	files, err := extsort.ReadRotatedIndex(dir, intFromBytes)
+	if err != nil {
+		t.Fatalf("ReadRotatedIndex error: %v", err)
+	}
+	return files
+}
+
+func TestSortToRotatedFiles(t *testing.T) {
+	const maxBytes = 4096
+	data := generateRandomInts(5000)
+	files := sortIntsToRotatedFiles(t, data, maxBytes)
+	if len(files) < 2 {
+		t.Fatalf("expected the output to be split, got %d files", len(files))
+	}
+
+	var all []int
+	for i, f := range files {
+		info, err := os.Stat(f.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > maxBytes {
+			t.Errorf("file %d is %d bytes, more than %d", i, info.Size(), maxBytes)
+		}
+		records := readSortedInts(t, f.Path)
+		if int64(len(records)) != f.Count || records[0] != f.First || records[len(records)-1] != f.Last {
+			t.Errorf("file %d: index entry %+v does not match its %d records", i, f, len(records))
+		}
+		if i > 0 && files[i-1].Last > f.First {
+			t.Errorf("file %d starts at %d, before the end of the previous file at %d", i, f.First, files[i-1].Last)
+		}
+		all = append(all, records...)
+	}
+	slices.Sort(data)
+	if !slices.Equal(all, data) {
+		t.Fatal("records across files do not match the sorted input")
+	}
+}
+
+// TestSortToRotatedFilesOversized verifies that records are never split, even when
+// a single record does not fit in maxBytes.
+func TestSortToRotatedFilesOversized(t *testing.T) {
+	data := []int{5, 3, 9, 1}
+	files := sortIntsToRotatedFiles(t, data, 1)
+	if len(files) != len(data) {
+		t.Fatalf("expected a file per record, got %d files", len(files))
+	}
+	for i, want := range []int{1, 3, 5, 9} {
+		if got := readSortedInts(t, files[i].Path); !slices.Equal(got, []int{want}) {
+			t.Errorf("file %d: expected [%d], got %v", i, want, got)
+		}
+	}
+}
+
+func TestSortToRotatedFilesEmpty(t *testing.T) {
+	if files := sortIntsToRotatedFiles(t, nil, 4096); len(files) != 0 {
+		t.Errorf("expected no files, got %d", len(files))
+	}
+}
+
+func TestSortToRotatedFilesInvalid(t *testing.T) {
+	inputChan := make(chan int)
+	close(inputChan)
+	err := extsort.SortToRotatedFiles(context.Background(), inputChan, intFromBytes, intToBytes, cmp.Compare[int], t.TempDir(), 0, nil)
+	var configErr *extsort.ConfigError
+	if !errors.As(err, &configErr) {
+		t.Errorf("expected a ConfigError, got %v", err)
+	}
+
+	// the bytes of -1 do not start with a valid name length
+	dir := t.TempDir()
+	path := sortIntsToFile(t, []int{-1}, nil)
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.dat"), contents, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extsort.ReadRotatedIndex(dir, intFromBytes); !errors.Is(err, extsort.ErrInvalidSortedFile) {
+		t.Errorf("expected ErrInvalidSortedFile, got %v", err)
+	}
+}
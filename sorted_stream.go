@@ -0,0 +1,134 @@
+package extsort
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/lanrat/extsort/tempfile"
+)
+
+// WriteSorted sorts like Sort and writes the sorted records to w, each framed as a
+// uvarint length followed by the record serialized with the sorter's ToBytes
+// function, as records are framed in temporary files. It returns the number of
+// bytes written once the sort finishes, with the sort's error if it failed, or the
+// first error writing to w, which stops the sort. Cancelling ctx stops the sort and
+// the writing. Once WriteSorted is called, the record and error channels returned
+// when the sorter was created must not be read. The output can be read back with
+// ReadSorted.
+func (s *GenericSorter[E]) WriteSorted(ctx context.Context, w io.Writer) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.Sort(ctx)
+
+	bw := bufio.NewWriterSize(w, tempfile.BufferSize)
+	var written int64
+	writeErr := func() error {
+		scratch := make([]byte, binary.MaxVarintLen64)
+		for rec := range s.mergeChunkChan {
+			raw, err := s.toBytes(rec)
+			if err != nil {
+				return NewSerializationError(err, "WriteSorted")
+			}
+			n := binary.PutUvarint(scratch, uint64(len(raw)))
+			if _, err := bw.Write(scratch[:n]); err != nil {
+				return NewDiskError(err, "write size header", "")
+			}
+			if _, err := bw.Write(raw); err != nil {
+				return NewDiskError(err, "write data", "")
+			}
+			written += int64(n + len(raw))
+		}
+		if err := bw.Flush(); err != nil {
+			return NewDiskError(err, "flush sorted output", "")
+		}
+		return nil
+	}()
+	if writeErr != nil {
+		// stop the merge and drain the output so it can shut down
+		cancel()
+		for range s.mergeChunkChan {
+		}
+	}
+	// bytes still buffered were never written
+	written -= int64(bw.Buffered())
+	if err := <-s.mergeErrChan; err != nil && writeErr == nil {
+		return written, err
+	}
+	return written, writeErr
+}
+
+// ReadSorted streams the records written by WriteSorted from r, deserializing them
+// with fromBytes. Reading stops when r is exhausted, at the first error, or when ctx
+// is done. Input that ends part way through a record produces io.ErrUnexpectedEOF.
+// Errors are delivered on the error channel after the output channel is closed.
+func ReadSorted[E any](ctx context.Context, r io.Reader, fromBytes FromBytesGeneric[E]) (<-chan E, <-chan error) {
+	output := make(chan E, DefaultConfig().SortedChanBuffSize)
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(errChan)
+		defer close(output)
+		if err := readSorted(ctx, bufio.NewReaderSize(r, tempfile.BufferSize), fromBytes, output); err != nil {
+			errChan <- err
+		}
+	}()
+	return output, errChan
+}
+
+// readSorted decodes framed records from br onto output.
+func readSorted[E any](ctx context.Context, br *bufio.Reader, fromBytes FromBytesGeneric[E], output chan<- E) error {
+	for {
+		n, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return NewDiskError(err, "read size header", "")
+		}
+		raw, err := readRecord(br, n)
+		if err != nil {
+			return NewDiskError(err, "read data", "")
+		}
+		rec, err := fromBytes(raw)
+		if err != nil {
+			return NewDeserializationError(err, len(raw), "ReadSorted")
+		}
+		select {
+		case output <- rec:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// maxPreallocRecord is the largest record readRecord allocates up front.
+const maxPreallocRecord = 1 << 20
+
+// readRecord reads a record of size bytes from r, where size comes from a header
+// that may be corrupt. Records larger than maxPreallocRecord are read into a buffer
+// that grows as data arrives, so a bogus size cannot claim more memory than r holds.
+// Input that ends before size bytes produces io.ErrUnexpectedEOF.
+func readRecord(r io.Reader, size uint64) ([]byte, error) {
+	if size <= maxPreallocRecord {
+		raw := make([]byte, size)
+		if _, err := io.ReadFull(r, raw); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return raw, nil
+	}
+	var buf bytes.Buffer
+	n, err := buf.ReadFrom(io.LimitReader(r, int64(min(size, math.MaxInt64))))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(n) < size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), nil
+}
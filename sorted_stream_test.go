@@ -0,0 +1,123 @@
+package extsort_test
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestWriteSortedRoundTrip(t *testing.T) {
+	data := generateRandomInts(5000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 300
+	sorter, _, _ := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	var buf bytes.Buffer
+	n, err := sorter.WriteSorted(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("WriteSorted error: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("WriteSorted reported %d bytes, wrote %d", n, buf.Len())
+	}
+
+	outChan, errChan := extsort.ReadSorted(context.Background(), bytes.NewReader(buf.Bytes()), intFromBytes)
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("ReadSorted error: %v", err)
+	}
+	slices.Sort(data)
+	if !slices.Equal(result, data) {
+		t.Fatal("round trip does not match sorted input")
+	}
+
+	// a record cut short is reported rather than silently dropped
+	outChan, errChan = extsort.ReadSorted(context.Background(), bytes.NewReader(buf.Bytes()[:buf.Len()-1]), intFromBytes)
+	if _, err := extsort.Collect(outChan, errChan, 0); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF from truncated input, got %v", err)
+	}
+}
+
+// failingWriter accepts limit bytes, then fails every write.
+type failingWriter struct {
+	limit int
+}
+
+var errWriterFull = errors.New("writer full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errWriterFull
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestWriteSortedWriterError(t *testing.T) {
+	data := generateRandomInts(100000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 10000
+	sorter, _, _ := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	n, err := sorter.WriteSorted(context.Background(), &failingWriter{limit: 100000})
+	if !errors.Is(err, errWriterFull) {
+		t.Fatalf("expected the writer's error, got %v", err)
+	}
+	if n > 100000 {
+		t.Fatalf("reported %d bytes written, more than the writer accepted", n)
+	}
+}
+
+func TestWriteSortedCancel(t *testing.T) {
+	inputChan := make(chan int, 1000)
+	for i := range 1000 {
+		inputChan <- i
+	}
+	close(inputChan)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	sorter, _, _ := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	if _, err := sorter.WriteSorted(ctx, io.Discard); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// TestReadSortedCorruptHeader verifies that a corrupt size header is reported as an
+// error instead of allocating the size it claims.
+func TestReadSortedCorruptHeader(t *testing.T) {
+	for name, input := range map[string][]byte{
+		"truncated header": {0x80},
+		"oversized record": binary.AppendUvarint(nil, math.MaxUint64),
+		"large record":     append(binary.AppendUvarint(nil, 1<<30), 1, 2, 3),
+	} {
+		outChan, errChan := extsort.ReadSorted(context.Background(), bytes.NewReader(input), intFromBytes)
+		_, err := extsort.Collect(outChan, errChan, 0)
+		var diskErr *extsort.DiskError
+		if !errors.As(err, &diskErr) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: expected DiskError wrapping io.ErrUnexpectedEOF, got %v", name, err)
+		}
+	}
+}
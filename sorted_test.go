@@ -0,0 +1,64 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+func TestSorted(t *testing.T) {
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+
+	sorted := extsort.NewSorted(outChan, errChan)
+	var result []int
+	for sorted.Next() {
+		result = append(result, sorted.Value())
+	}
+	if err := sorted.Err(); err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	slices.Sort(data)
+	if !slices.Equal(result, data) {
+		t.Fatal("cursor output does not match sorted input")
+	}
+	if sorted.Next() {
+		t.Fatal("expected Next to keep returning false after the end")
+	}
+}
+
+func TestSortedError(t *testing.T) {
+	inputChan := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		inputChan <- i
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 2
+	errTest := errors.New("test error")
+	failingToBytes := func(int) ([]byte, error) { return nil, errTest }
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, failingToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+
+	sorted := extsort.NewSorted(outChan, errChan)
+	for sorted.Next() {
+		t.Fatal("expected no records")
+	}
+	if err := sorted.Err(); !errors.Is(err, errTest) {
+		t.Fatalf("expected test error, got %v", err)
+	}
+}
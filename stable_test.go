@@ -0,0 +1,85 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestStable verifies that equal records keep their input order both within a
+// chunk and across chunks, for the in-memory, single-threaded, and parallel paths.
+func TestStable(t *testing.T) {
+	const n = 2000
+	// many records share each key; the value itself records the input position
+	key := func(v int) int { return v * 7919 % 10 }
+	compare := func(a, b int) int { return cmp.Compare(key(a), key(b)) }
+
+	for _, tc := range []struct {
+		chunkSize, numWorkers int
+	}{
+		{n, 2},    // single chunk sorted in memory
+		{100, 50}, // single-threaded merge
+		{100, 4},  // parallel merge
+	} {
+		inputChan := make(chan int, n)
+		for i := 0; i < n; i++ {
+			inputChan <- i
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = tc.chunkSize
+		config.NumWorkers = tc.numWorkers
+		config.Stable = true
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, compare, config)
+		sorter.Sort(context.Background())
+		result, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("%+v: sort error: %v", tc, err)
+		}
+		if len(result) != n {
+			t.Fatalf("%+v: expected %d records, got %d", tc, n, len(result))
+		}
+		for i := 1; i < len(result); i++ {
+			prev, cur := result[i-1], result[i]
+			if c := compare(prev, cur); c > 0 || (c == 0 && prev > cur) {
+				t.Fatalf("%+v: %d emitted before %d at position %d", tc, prev, cur, i)
+			}
+		}
+	}
+}
+
+// TestStableLegacy verifies that Config.Stable keeps equal records in input order
+// with the legacy New API, whose less function cannot report equality directly.
+func TestStableLegacy(t *testing.T) {
+	const n = 10
+	for _, chunkSize := range []int{n, 3} {
+		inputChan := make(chan extsort.SortType, n)
+		for i := 0; i < n; i++ {
+			inputChan <- val{Key: 1, Order: i}
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+		config.Stable = true
+
+		sorter, outChan, errChan := extsort.New(inputChan, fromBytesForTest, KeyLessThan, config)
+		sorter.Sort(context.Background())
+		result, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("ChunkSize %d: sort error: %v", chunkSize, err)
+		}
+		if len(result) != n {
+			t.Fatalf("ChunkSize %d: expected %d records, got %d", chunkSize, n, len(result))
+		}
+		for i, r := range result {
+			if got := r.(val).Order; got != i {
+				t.Fatalf("ChunkSize %d: position %d holds record %d", chunkSize, i, got)
+			}
+		}
+	}
+}
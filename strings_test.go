@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"slices"
 	"testing"
 
 	"github.com/lanrat/extsort"
@@ -151,3 +152,34 @@ func TestRandom1MString(t *testing.T) {
 		t.Error("not sorted")
 	}
 }
+
+// TestStringsEmbeddedDelimiters verifies that strings containing newlines, nulls,
+// and other control bytes survive being spilled to disk, since records are
+// length-prefixed rather than delimited.
+func TestStringsEmbeddedDelimiters(t *testing.T) {
+	data := []string{
+		"b\nc", "a", "", "a\x00b", "\n", "\x00", "a\n", "line1\nline2\r\nline3",
+		"\x00\x00", "tab\tsep", "a\x00", "\xff\xfe", "b", "", "\n\n",
+	}
+	for _, chunkSize := range []int{3, 1000} {
+		inputChan := make(chan string, len(data))
+		for _, s := range data {
+			inputChan <- s
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = chunkSize
+
+		sorter, outChan, errChan := extsort.Strings(inputChan, config)
+		sorter.Sort(context.Background())
+		got, err := extsort.Collect(outChan, errChan, 0)
+		if err != nil {
+			t.Fatalf("chunk size %d: sort error: %v", chunkSize, err)
+		}
+		expected := slices.Sorted(slices.Values(data))
+		if !slices.Equal(got, expected) {
+			t.Errorf("chunk size %d: expected %q, got %q", chunkSize, expected, got)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package extsort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/lanrat/extsort"
+)
+
+// TestTempFilesDirs verifies that sorting with chunks spread over two directories
+// produces correct output for both the single and parallel merge paths.
+func TestTempFilesDirs(t *testing.T) {
+	for _, numWorkers := range []int{2, 20} {
+		data := generateRandomInts(5000)
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		config := extsort.DefaultConfig()
+		config.ChunkSize = 500
+		config.NumWorkers = numWorkers
+		config.TempFilesDirs = []string{t.TempDir(), t.TempDir()}
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		sorter.Sort(context.Background())
+
+		count := 0
+		prev := -1
+		for v := range outChan {
+			if v < prev {
+				t.Fatalf("workers %d: output not sorted: %d before %d", numWorkers, prev, v)
+			}
+			prev = v
+			count++
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("workers %d: sort error: %v", numWorkers, err)
+		}
+		if count != len(data) {
+			t.Fatalf("workers %d: expected %d records, got %d", numWorkers, len(data), count)
+		}
+	}
+}
+
+// TestTempFileID verifies that temporary files are named by TempFileID and that
+// a name that is already taken fails the sort.
+func TestTempFileID(t *testing.T) {
+	data := generateRandomInts(1000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	var ids []string
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.TempFilesDir = t.TempDir()
+	config.TempFileID = func() string {
+		id := fmt.Sprintf("host-%d", len(ids))
+		ids = append(ids, id)
+		return id
+	}
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	got, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	if len(got) != len(data) {
+		t.Fatalf("expected %d records, got %d", len(data), len(got))
+	}
+	if !slices.Equal(ids, []string{"host-0"}) {
+		t.Fatalf("expected one generated id, got %v", ids)
+	}
+
+	// a file left behind by another host with the same name
+	config.TempFileID = func() string { return "taken" }
+	if err := os.WriteFile(filepath.Join(config.TempFilesDir, "extsort_taken"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, outChan, errChan = extsort.Generic(make(chan int), intFromBytes, intToBytes, cmp.Compare[int], config)
+	if _, err := extsort.Collect(outChan, errChan, 0); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected fs.ErrExist, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package tempfile
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+// BufferPool recycles the I/O buffers of BufferSize bytes held by writers and by
+// section readers, so that sorts run one after another reuse them instead of
+// allocating new ones. A BufferPool is safe for concurrent use by any number of
+// writers and readers. Readers returned by SizedReader.ReadSize have a caller chosen
+// size and are not pooled.
+type BufferPool struct {
+	readers sync.Pool // *bufio.Reader of fileBufferSize
+	writers sync.Pool // *bufio.Writer of fileBufferSize
+}
+
+// NewBufferPool returns an empty BufferPool.
+func NewBufferPool() *BufferPool {
+	return &BufferPool{}
+}
+
+// defaultBufferPool is shared by all temporary files created without WithBufferPool.
+var defaultBufferPool = NewBufferPool()
+
+// WithBufferPool draws the I/O buffers of temporary files from p, and returns them
+// to p once the files are closed. By default a pool shared by the whole process is used.
+func WithBufferPool(p *BufferPool) Option {
+	return func(o *options) {
+		o.bufferPool = p
+	}
+}
+
+// getReader returns a reader of fileBufferSize bytes reading from r.
+func (p *BufferPool) getReader(r io.Reader) *bufio.Reader {
+	if br, ok := p.readers.Get().(*bufio.Reader); ok {
+		br.Reset(r)
+		return br
+	}
+	return bufio.NewReaderSize(r, fileBufferSize)
+}
+
+// putReader returns br to the pool. It must no longer be used by the caller.
+func (p *BufferPool) putReader(br *bufio.Reader) {
+	br.Reset(nil)
+	p.readers.Put(br)
+}
+
+// getWriter returns a writer of fileBufferSize bytes writing to w.
+func (p *BufferPool) getWriter(w io.Writer) *bufio.Writer {
+	if bw, ok := p.writers.Get().(*bufio.Writer); ok {
+		bw.Reset(w)
+		return bw
+	}
+	return bufio.NewWriterSize(w, fileBufferSize)
+}
+
+// putWriter returns bw to the pool, discarding any unflushed data. It must no
+// longer be used by the caller.
+func (p *BufferPool) putWriter(bw *bufio.Writer) {
+	bw.Reset(nil)
+	p.writers.Put(bw)
+}
@@ -89,13 +89,6 @@ func newMockTempReader(sections []int, data []byte) (*mockFileReader, error) {
 	r.sections = sections
 	r.readers = make([]*bufio.Reader, len(r.sections))
 
-	offset := 0
-	for i, end := range r.sections {
-		section := io.NewSectionReader(r.data, int64(offset), int64(end-offset))
-		offset = end
-		r.readers[i] = bufio.NewReaderSize(section, fileBufferSize)
-	}
-
 	return &r, nil
 }
 
@@ -113,10 +106,32 @@ func (r *mockFileReader) Size() int {
 }
 
 // Read returns a buffered reader for the specified virtual file section.
+// The reader is created on first use and returned again by later calls.
 // Panics if the section index is out of range.
 func (r *mockFileReader) Read(i int) *bufio.Reader {
 	if i < 0 || i >= len(r.readers) {
 		panic("tempfile: read request out of range")
 	}
+	if r.readers[i] == nil {
+		r.readers[i] = bufio.NewReaderSize(r.section(i), fileBufferSize)
+	}
 	return r.readers[i]
 }
+
+// ReadSize returns a new reader for the specified virtual file section that
+// buffers size bytes. Panics if the section index is out of range.
+func (r *mockFileReader) ReadSize(i, size int) *bufio.Reader {
+	if i < 0 || i >= len(r.readers) {
+		panic("tempfile: read request out of range")
+	}
+	return bufio.NewReaderSize(r.section(i), size)
+}
+
+// section returns an unbuffered reader over virtual file section i.
+func (r *mockFileReader) section(i int) *io.SectionReader {
+	start := 0
+	if i > 0 {
+		start = r.sections[i-1]
+	}
+	return io.NewSectionReader(r.data, int64(start), int64(r.sections[i]-start))
+}
@@ -0,0 +1,169 @@
+package tempfile
+
+import (
+	"bufio"
+	"errors"
+)
+
+// MultiFileWriter spreads virtual temporary file sections across several directories,
+// assigning sections to directories in round-robin order. Each directory holds a single
+// physical file. Sections are written one at a time, each to the file of its directory,
+// while the reader can read sections stored on different disks concurrently. It
+// satisfies the TempWriter interface.
+type MultiFileWriter struct {
+	writers []*FileWriter
+	owners  []int // index of the writer holding each completed section
+	current int   // index of the writer receiving the current section
+}
+
+// multiFileReader provides the TempReader for a MultiFileWriter, mapping each
+// virtual section to its section within the reader of the directory holding it.
+type multiFileReader struct {
+	readers  []TempReader
+	sections []multiFileSection
+}
+
+// multiFileSection locates a virtual section within one of the underlying readers.
+type multiFileSection struct {
+	reader int
+	index  int
+}
+
+// NewMulti creates a MultiFileWriter with one temporary file in each of dirs.
+// Directory selection for each entry follows the same rules as New, so an empty
+// entry uses intelligent directory selection controlled by preferDiskBacked.
+func NewMulti(dirs []string, preferDiskBacked bool, opts ...Option) (*MultiFileWriter, error) {
+	if len(dirs) == 0 {
+		return nil, errors.New("tempfile: no directories provided")
+	}
+	w := &MultiFileWriter{
+		writers: make([]*FileWriter, 0, len(dirs)),
+	}
+	for _, dir := range dirs {
+		fw, err := New(dir, preferDiskBacked, opts...)
+		if err != nil {
+			_ = w.Close()
+			return nil, err
+		}
+		w.writers = append(w.writers, fw)
+	}
+	return w, nil
+}
+
+// Size returns the total number of virtual file sections created.
+// This includes the current section being written plus all completed sections.
+func (w *MultiFileWriter) Size() int {
+	return len(w.owners) + 1
+}
+
+// Names returns the full filesystem paths of the underlying physical temporary files,
+// one per directory. This is primarily useful for debugging and logging purposes.
+func (w *MultiFileWriter) Names() []string {
+	names := make([]string, len(w.writers))
+	for i, fw := range w.writers {
+		names[i] = fw.Name()
+	}
+	return names
+}
+
+// Close terminates every underlying FileWriter and removes their files.
+// It returns the first error encountered.
+func (w *MultiFileWriter) Close() error {
+	var err error
+	for _, fw := range w.writers {
+		if closeErr := fw.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	w.writers = nil
+	w.owners = nil
+	return err
+}
+
+// Write appends data to the current virtual file section.
+func (w *MultiFileWriter) Write(p []byte) (int, error) {
+	return w.writers[w.current].Write(p)
+}
+
+// WriteString appends a string to the current virtual file section.
+func (w *MultiFileWriter) WriteString(s string) (int, error) {
+	return w.writers[w.current].WriteString(s)
+}
+
+// Next finalizes the current virtual file section and moves on to the next directory.
+// Returns the offset at which the finalized section ended within its physical file.
+func (w *MultiFileWriter) Next() (int64, error) {
+	pos, err := w.writers[w.current].Next()
+	if err != nil {
+		return 0, err
+	}
+	w.owners = append(w.owners, w.current)
+	w.current = (w.current + 1) % len(w.writers)
+	return pos, nil
+}
+
+// Save finalizes all virtual file sections and returns a TempReader for accessing the data.
+// Sections keep the order in which they were written, regardless of their directory.
+// After calling Save(), the MultiFileWriter can no longer be used for writing.
+func (w *MultiFileWriter) Save() (TempReader, error) {
+	r := &multiFileReader{
+		readers:  make([]TempReader, 0, len(w.writers)),
+		sections: make([]multiFileSection, 0, len(w.owners)+1),
+	}
+	for i, fw := range w.writers {
+		reader, err := fw.Save()
+		if err != nil {
+			for _, remaining := range w.writers[i:] {
+				_ = remaining.Close()
+			}
+			_ = r.Close()
+			return nil, err
+		}
+		r.readers = append(r.readers, reader)
+	}
+
+	// the section being written when Save was called is the last one of its writer
+	counts := make([]int, len(w.writers))
+	for _, owner := range append(w.owners, w.current) {
+		r.sections = append(r.sections, multiFileSection{reader: owner, index: counts[owner]})
+		counts[owner]++
+	}
+	return r, nil
+}
+
+// Close closes every underlying reader and returns the first error encountered.
+func (r *multiFileReader) Close() error {
+	var err error
+	for _, reader := range r.readers {
+		if closeErr := reader.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	r.readers = nil
+	return err
+}
+
+// Size returns the number of virtual file sections available for reading.
+func (r *multiFileReader) Size() int {
+	return len(r.sections)
+}
+
+// Read returns a buffered reader for the specified virtual file section.
+// Panics if the section index is out of range.
+func (r *multiFileReader) Read(i int) *bufio.Reader {
+	if i < 0 || i >= len(r.sections) {
+		panic("tempfile: read request out of range")
+	}
+	section := r.sections[i]
+	return r.readers[section.reader].Read(section.index)
+}
+
+// ReadSize returns a new reader for the specified virtual file section that
+// buffers size bytes. Panics if the section index is out of range.
+func (r *multiFileReader) ReadSize(i, size int) *bufio.Reader {
+	if i < 0 || i >= len(r.sections) {
+		panic("tempfile: read request out of range")
+	}
+	section := r.sections[i]
+	return ReadSize(r.readers[section.reader], section.index, size)
+}
@@ -0,0 +1,54 @@
+package tempfile
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Option configures the temporary files created by New and NewMulti.
+type Option func(*options)
+
+// options holds the settings applied by Option values.
+type options struct {
+	fileID       func() string
+	bufferPool   *BufferPool
+	retries      int
+	retryBackoff time.Duration
+	ctx          context.Context
+	wrapWriter   func(io.Writer) io.Writer
+}
+
+// WithFileID names temporary files with the string returned by id in place of
+// the process id and random suffix used by default. This allows callers sharing
+// a directory across hosts, such as network storage, to supply host-qualified
+// names. It is called once for every file created, and must return a unique
+// name each time; creating a file that already exists fails.
+func WithFileID(id func() string) Option {
+	return func(o *options) {
+		o.fileID = id
+	}
+}
+
+// WithFileWriter passes the writer that each temporary file's buffered data is
+// written through to wrap, and writes through the writer it returns instead. The
+// wrapper sits below the retries selected by WithRetry, so it can be used to
+// instrument or throttle temporary file writes, or to inject failures in tests.
+func WithFileWriter(wrap func(io.Writer) io.Writer) Option {
+	return func(o *options) {
+		o.wrapWriter = wrap
+	}
+}
+
+// Create creates and opens a new temporary file in dir whose name starts with
+// prefix. When id is nil, a random suffix is appended as by os.CreateTemp.
+// Otherwise the file is named prefix followed by the result of id, and Create
+// fails with an error matching fs.ErrExist if that file already exists.
+func Create(dir, prefix string, id func() string) (*os.File, error) {
+	if id == nil {
+		return os.CreateTemp(dir, prefix+"*")
+	}
+	return os.OpenFile(filepath.Join(dir, prefix+id()), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+}
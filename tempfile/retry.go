@@ -0,0 +1,139 @@
+package tempfile
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// defaultRetryBackoff is the wait before the first retry when WithRetry is given
+// no backoff.
+const defaultRetryBackoff = 10 * time.Millisecond
+
+// maxRetryBackoff is the longest wait between retries, unless the backoff given to
+// WithRetry is longer.
+const maxRetryBackoff = time.Minute
+
+// WithRetry retries reads and writes of temporary files that fail with a transient
+// error up to retries times before giving up, waiting backoff before the first
+// retry and twice as long before each one after it, up to a minute. Transient
+// errors are those reporting themselves as temporary or as timeouts, such as
+// EAGAIN or ETIMEDOUT. Any other error, such as permission denied or EIO, is
+// returned immediately: after an EIO the kernel may already have dropped the data
+// that failed to reach the disk, so a retry that succeeds would hide the loss. The
+// final fsync of a file is never retried for the same reason. A backoff of zero
+// waits 10ms. Waits end early once the context given to WithContext is done.
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.retries = retries
+		o.retryBackoff = backoff
+	}
+}
+
+// WithContext stops retries selected by WithRetry once ctx is done: a wait for
+// the next retry ends early, and the operation fails with the error of its last
+// attempt.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// retrier retries operations that fail with transient errors.
+type retrier struct {
+	retries int
+	backoff time.Duration
+	done    <-chan struct{} // closed once retries must stop; nil if never
+}
+
+// newRetrier returns the retrier selected by o, or nil if retries are disabled.
+func newRetrier(o *options) *retrier {
+	if o.retries <= 0 {
+		return nil
+	}
+	backoff := o.retryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	r := &retrier{retries: o.retries, backoff: backoff}
+	if o.ctx != nil {
+		r.done = o.ctx.Done()
+	}
+	return r
+}
+
+// retry reports whether an operation that failed with err on the given zero based
+// attempt should be tried again, after waiting for the backoff if so. It gives up
+// when the retrier's context is done before the wait ends.
+func (r *retrier) retry(attempt int, err error) bool {
+	if attempt >= r.retries || !isTransient(err) {
+		return false
+	}
+	timer := time.NewTimer(r.wait(attempt))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// wait returns the wait before retrying after the given zero based attempt, which
+// doubles with every attempt up to maxRetryBackoff.
+func (r *retrier) wait(attempt int) time.Duration {
+	limit := max(r.backoff, maxRetryBackoff)
+	wait := r.backoff
+	for range attempt {
+		if wait >= limit/2 {
+			return limit
+		}
+		wait *= 2
+	}
+	return wait
+}
+
+// isTransient reports whether err may go away if the operation is tried again.
+func isTransient(err error) bool {
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return true
+	}
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// retryWriter writes to w, retrying the unwritten remainder after transient errors.
+type retryWriter struct {
+	w io.Writer
+	r *retrier
+}
+
+func (rw *retryWriter) Write(p []byte) (int, error) {
+	var written int
+	for attempt := 0; ; attempt++ {
+		n, err := rw.w.Write(p[written:])
+		written += n
+		if err == nil || !rw.r.retry(attempt, err) {
+			return written, err
+		}
+	}
+}
+
+// retryReaderAt reads from r, retrying the unread remainder after transient errors.
+type retryReaderAt struct {
+	ra io.ReaderAt
+	r  *retrier
+}
+
+func (rr *retryReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	var read int
+	for attempt := 0; ; attempt++ {
+		n, err := rr.ra.ReadAt(p[read:], off+int64(read))
+		read += n
+		if err == nil || !rr.r.retry(attempt, err) {
+			return read, err
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package tempfile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyFile fails its first failures writes and reads with err, after writing
+// or reading half of the requested bytes, then behaves normally.
+type flakyFile struct {
+	buf      bytes.Buffer
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyFile) Write(p []byte) (int, error) {
+	f.calls++
+	if f.failures > 0 {
+		f.failures--
+		n, _ := f.buf.Write(p[:len(p)/2])
+		return n, f.err
+	}
+	return f.buf.Write(p)
+}
+
+func (f *flakyFile) ReadAt(p []byte, off int64) (int, error) {
+	f.calls++
+	if f.failures > 0 {
+		f.failures--
+		n := copy(p[:len(p)/2], f.buf.Bytes()[off:])
+		return n, f.err
+	}
+	n := copy(p, f.buf.Bytes()[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestRetry(t *testing.T) {
+	r := newRetrier(&options{retries: 3, retryBackoff: time.Microsecond})
+	data := []byte("transient errors are retried")
+	transient := &os.PathError{Op: "write", Path: "flaky", Err: syscall.EAGAIN}
+
+	f := &flakyFile{failures: 3, err: transient}
+	w := &retryWriter{w: f, r: r}
+	if n, err := w.Write(data); err != nil || n != len(data) {
+		t.Fatalf("expected write to succeed after 3 retries, got %d, %v", n, err)
+	}
+	if !bytes.Equal(f.buf.Bytes(), data) {
+		t.Fatalf("expected %q written, got %q", data, f.buf.Bytes())
+	}
+
+	f.failures = 3
+	got := make([]byte, len(data))
+	ra := &retryReaderAt{ra: f, r: r}
+	if n, err := ra.ReadAt(got, 0); err != nil || n != len(data) {
+		t.Fatalf("expected read to succeed after 3 retries, got %d, %v", n, err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected %q read, got %q", data, got)
+	}
+
+	// one failure more than the retries gives up
+	f = &flakyFile{failures: 4, err: transient}
+	w = &retryWriter{w: f, r: r}
+	if _, err := w.Write(data); !errors.Is(err, syscall.EAGAIN) {
+		t.Fatalf("expected EAGAIN once retries are exhausted, got %v", err)
+	}
+	if f.calls != 4 {
+		t.Fatalf("expected 4 attempts, got %d", f.calls)
+	}
+}
+
+func TestRetryPermanentError(t *testing.T) {
+	r := newRetrier(&options{retries: 3, retryBackoff: time.Microsecond})
+	f := &flakyFile{failures: 1, err: &os.PathError{Op: "write", Path: "flaky", Err: syscall.EACCES}}
+	w := &retryWriter{w: f, r: r}
+	if _, err := w.Write([]byte("denied")); !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("expected permission error, got %v", err)
+	}
+	if f.calls != 1 {
+		t.Fatalf("expected permission denied not to be retried, got %d attempts", f.calls)
+	}
+}
+
+func TestRetryDisabled(t *testing.T) {
+	if r := newRetrier(&options{}); r != nil {
+		t.Fatal("expected no retrier without retries")
+	}
+}
+
+func TestRetryEIO(t *testing.T) {
+	r := newRetrier(&options{retries: 3, retryBackoff: time.Microsecond})
+	f := &flakyFile{failures: 1, err: &os.PathError{Op: "write", Path: "flaky", Err: syscall.EIO}}
+	w := &retryWriter{w: f, r: r}
+	if _, err := w.Write([]byte("lost")); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("expected EIO, got %v", err)
+	}
+	if f.calls != 1 {
+		t.Fatalf("expected EIO not to be retried, got %d attempts", f.calls)
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := newRetrier(&options{retries: 3, retryBackoff: time.Hour, ctx: ctx})
+	f := &flakyFile{failures: 1, err: &os.PathError{Op: "write", Path: "flaky", Err: syscall.EAGAIN}}
+	w := &retryWriter{w: f, r: r}
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := w.Write([]byte("cancelled")); !errors.Is(err, syscall.EAGAIN) {
+		t.Fatalf("expected the error of the last attempt, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("retry waited %v after the context was cancelled", elapsed)
+	}
+	if f.calls != 1 {
+		t.Fatalf("expected no retry after the context was cancelled, got %d attempts", f.calls)
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	r := newRetrier(&options{retries: 100, retryBackoff: time.Millisecond})
+	if got := r.wait(0); got != time.Millisecond {
+		t.Fatalf("expected the first wait to be the backoff, got %v", got)
+	}
+	if got := r.wait(3); got != 8*time.Millisecond {
+		t.Fatalf("expected the wait to double, got %v", got)
+	}
+	// large attempts must not overflow
+	if got := r.wait(99); got != maxRetryBackoff {
+		t.Fatalf("expected the wait to be capped at %v, got %v", maxRetryBackoff, got)
+	}
+	r = newRetrier(&options{retries: 100, retryBackoff: time.Hour})
+	if got := r.wait(99); got != time.Hour {
+		t.Fatalf("expected a backoff above the cap to be kept, got %v", got)
+	}
+}
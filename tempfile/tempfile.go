@@ -19,6 +19,7 @@ package tempfile
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -29,6 +30,10 @@ import (
 // file IO buffer size for each file
 const fileBufferSize = 1 << 16 // 64k
 
+// BufferSize is the size of the I/O buffer allocated for the writer and for
+// each section reader. It can be used to estimate the memory held while merging.
+const BufferSize = fileBufferSize
+
 // filename prefix for files put in temp directory
 var mergeFilenamePrefix = fmt.Sprintf("extsort_%d_", os.Getpid())
 
@@ -48,6 +53,9 @@ var (
 type FileWriter struct {
 	file         *os.File
 	bufWriter    *bufio.Writer
+	pool         *BufferPool
+	retrier      *retrier                  // nil unless transient errors are retried
+	wrapWriter   func(io.Writer) io.Writer // set by WithFileWriter
 	sections     []int64
 	needsCleanup bool   // true if manual cleanup is needed (Windows)
 	createdDir   string // directory we created (for cleanup)
@@ -57,8 +65,11 @@ type fileReader struct {
 	file         *os.File
 	sections     []int64
 	readers      []*bufio.Reader
-	needsCleanup bool   // true if manual cleanup is needed (Windows)
-	filename     string // filename for cleanup
+	pool         *BufferPool
+	retrier      *retrier                  // nil unless transient errors are retried
+	wrapWriter   func(io.Writer) io.Writer // kept for Recycle
+	needsCleanup bool                      // true if manual cleanup is needed (Windows)
+	filename     string                    // filename for cleanup
 }
 
 // New creates a new FileWriter for virtual temporary files in the specified directory.
@@ -66,9 +77,13 @@ type fileReader struct {
 // locations over potentially memory-backed filesystems (controlled by preferDiskBacked).
 // The function attempts automatic cleanup on Unix systems by unlinking the file immediately,
 // while Windows requires explicit cleanup when the FileWriter is closed.
-func New(dir string, preferDiskBacked bool) (*FileWriter, error) {
+func New(dir string, preferDiskBacked bool, opts ...Option) (*FileWriter, error) {
 	var w FileWriter
 	var err error
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 
 	// Use intelligent directory selection if no specific directory provided
 	selectedDir := GetTempDir(dir, preferDiskBacked)
@@ -88,7 +103,11 @@ func New(dir string, preferDiskBacked bool) (*FileWriter, error) {
 		incrementDirRefCount(selectedDir)
 	}
 
-	w.file, err = os.CreateTemp(selectedDir, mergeFilenamePrefix)
+	if o.fileID != nil {
+		w.file, err = Create(selectedDir, "extsort_", o.fileID)
+	} else {
+		w.file, err = Create(selectedDir, mergeFilenamePrefix, nil)
+	}
 	if err != nil {
 		// Clean up if we created the directory but failed to create the file
 		if w.createdDir != "" {
@@ -103,7 +122,13 @@ func New(dir string, preferDiskBacked bool) (*FileWriter, error) {
 		w.needsCleanup = true // Manual cleanup needed
 	}
 
-	w.bufWriter = bufio.NewWriterSize(w.file, fileBufferSize)
+	w.pool = o.bufferPool
+	if w.pool == nil {
+		w.pool = defaultBufferPool
+	}
+	w.retrier = newRetrier(&o)
+	w.wrapWriter = o.wrapWriter
+	w.bufWriter = w.pool.getWriter(w.output())
 	w.sections = make([]int64, 0, 10)
 
 	return &w, nil
@@ -130,7 +155,7 @@ func (w *FileWriter) Close() error {
 	filename := w.file.Name()
 	err := w.file.Close()
 	w.sections = nil
-	w.bufWriter = nil
+	w.releaseWriter()
 
 	// Only attempt manual cleanup if needed (Windows case)
 	if w.needsCleanup {
@@ -185,10 +210,13 @@ func (w *FileWriter) Save() (TempReader, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = w.file.Sync()
-	if err != nil {
+	// a failed fsync is not retried: the pages that failed to be written may already
+	// have been dropped, so a retry could succeed without the data being on disk
+	if err = w.file.Sync(); err != nil {
 		return nil, err
 	}
+	// everything has been flushed by Next
+	w.releaseWriter()
 
 	if w.needsCleanup {
 		// Windows case: close file and reopen for reading
@@ -197,16 +225,37 @@ func (w *FileWriter) Save() (TempReader, error) {
 		if err != nil {
 			return nil, err
 		}
-		return newTempReader(filename, w.sections, w.needsCleanup)
+		return newTempReader(filename, w.sections, w.needsCleanup, w.pool, w.retrier, w.wrapWriter)
 	} else {
 		// Unix case: file is unlinked, reuse the same file handle
-		return newTempReaderFromFile(w.file, w.sections, w.needsCleanup)
+		return newTempReaderFromFile(w.file, w.sections, w.needsCleanup, w.pool, w.retrier, w.wrapWriter)
+	}
+}
+
+// output returns the writer that buffered writes are flushed to: the file, wrapped
+// as selected by WithFileWriter and retrying transient errors as selected by WithRetry.
+func (w *FileWriter) output() io.Writer {
+	var out io.Writer = w.file
+	if w.wrapWriter != nil {
+		out = w.wrapWriter(out)
+	}
+	if w.retrier != nil {
+		out = &retryWriter{w: out, r: w.retrier}
+	}
+	return out
+}
+
+// releaseWriter returns the write buffer to its pool, if it has not been already.
+func (w *FileWriter) releaseWriter() {
+	if w.bufWriter != nil {
+		w.pool.putWriter(w.bufWriter)
+		w.bufWriter = nil
 	}
 }
 
 // newTempReader creates a TempReader by opening a file by name.
 // This is used on Windows where files need to be closed and reopened for reading.
-func newTempReader(filename string, sections []int64, needsCleanup bool) (*fileReader, error) {
+func newTempReader(filename string, sections []int64, needsCleanup bool, pool *BufferPool, retrier *retrier, wrapWriter func(io.Writer) io.Writer) (*fileReader, error) {
 	// create TempReader by opening file by name
 	var err error
 	var r fileReader
@@ -218,20 +267,16 @@ func newTempReader(filename string, sections []int64, needsCleanup bool) (*fileR
 	r.readers = make([]*bufio.Reader, len(r.sections))
 	r.needsCleanup = needsCleanup
 	r.filename = filename
-
-	offset := int64(0)
-	for i, end := range r.sections {
-		section := io.NewSectionReader(r.file, offset, end-offset)
-		offset = end
-		r.readers[i] = bufio.NewReaderSize(section, fileBufferSize)
-	}
+	r.pool = pool
+	r.retrier = retrier
+	r.wrapWriter = wrapWriter
 
 	return &r, nil
 }
 
 // newTempReaderFromFile creates a TempReader by reusing an existing file handle.
 // This is used on Unix systems where unlinked files can continue to be accessed.
-func newTempReaderFromFile(file *os.File, sections []int64, needsCleanup bool) (*fileReader, error) {
+func newTempReaderFromFile(file *os.File, sections []int64, needsCleanup bool, pool *BufferPool, retrier *retrier, wrapWriter func(io.Writer) io.Writer) (*fileReader, error) {
 	// create TempReader by reusing existing file handle
 	var r fileReader
 	r.file = file
@@ -239,13 +284,9 @@ func newTempReaderFromFile(file *os.File, sections []int64, needsCleanup bool) (
 	r.readers = make([]*bufio.Reader, len(r.sections))
 	r.needsCleanup = needsCleanup
 	r.filename = file.Name()
-
-	offset := int64(0)
-	for i, end := range r.sections {
-		section := io.NewSectionReader(r.file, offset, end-offset)
-		offset = end
-		r.readers[i] = bufio.NewReaderSize(section, fileBufferSize)
-	}
+	r.pool = pool
+	r.retrier = retrier
+	r.wrapWriter = wrapWriter
 
 	return &r, nil
 }
@@ -253,6 +294,11 @@ func newTempReaderFromFile(file *os.File, sections []int64, needsCleanup bool) (
 // Close closes the fileReader and cleans up the underlying file if manual cleanup is needed.
 // On Windows, this removes the temporary file from disk.
 func (r *fileReader) Close() error {
+	for _, br := range r.readers {
+		if br != nil {
+			r.pool.putReader(br)
+		}
+	}
 	r.readers = nil
 	err := r.file.Close()
 
@@ -272,14 +318,90 @@ func (r *fileReader) Size() int {
 }
 
 // Read returns a buffered reader for the specified virtual file section.
+// The reader is created on first use and returned again by later calls.
 // Panics if the section index is out of range.
 func (r *fileReader) Read(i int) *bufio.Reader {
 	if i < 0 || i >= len(r.readers) {
 		panic("tempfile: read request out of range")
 	}
+	if r.readers[i] == nil {
+		r.readers[i] = r.pool.getReader(r.section(i))
+	}
 	return r.readers[i]
 }
 
+// ReadSize returns a new reader for the specified virtual file section that
+// buffers size bytes. Panics if the section index is out of range.
+func (r *fileReader) ReadSize(i, size int) *bufio.Reader {
+	if i < 0 || i >= len(r.readers) {
+		panic("tempfile: read request out of range")
+	}
+	return bufio.NewReaderSize(r.section(i), size)
+}
+
+// section returns an unbuffered reader over virtual file section i.
+func (r *fileReader) section(i int) *io.SectionReader {
+	var start int64
+	if i > 0 {
+		start = r.sections[i-1]
+	}
+	if r.retrier != nil {
+		return io.NewSectionReader(&retryReaderAt{ra: r.file, r: r.retrier}, start, r.sections[i]-start)
+	}
+	return io.NewSectionReader(r.file, start, r.sections[i]-start)
+}
+
+// ErrNotRecyclable is returned by Recycle for readers that were not returned by
+// FileWriter.Save.
+var ErrNotRecyclable = errors.New("tempfile: reader cannot be recycled")
+
+// Recycle empties the physical file behind r, which must have been returned by
+// FileWriter.Save, and returns a new FileWriter that writes to it from the start.
+// This allows a temporary file to be reused once its sections have been read,
+// without creating another file. r and the readers it returned must no longer be
+// used. If r cannot be recycled, it is left open and ErrNotRecyclable is returned;
+// on any other error, r is closed.
+func Recycle(r TempReader) (TempWriter, error) {
+	fr, ok := r.(*fileReader)
+	if !ok {
+		return nil, ErrNotRecyclable
+	}
+	for _, br := range fr.readers {
+		if br != nil {
+			fr.pool.putReader(br)
+		}
+	}
+	fr.readers = nil
+
+	file := fr.file
+	var err error
+	if fr.needsCleanup {
+		// Windows case: the reader opened the file read-only, so reopen it for writing
+		if err = file.Close(); err == nil {
+			file, err = os.OpenFile(fr.filename, os.O_RDWR|os.O_TRUNC, 0)
+		}
+	} else {
+		if err = file.Truncate(0); err == nil {
+			_, err = file.Seek(0, io.SeekStart)
+		}
+	}
+	if err != nil {
+		_ = fr.Close()
+		return nil, err
+	}
+
+	w := &FileWriter{
+		file:         file,
+		pool:         fr.pool,
+		retrier:      fr.retrier,
+		wrapWriter:   fr.wrapWriter,
+		sections:     make([]int64, 0, 10),
+		needsCleanup: fr.needsCleanup,
+	}
+	w.bufWriter = w.pool.getWriter(w.output())
+	return w, nil
+}
+
 // incrementDirRefCount increments the reference count for a directory we created.
 // This is used to track how many FileWriters are using a shared temp directory.
 func incrementDirRefCount(dir string) {
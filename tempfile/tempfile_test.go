@@ -1,9 +1,12 @@
 package tempfile_test
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/lanrat/extsort/tempfile"
@@ -194,3 +197,230 @@ func TestMockFileMultiSection(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestTempFileReadSize(t *testing.T) {
+	line := "The quick brown fox jumps over the lazy dog"
+	fileWriter, err := tempfile.New("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiWriter, err := tempfile.NewMulti([]string{t.TempDir(), t.TempDir()}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tempWriter := range map[string]tempfile.TempWriter{
+		"file":  fileWriter,
+		"mock":  tempfile.Mock(10),
+		"multi": multiWriter,
+	} {
+		for i := 0; i < 3; i++ {
+			if _, err := fmt.Fprintf(tempWriter, "%d: %s", i, line); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tempWriter.Next(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tempReader, err := tempWriter.Save()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			r := tempfile.ReadSize(tempReader, i, 16)
+			if r.Size() != 16 {
+				t.Fatalf("%s: expected a 16 byte buffer, got %d", name, r.Size())
+			}
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := fmt.Sprintf("%d: %s", i, line); string(b) != expected {
+				t.Fatalf("%s: section %d returned %q expected %q", name, i, b, expected)
+			}
+		}
+		// a reader without ReadSize falls back to Read
+		plain := struct{ tempfile.TempReader }{tempReader}
+		b, err := io.ReadAll(tempfile.ReadSize(plain, 1, 16))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprintf("1: %s", line); string(b) != expected {
+			t.Fatalf("%s: fallback returned %q expected %q", name, b, expected)
+		}
+		if err := tempReader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTempFileWithFileID(t *testing.T) {
+	dir := t.TempDir()
+	tempWriter, err := tempfile.New(dir, true, tempfile.WithFileID(func() string { return "host-1" }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = tempWriter.Close() }()
+	if name, want := tempWriter.Name(), filepath.Join(dir, "extsort_host-1"); name != want {
+		t.Fatalf("tempWriter.Name returned %q, expected %q", name, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "extsort_host-2"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tempfile.New(dir, true, tempfile.WithFileID(func() string { return "host-2" })); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected fs.ErrExist, got %v", err)
+	}
+}
+
+func TestTempFileBufferPool(t *testing.T) {
+	pool := tempfile.NewBufferPool()
+	// buffers reused by later files must not leak data from earlier ones
+	for round := 0; round < 5; round++ {
+		tempWriter, err := tempfile.New(t.TempDir(), true, tempfile.WithBufferPool(pool))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			if _, err := fmt.Fprintf(tempWriter, "round %d section %d", round, i); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tempWriter.Next(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tempReader, err := tempWriter.Save()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			b, err := io.ReadAll(tempReader.Read(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := fmt.Sprintf("round %d section %d", round, i); string(b) != expected {
+				t.Fatalf("section %d returned %q expected %q", i, b, expected)
+			}
+		}
+		if err := tempReader.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// closing after Save must not return the write buffer twice
+		_ = tempWriter.Close()
+	}
+}
+
+func TestMultiTempFile(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+	tempWriter, err := tempfile.NewMulti(dirs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := tempWriter.Names()
+	if len(names) != len(dirs) {
+		t.Fatalf("expected %d files, got %d", len(dirs), len(names))
+	}
+	for i, name := range names {
+		if filepath.Dir(name) != dirs[i] {
+			t.Errorf("expected file %d in %q, got %q", i, dirs[i], name)
+		}
+	}
+
+	const sections = 5
+	for i := 0; i < sections; i++ {
+		if _, err := tempWriter.WriteString(fmt.Sprintf("section %d", i)); err != nil {
+			t.Fatal(err)
+		}
+		if i < sections-1 {
+			if _, err := tempWriter.Next(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if s := tempWriter.Size(); s != sections {
+		t.Fatalf("tempWriter.Size returned %d, expected %d", s, sections)
+	}
+
+	tempReader, err := tempWriter.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := tempReader.Size(); s != sections {
+		t.Fatalf("tempReader.Size returned %d, expected %d", s, sections)
+	}
+	// read sections out of order to ensure they are independent
+	for i := sections - 1; i >= 0; i-- {
+		data, err := io.ReadAll(tempReader.Read(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("section %d", i); string(data) != want {
+			t.Fatalf("section %d: read %q, expected %q", i, data, want)
+		}
+	}
+	if err := tempReader.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMultiTempFileNoDirs(t *testing.T) {
+	if _, err := tempfile.NewMulti(nil, true); err == nil {
+		t.Fatal("expected an error with no directories")
+	}
+}
+
+func TestRecycle(t *testing.T) {
+	w, err := tempfile.New(t.TempDir(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString("a much longer first section"); err != nil {
+		t.Fatal(err)
+	}
+	r, err := w.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r.Read(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	recycled, err := tempfile.Recycle(r)
+	if err != nil {
+		t.Fatalf("Recycle error: %v", err)
+	}
+	for _, section := range []string{"one", "two"} {
+		if _, err := recycled.WriteString(section); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := recycled.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err = recycled.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	// the trailing empty section is added by Save
+	if r.Size() != 3 {
+		t.Fatalf("expected 3 sections, got %d", r.Size())
+	}
+	for i, want := range []string{"one", "two", ""} {
+		got, err := io.ReadAll(r.Read(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("section %d: expected %q, got %q", i, want, got)
+		}
+	}
+
+	mock, err := tempfile.Mock(0).Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tempfile.Recycle(mock); !errors.Is(err, tempfile.ErrNotRecyclable) {
+		t.Errorf("expected ErrNotRecyclable for a mock reader, got %v", err)
+	}
+}
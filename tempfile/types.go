@@ -36,7 +36,8 @@ type TempWriter interface {
 // Multiple readers can access different sections simultaneously for efficient merging.
 type TempReader interface {
 	// Close terminates the reader and cleans up resources.
-	// This should be called after all reading operations are complete.
+	// This should be called after all reading operations are complete; readers
+	// returned by Read must not be used afterwards, since their buffers are reused.
 	io.Closer
 
 	// Size returns the total number of virtual file sections available for reading.
@@ -47,3 +48,22 @@ type TempReader interface {
 	// Each call may return a new reader instance positioned at the section start.
 	Read(i int) *bufio.Reader
 }
+
+// SizedReader is implemented by TempReaders that can return section readers with a
+// caller chosen buffer size, which allows the memory used by readers to be bounded.
+// All TempReaders returned by this package implement it.
+type SizedReader interface {
+	// ReadSize is like Read but always returns a new reader, positioned at the
+	// section start, that buffers size bytes. Sizes below the bufio minimum are
+	// rounded up.
+	ReadSize(i, size int) *bufio.Reader
+}
+
+// ReadSize returns a reader for section i of r that buffers size bytes if r
+// implements SizedReader, and the reader returned by r.Read(i) otherwise.
+func ReadSize(r TempReader, i, size int) *bufio.Reader {
+	if sr, ok := r.(SizedReader); ok {
+		return sr.ReadSize(i, size)
+	}
+	return r.Read(i)
+}
@@ -1,12 +1,20 @@
 package extsort_test
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/lanrat/extsort"
+	"github.com/lanrat/extsort/tempfile"
 )
 
 // TestTempFileCreationFailure tests that the library handles tempfile creation
@@ -194,3 +202,193 @@ func (td *testData) ToBytes() []byte {
 	copy(result[1:], td.Value)
 	return result
 }
+
+// failingTempConfig returns a config whose temporary file cannot be created.
+// An unwritable TempFilesDir is not enough, since the library then falls back to
+// another directory, so the file name is made to collide with an existing file.
+func failingTempConfig(t *testing.T) *extsort.Config {
+	config := extsort.DefaultConfig()
+	config.TempFilesDir = t.TempDir()
+	config.TempFileID = func() string { return "taken" }
+	if err := os.WriteFile(filepath.Join(config.TempFilesDir, "extsort_taken"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// TestMemoryFallback verifies that a small input is sorted in memory when the
+// temporary file cannot be created and AllowMemoryFallback is set.
+func TestMemoryFallback(t *testing.T) {
+	data := generateRandomInts(50)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := failingTempConfig(t)
+	config.ChunkSize = 100
+	config.AllowMemoryFallback = true
+
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	if sorter == nil {
+		t.Fatalf("expected a sorter, got error: %v", <-errChan)
+	}
+	sorter.Sort(context.Background())
+	got, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	slices.Sort(data)
+	if !slices.Equal(got, data) {
+		t.Fatal("output does not match sorted input")
+	}
+}
+
+// TestMemoryFallbackTooLarge verifies that the temporary file error is reported
+// when the input does not fit in a single chunk, and when the fallback is disabled.
+func TestMemoryFallbackTooLarge(t *testing.T) {
+	for _, allowFallback := range []bool{true, false} {
+		inputChan := make(chan int, 1000)
+		for _, v := range generateRandomInts(1000) {
+			inputChan <- v
+		}
+		close(inputChan)
+
+		config := failingTempConfig(t)
+		config.ChunkSize = 100
+		config.AllowMemoryFallback = allowFallback
+
+		sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+		if (sorter != nil) != allowFallback {
+			t.Fatalf("fallback %v: unexpected sorter %v", allowFallback, sorter)
+		}
+		if sorter != nil {
+			sorter.Sort(context.Background())
+		}
+		got, err := extsort.Collect(outChan, errChan, 0)
+		if !errors.Is(err, fs.ErrExist) {
+			t.Errorf("fallback %v: expected the temporary directory error, got %v", allowFallback, err)
+		}
+		if len(got) != 0 {
+			t.Errorf("fallback %v: expected no output, got %d records", allowFallback, len(got))
+		}
+	}
+}
+
+func TestWriteRetries(t *testing.T) {
+	data := generateRandomInts(2000)
+	inputChan := make(chan int, len(data))
+	for _, v := range data {
+		inputChan <- v
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.WriteRetries = 3
+	sorter, outChan, errChan := extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], config)
+	sorter.Sort(context.Background())
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	slices.Sort(data)
+	if !slices.Equal(result, data) {
+		t.Fatal("output does not match sorted input")
+	}
+
+	inputChan = make(chan int)
+	close(inputChan)
+	sorter, outChan, errChan = extsort.Generic(inputChan, intFromBytes, intToBytes, cmp.Compare[int], &extsort.Config{WriteRetries: -1})
+	sorter.Sort(context.Background())
+	_, err = extsort.Collect(outChan, errChan, 0)
+	var configErr *extsort.ConfigError
+	if !errors.As(err, &configErr) || configErr.Field != "WriteRetries" {
+		t.Errorf("expected a WriteRetries ConfigError, got %v", err)
+	}
+}
+
+// flakyWriter fails every other write with a transient error, or every write when
+// always is set.
+type flakyWriter struct {
+	w      io.Writer
+	always bool
+	calls  int
+}
+
+func (f *flakyWriter) Write(p []byte) (int, error) {
+	f.calls++
+	if f.always || f.calls%2 == 1 {
+		return 0, &os.PathError{Op: "write", Path: "flaky", Err: syscall.EAGAIN}
+	}
+	return f.w.Write(p)
+}
+
+// TestWriteRetriesFlaky verifies that the sorter retries temporary file writes
+// that fail transiently, and that cancelling the sort ends the waits between them.
+func TestWriteRetriesFlaky(t *testing.T) {
+	data := generateRandomInts(2000)
+	input := func() chan int {
+		inputChan := make(chan int, len(data))
+		for _, v := range data {
+			inputChan <- v
+		}
+		close(inputChan)
+		return inputChan
+	}
+
+	var flaky []*flakyWriter
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 100
+	config.WriteRetries = 3
+	config.RetryBackoff = time.Microsecond
+	sorter, outChan, errChan := extsort.Generic(input(), intFromBytes, intToBytes, cmp.Compare[int], config)
+	err := extsort.SetTempFileOptions(sorter, tempfile.WithFileWriter(func(w io.Writer) io.Writer {
+		f := &flakyWriter{w: w}
+		flaky = append(flaky, f)
+		return f
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sorter.Sort(context.Background())
+	result, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+	slices.Sort(data)
+	if !slices.Equal(result, data) {
+		t.Fatal("output does not match sorted input")
+	}
+	if len(flaky) == 0 || flaky[0].calls < 2 {
+		t.Fatal("expected temporary file writes to be retried")
+	}
+
+	// a write that never succeeds waits an hour between retries unless cancelled
+	config.RetryBackoff = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sorter, outChan, errChan = extsort.Generic(input(), intFromBytes, intToBytes, cmp.Compare[int], config)
+	err = extsort.SetTempFileOptions(sorter, tempfile.WithFileWriter(func(w io.Writer) io.Writer {
+		return &flakyWriter{w: w, always: true}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	done := make(chan error, 1)
+	go func() {
+		sorter.Sort(ctx)
+		_, err := extsort.Collect(outChan, errChan, 0)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected the cancelled sort to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled sort still waiting to retry")
+	}
+}
@@ -33,4 +33,9 @@ type ToBytesGeneric[E any] func(E) ([]byte, error)
 // and a positive integer if a should be ordered after b in the final sorted output.
 // The function must be consistent and must handle any errors by panicking.
 // This follows the same semantics as cmp.Compare and can be implemented using cmp.Compare[T] for ordered types.
+// Both chunk sorting and the merge call it with whole records, as deserialized by
+// FromBytesGeneric, so fields beyond the sort key, such as a version number, may be
+// used to break ties as long as the serialization keeps them.
+// A comparison that needs state, such as a lookup table, can be passed as a method
+// value, for example table.Compare.
 type CompareGeneric[E any] func(a, b E) int
@@ -1,14 +1,17 @@
 package extsort
 
+import "bytes"
+
 // UniqStringChan returns a channel that filters out consecutive duplicate strings from the input.
 // This function assumes the input channel provides strings in sorted order and uses string equality
 // to detect duplicates. It preserves the first occurrence of each unique string while filtering
 // out subsequent duplicates. The function is optimized for sorted inputs where duplicates appear
 // consecutively, making it suitable for post-processing sorted results from external sort operations.
 //
+// The input may be the output channel of a sorter, such as the one returned by Strings.
 // The returned channel will be closed when the input channel is closed.
 // This function spawns a goroutine that will terminate when the input channel is closed.
-func UniqStringChan(in chan string) chan string {
+func UniqStringChan(in <-chan string) chan string {
 	out := make(chan string)
 	go func() {
 		var prior string
@@ -28,3 +31,42 @@ func UniqStringChan(in chan string) chan string {
 	}()
 	return out
 }
+
+// UniqKeyChan returns a channel that collapses consecutive records sharing the same
+// dedup key, as returned by keyFunc and compared with bytes.Equal. The dedup key can
+// be narrower than the sort order; for example, records sorted by id and timestamp
+// can be deduplicated by id alone. Like UniqStringChan, it assumes records with the
+// same key arrive consecutively, which holds when the sort order groups them.
+//
+// When mergeFunc is nil, the first record of each run is kept. Otherwise every
+// duplicate is folded into the kept record with mergeFunc(kept, duplicate), and the
+// result is emitted once the run ends. The key of the first record identifies the run.
+//
+// The returned channel will be closed when the input channel is closed.
+// This function spawns a goroutine that will terminate when the input channel is closed.
+func UniqKeyChan[E any](in <-chan E, keyFunc func(E) []byte, mergeFunc func(kept, duplicate E) E) <-chan E {
+	out := make(chan E)
+	go func() {
+		var kept E
+		var keptKey []byte
+		keptSet := false
+		for d := range in {
+			key := keyFunc(d)
+			if keptSet && bytes.Equal(key, keptKey) {
+				if mergeFunc != nil {
+					kept = mergeFunc(kept, d)
+				}
+				continue
+			}
+			if keptSet {
+				out <- kept
+			}
+			kept, keptKey, keptSet = d, key, true
+		}
+		if keptSet {
+			out <- kept
+		}
+		close(out)
+	}()
+	return out
+}
@@ -1,7 +1,12 @@
 package extsort_test
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
 	"fmt"
+	"slices"
+	"strconv"
 	"testing"
 
 	"github.com/lanrat/extsort"
@@ -30,3 +35,115 @@ func TestUniqString(t *testing.T) {
 		past = u
 	}
 }
+
+// TestSortPipeline verifies that the output of one sort can be fed directly into
+// another sort and into UniqStringChan without being materialized.
+func TestSortPipeline(t *testing.T) {
+	inputChan := make(chan string, 100)
+	for i := 0; i < 100; i++ {
+		inputChan <- strconv.Itoa(i % 10)
+	}
+	close(inputChan)
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 30
+	ctx := context.Background()
+
+	// sort in descending order, then re-sort the output in ascending order
+	fromBytes := func(b []byte) (string, error) { return string(b), nil }
+	toBytes := func(s string) ([]byte, error) { return []byte(s), nil }
+	descending := func(a, b string) int { return cmp.Compare(b, a) }
+	first, firstOut, firstErr := extsort.Generic(inputChan, fromBytes, toBytes, descending, config)
+	first.Sort(ctx)
+	second, secondOut, secondErr := extsort.Strings(firstOut, config)
+	second.Sort(ctx)
+
+	var result []string
+	for s := range extsort.UniqStringChan(secondOut) {
+		result = append(result, s)
+	}
+	if err := <-firstErr; err != nil {
+		t.Fatalf("first sort error: %v", err)
+	}
+	if err := <-secondErr; err != nil {
+		t.Fatalf("second sort error: %v", err)
+	}
+	expected := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+	if !slices.Equal(result, expected) {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+}
+
+// event is a record sorted by id then timestamp but deduplicated by id only.
+type event struct {
+	ID, TS int
+}
+
+// sortEvents sorts events by id then timestamp and returns them on a channel.
+func sortEvents(t *testing.T, events []event) <-chan event {
+	t.Helper()
+	inputChan := make(chan event, len(events))
+	for _, e := range events {
+		inputChan <- e
+	}
+	close(inputChan)
+
+	compare := func(a, b event) int {
+		if c := cmp.Compare(a.ID, b.ID); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.TS, b.TS)
+	}
+	fromBytes := func(b []byte) (event, error) {
+		var e event
+		err := json.Unmarshal(b, &e)
+		return e, err
+	}
+	toBytes := func(e event) ([]byte, error) { return json.Marshal(e) }
+
+	config := extsort.DefaultConfig()
+	config.ChunkSize = 3
+	sorter, outChan, errChan := extsort.Generic(inputChan, fromBytes, toBytes, compare, config)
+	sorter.Sort(context.Background())
+	sorted, err := extsort.Collect(outChan, errChan, 0)
+	if err != nil {
+		t.Fatalf("sort error: %v", err)
+	}
+
+	sortedChan := make(chan event, len(sorted))
+	for _, e := range sorted {
+		sortedChan <- e
+	}
+	close(sortedChan)
+	return sortedChan
+}
+
+var uniqEvents = []event{{2, 30}, {1, 20}, {3, 5}, {1, 10}, {2, 10}, {1, 30}, {2, 20}}
+
+func idKey(e event) []byte {
+	return []byte(strconv.Itoa(e.ID))
+}
+
+func TestUniqKeyKeepFirst(t *testing.T) {
+	var result []event
+	for e := range extsort.UniqKeyChan(sortEvents(t, uniqEvents), idKey, nil) {
+		result = append(result, e)
+	}
+	want := []event{{1, 10}, {2, 10}, {3, 5}}
+	if !slices.Equal(result, want) {
+		t.Fatalf("expected %v, got %v", want, result)
+	}
+}
+
+func TestUniqKeyMerge(t *testing.T) {
+	// keep the latest timestamp of each id
+	latest := func(kept, duplicate event) event { return duplicate }
+	var result []event
+	for e := range extsort.UniqKeyChan(sortEvents(t, uniqEvents), idKey, latest) {
+		result = append(result, e)
+	}
+	want := []event{{1, 30}, {2, 30}, {3, 5}}
+	if !slices.Equal(result, want) {
+		t.Fatalf("expected %v, got %v", want, result)
+	}
+}